@@ -0,0 +1,47 @@
+package mongoutils
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// BatchProgress batch migration progress
+type BatchProgress struct {
+	Name string
+	// Processed documents count (including previous runs)
+	Processed int64
+	// Total estimated documents count
+	Total   int64
+	Batches int64
+	LastID  any
+	Done    bool
+	Elapsed time.Duration
+	// ETA estimated remaining time
+	ETA time.Duration
+}
+
+// BatchMigrator batched data migration over collection iterated by _id
+//
+// position stored in state collection after each batch, so run resumed after crash
+type BatchMigrator interface {
+	// Filter set documents filter
+	Filter(filter any) BatchMigrator
+	// BatchSize set batch size (default 1000)
+	BatchSize(size int64) BatchMigrator
+	// Throttle set wait time between batches
+	Throttle(d time.Duration) BatchMigrator
+	// Update set update document or pipeline applied to each batch using updateMany
+	Update(update any) BatchMigrator
+	// Each set per document transform, returned update applied to document (nil update ignored)
+	Each(fn func(ctx context.Context, doc primitive.M) (any, error)) BatchMigrator
+	// Batch set per batch transform
+	Batch(fn func(ctx context.Context, docs []primitive.M) error) BatchMigrator
+	// OnProgress set progress callback called after each batch
+	OnProgress(fn func(p BatchProgress)) BatchMigrator
+	// Run run or resume migration
+	Run(ctx context.Context) (BatchProgress, error)
+	// Reset remove saved position
+	Reset(ctx context.Context) error
+}
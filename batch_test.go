@@ -0,0 +1,85 @@
+package mongoutils_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bopher/mongoutils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestBatchMigrator(t *testing.T) {
+	ctx := context.TODO()
+	newMigrator := func(count int) (mongoutils.BatchMigrator, mongoutils.Collection) {
+		db := mongoutils.NewMemoryDatabase()
+		coll := db.Collection("items")
+		for i := 0; i < count; i++ {
+			coll.InsertOne(ctx, mongoutils.Map("_id", i))
+		}
+		return mongoutils.NewBatchMigrator("items", coll, db.Collection("batches")).BatchSize(3), coll
+	}
+
+	// No transform
+	m, _ := newMigrator(1)
+	if _, err := m.Run(ctx); err == nil {
+		t.Fatal("fail Run without transform")
+	}
+
+	// Chunking
+	for count, expected := range map[int][]int64{7: {3, 6, 7}, 6: {3, 6, 6}, 0: {0}} {
+		m, coll := newMigrator(count)
+		progress := make([]mongoutils.BatchProgress, 0)
+		m.Each(func(ctx context.Context, doc primitive.M) (any, error) {
+			return mongoutils.Set(mongoutils.Map("migrated", true)), nil
+		}).OnProgress(func(p mongoutils.BatchProgress) {
+			progress = append(progress, p)
+		})
+		res, err := m.Run(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(progress) != len(expected) || !res.Done || res.Processed != int64(count) || res.Total != int64(count) {
+			t.Fatal("fail chunking", count, progress, res)
+		}
+		for i := range expected {
+			if progress[i].Processed != expected[i] {
+				t.Fatal("fail chunking boundary", count, progress)
+			}
+			// ETA estimated for unfinished batches only
+			if progress[i].Done != (i == len(expected)-1) || progress[i].Done && progress[i].ETA != 0 || progress[i].ETA < 0 {
+				t.Fatal("fail progress", count, progress[i])
+			}
+		}
+		if n, _ := coll.CountDocuments(ctx, mongoutils.Map("migrated", true)); n != int64(count) {
+			t.Fatal("fail Each update", count, n)
+		}
+		if again, _ := m.Run(ctx); !again.Done || again.Processed != int64(count) || again.Batches != res.Batches {
+			t.Fatal("fail resume done", again)
+		}
+	}
+
+	// Update, Reset and ETA
+	m, coll := newMigrator(4)
+	var eta time.Duration
+	m.BatchSize(2).
+		Throttle(time.Millisecond).
+		Update(mongoutils.Set(mongoutils.Map("version", 2))).
+		OnProgress(func(p mongoutils.BatchProgress) {
+			if p.Batches == 1 {
+				eta = p.ETA
+			}
+		})
+	if res, err := m.Run(ctx); err != nil || res.Processed != 4 || res.Batches != 2 {
+		t.Fatal("fail Update", res, err)
+	}
+	if n, _ := coll.CountDocuments(ctx, mongoutils.Map("version", 2)); n != 4 || eta <= 0 {
+		t.Fatal("fail Update count or ETA", n, eta)
+	}
+	if err := m.Reset(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if res, err := m.Run(ctx); err != nil || res.Processed != 4 {
+		t.Fatal("fail Reset", res, err)
+	}
+}
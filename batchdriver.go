@@ -0,0 +1,224 @@
+package mongoutils
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type batchState struct {
+	ID        string    `bson:"_id"`
+	LastID    any       `bson:"last_id"`
+	Processed int64     `bson:"processed"`
+	Batches   int64     `bson:"batches"`
+	Done      bool      `bson:"done"`
+	UpdatedAt time.Time `bson:"updated_at"`
+}
+
+type batchMigrator struct {
+	name       string
+	coll       Collection
+	state      Collection
+	filter     any
+	size       int64
+	throttle   time.Duration
+	update     any
+	each       func(ctx context.Context, doc primitive.M) (any, error)
+	batch      func(ctx context.Context, docs []primitive.M) error
+	onProgress func(p BatchProgress)
+}
+
+func (me *batchMigrator) Filter(filter any) BatchMigrator {
+	me.filter = filter
+	return me
+}
+
+func (me *batchMigrator) BatchSize(size int64) BatchMigrator {
+	if size > 0 {
+		me.size = size
+	}
+	return me
+}
+
+func (me *batchMigrator) Throttle(d time.Duration) BatchMigrator {
+	me.throttle = d
+	return me
+}
+
+func (me *batchMigrator) Update(update any) BatchMigrator {
+	me.update = update
+	return me
+}
+
+func (me *batchMigrator) Each(fn func(ctx context.Context, doc primitive.M) (any, error)) BatchMigrator {
+	me.each = fn
+	return me
+}
+
+func (me *batchMigrator) Batch(fn func(ctx context.Context, docs []primitive.M) error) BatchMigrator {
+	me.batch = fn
+	return me
+}
+
+func (me *batchMigrator) OnProgress(fn func(p BatchProgress)) BatchMigrator {
+	me.onProgress = fn
+	return me
+}
+
+func (me *batchMigrator) Run(ctx context.Context) (BatchProgress, error) {
+	progress := BatchProgress{Name: me.name}
+	if me.update == nil && me.each == nil && me.batch == nil {
+		return progress, errors.New("mongoutils: batch migration has no transform")
+	}
+
+	state := batchState{ID: me.name}
+	err := me.state.FindOne(ctx, primitive.M{"_id": me.name}).Decode(&state)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return progress, err
+	}
+	progress.Processed = state.Processed
+	progress.Batches = state.Batches
+	progress.LastID = state.LastID
+	progress.Done = state.Done
+	if state.Done {
+		progress.Total = state.Processed
+		return progress, nil
+	}
+
+	filter := me.filter
+	if filter == nil {
+		filter = primitive.M{}
+	}
+	if progress.Total, err = me.coll.CountDocuments(ctx, filter); err != nil {
+		return progress, err
+	}
+
+	start := time.Now()
+	startProcessed := progress.Processed
+	opt := options.Find().SetSort(primitive.D{{Key: "_id", Value: 1}}).SetLimit(me.size)
+	if me.each == nil && me.batch == nil {
+		opt.SetProjection(primitive.M{"_id": 1})
+	}
+	for {
+		query := filter
+		if state.LastID != nil {
+			query = primitive.M{"$and": primitive.A{filter, primitive.M{"_id": primitive.M{"$gt": state.LastID}}}}
+		}
+		cursor, err := me.coll.Find(ctx, query, opt)
+		if err != nil {
+			return progress, err
+		}
+		docs := make([]primitive.M, 0, me.size)
+		if err := cursor.All(ctx, &docs); err != nil {
+			return progress, err
+		}
+
+		if len(docs) == 0 {
+			state.Done = true
+		} else {
+			if err := me.apply(ctx, docs); err != nil {
+				return progress, err
+			}
+			state.LastID = docs[len(docs)-1]["_id"]
+			state.Processed += int64(len(docs))
+			state.Batches++
+			state.Done = int64(len(docs)) < me.size
+		}
+
+		state.UpdatedAt = time.Now().UTC()
+		if _, err := me.state.ReplaceOne(ctx, primitive.M{"_id": me.name}, state, options.Replace().SetUpsert(true)); err != nil {
+			return progress, err
+		}
+
+		progress.Processed = state.Processed
+		progress.Batches = state.Batches
+		progress.LastID = state.LastID
+		progress.Done = state.Done
+		progress.Elapsed = time.Since(start)
+		if progress.Total < progress.Processed {
+			progress.Total = progress.Processed
+		}
+		progress.ETA = 0
+		if !progress.Done {
+			progress.ETA = batchETA(progress.Elapsed, progress.Processed-startProcessed, progress.Total-progress.Processed)
+		}
+		if me.onProgress != nil {
+			me.onProgress(progress)
+		}
+		if progress.Done {
+			return progress, nil
+		}
+
+		if me.throttle > 0 {
+			select {
+			case <-ctx.Done():
+				return progress, ctx.Err()
+			case <-time.After(me.throttle):
+			}
+		}
+	}
+}
+
+// batchETA estimate remaining time from elapsed time of done documents in current run
+func batchETA(elapsed time.Duration, done int64, remaining int64) time.Duration {
+	if done <= 0 || remaining <= 0 {
+		return 0
+	}
+	return time.Duration(float64(elapsed) / float64(done) * float64(remaining))
+}
+
+func (me *batchMigrator) Reset(ctx context.Context) error {
+	_, err := me.state.DeleteOne(ctx, primitive.M{"_id": me.name})
+	return err
+}
+
+// apply run transforms on batch documents
+func (me *batchMigrator) apply(ctx context.Context, docs []primitive.M) error {
+	if me.update != nil {
+		ids := make(primitive.A, len(docs))
+		for i, doc := range docs {
+			ids[i] = doc["_id"]
+		}
+		if _, err := me.coll.UpdateMany(ctx, In("_id", ids...), me.update); err != nil {
+			return err
+		}
+	}
+	if me.each != nil {
+		models := make([]mongo.WriteModel, 0, len(docs))
+		for _, doc := range docs {
+			update, err := me.each(ctx, doc)
+			if err != nil {
+				return err
+			}
+			if update != nil {
+				models = append(models, mongo.NewUpdateOneModel().
+					SetFilter(primitive.M{"_id": doc["_id"]}).
+					SetUpdate(update))
+			}
+		}
+		if bw, ok := me.coll.(bulkWriter); ok {
+			if len(models) > 0 {
+				if _, err := bw.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false)); err != nil {
+					return err
+				}
+			}
+		} else {
+			for _, model := range models {
+				m := model.(*mongo.UpdateOneModel)
+				if _, err := me.coll.UpdateOne(ctx, m.Filter, m.Update); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if me.batch != nil {
+		if err := me.batch(ctx, docs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package mongoutils
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Collection common collection operations implemented by *mongo.Collection and in-memory collection
+type Collection interface {
+	// Name get collection name
+	Name() string
+	// InsertOne insert document, _id generated if missing
+	InsertOne(ctx context.Context, document any, opts ...*options.InsertOneOptions) (*mongo.InsertOneResult, error)
+	// InsertMany insert documents
+	InsertMany(ctx context.Context, documents []any, opts ...*options.InsertManyOptions) (*mongo.InsertManyResult, error)
+	// Find find documents by filter
+	Find(ctx context.Context, filter any, opts ...*options.FindOptions) (*mongo.Cursor, error)
+	// FindOne find first document by filter
+	FindOne(ctx context.Context, filter any, opts ...*options.FindOneOptions) *mongo.SingleResult
+	// UpdateOne update first document by filter
+	UpdateOne(ctx context.Context, filter any, update any, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error)
+	// UpdateMany update all documents by filter
+	UpdateMany(ctx context.Context, filter any, update any, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error)
+	// ReplaceOne replace first document by filter
+	ReplaceOne(ctx context.Context, filter any, replacement any, opts ...*options.ReplaceOptions) (*mongo.UpdateResult, error)
+	// DeleteOne delete first document by filter
+	DeleteOne(ctx context.Context, filter any, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error)
+	// DeleteMany delete all documents by filter
+	DeleteMany(ctx context.Context, filter any, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error)
+	// CountDocuments count documents by filter
+	CountDocuments(ctx context.Context, filter any, opts ...*options.CountOptions) (int64, error)
+	// Aggregate run aggregation pipeline
+	Aggregate(ctx context.Context, pipeline any, opts ...*options.AggregateOptions) (*mongo.Cursor, error)
+	// Distinct get distinct values of field
+	Distinct(ctx context.Context, fieldName string, filter any, opts ...*options.DistinctOptions) ([]any, error)
+}
+
+// MemoryDatabase in-memory collections container
+type MemoryDatabase interface {
+	// Collection get or create collection
+	Collection(name string) Collection
+	// Drop remove all collections
+	Drop()
+}
+
+// WrapCollection use mongo collection as Collection
+func WrapCollection(coll *mongo.Collection) Collection {
+	return coll
+}
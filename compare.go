@@ -0,0 +1,316 @@
+package mongoutils
+
+import (
+	"bytes"
+	"math"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// toDocument convert struct, map, primitive.D or bson.Raw to primitive.D
+//
+// nested documents converted to primitive.D and arrays to primitive.A
+func toDocument(v any) (primitive.D, error) {
+	if v == nil {
+		return primitive.D{}, nil
+	}
+	var data []byte
+	switch val := v.(type) {
+	case bson.Raw:
+		data = val
+	case []byte:
+		data = val
+	default:
+		var err error
+		if data, err = bson.Marshal(v); err != nil {
+			return nil, err
+		}
+	}
+	res := primitive.D{}
+	if err := bson.Unmarshal(data, &res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// toValue convert go value to bson value with the same representation as toDocument
+func toValue(v any) (any, error) {
+	doc, err := toDocument(primitive.D{{Key: "v", Value: v}})
+	if err != nil {
+		return nil, err
+	}
+	return doc[0].Value, nil
+}
+
+// copyValue deep copy documents and arrays
+func copyValue(v any) any {
+	switch val := v.(type) {
+	case primitive.D:
+		res := make(primitive.D, len(val))
+		for i, e := range val {
+			res[i] = primitive.E{Key: e.Key, Value: copyValue(e.Value)}
+		}
+		return res
+	case primitive.A:
+		res := make(primitive.A, len(val))
+		for i, e := range val {
+			res[i] = copyValue(e)
+		}
+		return res
+	}
+	return v
+}
+
+// canonicalType bson type order used by comparison and sort
+//
+// MinKey < Null < Numbers < String < Object < Array < BinData < ObjectId < Boolean < Date < Timestamp < Regex < MaxKey
+func canonicalType(v any) int {
+	switch v.(type) {
+	case primitive.MinKey:
+		return 1
+	case nil, primitive.Null, primitive.Undefined:
+		return 2
+	case int32, int64, float64, primitive.Decimal128, int, float32:
+		return 3
+	case string, primitive.Symbol:
+		return 4
+	case primitive.D:
+		return 5
+	case primitive.A:
+		return 6
+	case primitive.Binary:
+		return 7
+	case primitive.ObjectID:
+		return 8
+	case bool:
+		return 9
+	case primitive.DateTime:
+		return 10
+	case primitive.Timestamp:
+		return 11
+	case primitive.Regex:
+		return 12
+	case primitive.MaxKey:
+		return 14
+	}
+	return 13
+}
+
+// toFloat convert numeric value to float64
+func toFloat(v any) (float64, bool) {
+	switch val := v.(type) {
+	case int32:
+		return float64(val), true
+	case int64:
+		return float64(val), true
+	case int:
+		return float64(val), true
+	case float64:
+		return val, true
+	case float32:
+		return float64(val), true
+	case primitive.Decimal128:
+		f, err := strconv.ParseFloat(val.String(), 64)
+		if err != nil {
+			return math.NaN(), true
+		}
+		return f, true
+	}
+	return 0, false
+}
+
+// toInteger convert integral numeric value to int64
+func toInteger(v any) (int64, bool) {
+	if i, ok := intValue(v); ok {
+		return i, true
+	}
+	if f, ok := toFloat(v); ok && !math.IsNaN(f) && f == math.Trunc(f) {
+		return int64(f), true
+	}
+	return 0, false
+}
+
+// intValue get value of integer types
+func intValue(v any) (int64, bool) {
+	switch val := v.(type) {
+	case int32:
+		return int64(val), true
+	case int64:
+		return val, true
+	case int:
+		return int64(val), true
+	}
+	return 0, false
+}
+
+// truncInteger convert numeric value to int64 truncating fraction
+func truncInteger(v any) (int64, bool) {
+	if i, ok := intValue(v); ok {
+		return i, true
+	}
+	if f, ok := toFloat(v); ok && !math.IsNaN(f) {
+		return int64(f), true
+	}
+	return 0, false
+}
+
+// compareIntFloat compare integer with float without converting integer to float,
+// integers above 2^53 can not represented as float64 exactly
+func compareIntFloat(i int64, f float64) int {
+	switch {
+	case math.IsNaN(f):
+		return 1
+	case f >= math.MaxInt64:
+		return -1
+	case f < math.MinInt64:
+		return 1
+	}
+	t := math.Trunc(f)
+	if c := compareInt(i, int64(t)); c != 0 {
+		return c
+	}
+	switch {
+	case f > t:
+		return -1
+	case f < t:
+		return 1
+	}
+	return 0
+}
+
+// isNumber check if value is bson number
+func isNumber(v any) bool {
+	return canonicalType(v) == 3
+}
+
+// compareValues compare two bson values following mongodb comparison order
+//
+// returns -1, 0 or 1. values of different types compared by canonical type order
+func compareValues(a, b any) int {
+	ta, tb := canonicalType(a), canonicalType(b)
+	if ta != tb {
+		return compareInt(int64(ta), int64(tb))
+	}
+	switch x := a.(type) {
+	case int32, int64, int, float64, float32, primitive.Decimal128:
+		ia, aInt := intValue(a)
+		ib, bInt := intValue(b)
+		fa, _ := toFloat(a)
+		fb, _ := toFloat(b)
+		_, aDec := a.(primitive.Decimal128)
+		_, bDec := b.(primitive.Decimal128)
+		switch {
+		case aInt && bInt:
+			return compareInt(ia, ib)
+		case aInt && !bDec:
+			return compareIntFloat(ia, fb)
+		case bInt && !aDec:
+			return -compareIntFloat(ib, fa)
+		}
+		switch {
+		case math.IsNaN(fa) && math.IsNaN(fb):
+			return 0
+		case math.IsNaN(fa):
+			return -1
+		case math.IsNaN(fb):
+			return 1
+		case fa < fb:
+			return -1
+		case fa > fb:
+			return 1
+		}
+		return 0
+	case string, primitive.Symbol:
+		return strings.Compare(stringOf(a), stringOf(b))
+	case primitive.D:
+		y := b.(primitive.D)
+		for i := 0; i < len(x) && i < len(y); i++ {
+			if c := compareInt(int64(canonicalType(x[i].Value)), int64(canonicalType(y[i].Value))); c != 0 {
+				return c
+			}
+			if c := strings.Compare(x[i].Key, y[i].Key); c != 0 {
+				return c
+			}
+			if c := compareValues(x[i].Value, y[i].Value); c != 0 {
+				return c
+			}
+		}
+		return compareInt(int64(len(x)), int64(len(y)))
+	case primitive.A:
+		y := b.(primitive.A)
+		for i := 0; i < len(x) && i < len(y); i++ {
+			if c := compareValues(x[i], y[i]); c != 0 {
+				return c
+			}
+		}
+		return compareInt(int64(len(x)), int64(len(y)))
+	case primitive.Binary:
+		y := b.(primitive.Binary)
+		if c := compareInt(int64(len(x.Data)), int64(len(y.Data))); c != 0 {
+			return c
+		}
+		if c := compareInt(int64(x.Subtype), int64(y.Subtype)); c != 0 {
+			return c
+		}
+		return bytes.Compare(x.Data, y.Data)
+	case primitive.ObjectID:
+		y := b.(primitive.ObjectID)
+		return bytes.Compare(x[:], y[:])
+	case bool:
+		y := b.(bool)
+		switch {
+		case x == y:
+			return 0
+		case !x:
+			return -1
+		}
+		return 1
+	case primitive.DateTime:
+		return compareInt(int64(x), int64(b.(primitive.DateTime)))
+	case primitive.Timestamp:
+		y := b.(primitive.Timestamp)
+		return primitive.CompareTimestamp(x, y)
+	case primitive.Regex:
+		y := b.(primitive.Regex)
+		if c := strings.Compare(x.Pattern, y.Pattern); c != 0 {
+			return c
+		}
+		return strings.Compare(x.Options, y.Options)
+	}
+	return 0
+}
+
+func compareInt(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func stringOf(v any) string {
+	if s, ok := v.(primitive.Symbol); ok {
+		return string(s)
+	}
+	s, _ := v.(string)
+	return s
+}
+
+// isTruthy check if value is true in projection and $exists context
+func isTruthy(v any) bool {
+	switch val := v.(type) {
+	case nil, primitive.Null, primitive.Undefined:
+		return false
+	case bool:
+		return val
+	}
+	if f, ok := toFloat(v); ok {
+		return f != 0
+	}
+	return true
+}
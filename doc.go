@@ -3,6 +3,9 @@ package mongoutils
 import "go.mongodb.org/mongo-driver/bson/primitive"
 
 // MongoDoc mongo document (primitive.D) builder
+//
+// in strict mode $ prefixed or dotted field and nested keys and values containing such keys rejected and reported by Err,
+// regex patterns escaped and matched literally
 type MongoDoc interface {
 	// Add add new element
 	Add(k string, v any) MongoDoc
@@ -20,10 +23,17 @@ type MongoDoc interface {
 	NestedArray(root string, k string, v ...any) MongoDoc
 	// NestedDocArray add new nested array element with doc
 	NestedDocArray(root string, k string, cb func(d MongoDoc) MongoDoc) MongoDoc
-	// Regex add new element with regex value
+	// Regex add new element with regex value, pattern escaped in strict mode
 	Regex(k string, pattern string, opt string) MongoDoc
 	// Map creates a map from the elements of the Doc
 	Map() primitive.M
 	// Build generate mongo doc
 	Build() primitive.D
 }
+
+// StrictDoc mongo document builder for untrusted keys and values
+type StrictDoc interface {
+	MongoDoc
+	// Err get first unsafe key or value error
+	Err() error
+}
@@ -1,50 +1,128 @@
 package mongoutils
 
 import (
+	"fmt"
+
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type mDoc struct {
-	data primitive.D
+	data   primitive.D
+	strict bool
+	err    error
 }
 
-func (me *mDoc) Add(k string, v any) MongoDoc {
+// fail keep first strict mode error
+func (me *mDoc) fail(err error) bool {
+	if me.err == nil {
+		me.err = err
+	}
+	return false
+}
+
+// safeKey check key in strict mode
+func (me *mDoc) safeKey(k string) bool {
+	if me.strict && isUnsafeKey(k) {
+		return me.fail(fmt.Errorf("%w %q", ErrUnsafeKey, k))
+	}
+	return true
+}
+
+// safe check key and untrusted value in strict mode, pointer values dereferenced
+func (me *mDoc) safe(k string, v any) bool {
+	if !me.strict {
+		return true
+	}
+	if !me.safeKey(k) {
+		return false
+	}
+	if _, err := Sanitize(v); err != nil {
+		return me.fail(fmt.Errorf("%w in %q value", err, k))
+	}
+	return true
+}
+
+// child create nested builder with same mode
+func (me *mDoc) child(cb func(d MongoDoc) MongoDoc) MongoDoc {
+	d := cb(&mDoc{strict: me.strict})
+	if s, ok := d.(StrictDoc); ok && me.err == nil {
+		me.err = s.Err()
+	}
+	return d
+}
+
+func (me *mDoc) add(k string, v any) MongoDoc {
 	me.data = append(me.data, primitive.E{Key: k, Value: v})
 	return me
 }
 
+func (me *mDoc) Add(k string, v any) MongoDoc {
+	if !me.safe(k, v) {
+		return me
+	}
+	return me.add(k, v)
+}
+
 func (me *mDoc) Doc(k string, cb func(d MongoDoc) MongoDoc) MongoDoc {
-	return me.Add(k, cb(NewDoc()).Build())
+	if !me.safeKey(k) {
+		return me
+	}
+	return me.add(k, me.child(cb).Build())
 }
 
 func (me *mDoc) Array(k string, v ...any) MongoDoc {
-	return me.Add(k, v)
+	if !me.safe(k, v) {
+		return me
+	}
+	return me.add(k, v)
 }
 
 func (me *mDoc) DocArray(k string, cb func(d MongoDoc) MongoDoc) MongoDoc {
-	return me.Array(k, arrayOf(cb(NewDoc()))...)
+	if !me.safeKey(k) {
+		return me
+	}
+	return me.add(k, arrayOf(me.child(cb)))
 }
 
 func (me *mDoc) Nested(root string, k string, v any) MongoDoc {
-	return me.Add(root, primitive.M{k: v})
+	if !me.safeKey(k) || !me.safe(root, v) {
+		return me
+	}
+	return me.add(root, primitive.M{k: v})
 }
 
 func (me *mDoc) NestedDoc(root string, k string, cb func(d MongoDoc) MongoDoc) MongoDoc {
-	return me.Add(root, primitive.M{k: cb(NewDoc()).Build()})
+	if !me.safeKey(root) || !me.safeKey(k) {
+		return me
+	}
+	return me.add(root, primitive.M{k: me.child(cb).Build()})
 }
 
 func (me *mDoc) NestedArray(root string, k string, v ...any) MongoDoc {
-	return me.Add(root, primitive.M{k: v})
+	if !me.safeKey(k) || !me.safe(root, v) {
+		return me
+	}
+	return me.add(root, primitive.M{k: v})
 }
 
 func (me *mDoc) NestedDocArray(root string, k string, cb func(d MongoDoc) MongoDoc) MongoDoc {
-	return me.NestedArray(root, k, arrayOf(cb(NewDoc()))...)
+	if !me.safeKey(root) || !me.safeKey(k) {
+		return me
+	}
+	return me.add(root, primitive.M{k: arrayOf(me.child(cb))})
 }
 
 func (me *mDoc) Regex(k string, pattern string, opt string) MongoDoc {
+	if me.strict {
+		pattern = EscapeRegex(pattern)
+	}
 	return me.Add(k, primitive.Regex{Pattern: pattern, Options: opt})
 }
 
+func (me mDoc) Err() error {
+	return me.err
+}
+
 func (me mDoc) Map() primitive.M {
 	return me.data.Map()
 }
@@ -0,0 +1,52 @@
+package mongoutils
+
+import (
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ErrUnknownKey encryption key id not found in key ring
+var ErrUnknownKey = errors.New("mongoutils: unknown encryption key")
+
+// ErrInvalidCiphertext malformed or tampered ciphertext
+var ErrInvalidCiphertext = errors.New("mongoutils: invalid ciphertext")
+
+// ErrEncryptedField operation not supported on encrypted field
+var ErrEncryptedField = errors.New("mongoutils: unsupported operation on encrypted field")
+
+// Field encryption modes used in encrypt struct tag
+const (
+	EncryptRandom        = "random"
+	EncryptDeterministic = "deterministic"
+)
+
+// EncryptedSubtype binary subtype of encrypted values
+const EncryptedSubtype byte = 0x80
+
+// FieldEncryptor struct tag driven field encryption using AES-GCM with local key ring
+//
+// fields tagged with encrypt:"random" or encrypt:"deterministic" encrypted as binary with embedded key id,
+// deterministic fields generate same ciphertext for same value and key and can queried by equality
+type FieldEncryptor interface {
+	// Encrypt encrypt value with primary key, deterministic numbers normalized to int64 or float64
+	Encrypt(v any, deterministic bool) (primitive.Binary, error)
+	// Decrypt decrypt value using key id embedded in ciphertext
+	Decrypt(b primitive.Binary) (any, error)
+	// EncryptDocument encrypt tagged fields of document
+	EncryptDocument(doc any) (primitive.D, error)
+	// DecryptDocument decrypt all encrypted values of document recursively
+	DecryptDocument(doc any) (primitive.D, error)
+	// Filter encrypt deterministic field operands of filter, equality matched against ciphertext of every key
+	//
+	// returns ErrEncryptedField for random fields and operators other than $eq, $ne, $in, $nin and $exists
+	Filter(filter any) (primitive.D, error)
+	// Update encrypt $set and $setOnInsert values of tagged fields or replacement document
+	//
+	// returns ErrEncryptedField for other operators on tagged fields and pipeline updates
+	Update(update any) (any, error)
+	// Eq generate equality filter of deterministic field
+	Eq(field string, v any) (primitive.M, error)
+	// In generate $in filter of deterministic field
+	In(field string, v ...any) (primitive.M, error)
+}
@@ -0,0 +1,146 @@
+package mongoutils_test
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/bopher/mongoutils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type encryptedUser struct {
+	ID      int    `bson:"_id"`
+	Name    string `bson:"name"`
+	Email   string `bson:"email" encrypt:"deterministic"`
+	SSN     string `bson:"ssn" encrypt:"random"`
+	Pin     int64  `bson:"pin" encrypt:"deterministic"`
+	Profile struct {
+		Phone string `bson:"phone" encrypt:"deterministic"`
+	} `bson:"profile"`
+}
+
+func TestFieldEncryption(t *testing.T) {
+	ctx := context.TODO()
+	oldKey := []byte("0123456789abcdef0123456789abcdef")
+	newKey := []byte("fedcba9876543210fedcba9876543210")
+	old, err := mongoutils.NewFieldEncryptor(encryptedUser{}, "k1", map[string][]byte{"k1": oldKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc, err := mongoutils.NewFieldEncryptor(encryptedUser{}, "k2", map[string][]byte{"k1": oldKey, "k2": newKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mongoutils.NewFieldEncryptor(encryptedUser{}, "k3", map[string][]byte{"k1": oldKey}); !errors.Is(err, mongoutils.ErrUnknownKey) {
+		t.Fatal("fail unknown primary")
+	}
+
+	// Encrypt
+	a, _ := enc.Encrypt("x", true)
+	b, _ := enc.Encrypt("x", true)
+	c, _ := enc.Encrypt("x", false)
+	d, _ := enc.Encrypt("x", false)
+	if string(a.Data) != string(b.Data) || string(c.Data) == string(d.Data) {
+		t.Fatal("fail Encrypt mode")
+	}
+	if v, err := enc.Decrypt(c); err != nil || v != "x" {
+		t.Fatal("fail Decrypt")
+	}
+	c.Data[len(c.Data)-1] ^= 1
+	if _, err := enc.Decrypt(c); !errors.Is(err, mongoutils.ErrInvalidCiphertext) {
+		t.Fatal("fail Decrypt tampered")
+	}
+	if n, err := enc.Encrypt(int32(5), false); err != nil {
+		t.Fatal(err)
+	} else if v, _ := enc.Decrypt(n); v != int32(5) {
+		t.Fatal("fail random numeric type", v)
+	}
+	if u, err := enc.Encrypt(uint64(5), true); err != nil {
+		t.Fatal(err)
+	} else if i, _ := enc.Encrypt(5, true); string(u.Data) != string(i.Data) {
+		t.Fatal("fail deterministic uint64")
+	}
+	if _, err := enc.Encrypt(uint64(math.MaxUint64), true); err == nil {
+		t.Fatal("fail uint64 overflow")
+	}
+
+	// Rotation
+	raw := mongoutils.NewMemoryCollection("users")
+	coll := mongoutils.NewEncryptedCollection(raw, enc)
+	user := encryptedUser{ID: 1, Name: "John", Email: "john@mail.com", SSN: "123", Pin: 1234}
+	user.Profile.Phone = "555"
+	if _, err := mongoutils.NewEncryptedCollection(raw, old).InsertOne(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	user.ID, user.Name, user.Email = 2, "Jack", "jack@mail.com"
+	if _, err := coll.InsertOne(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	var stored primitive.M
+	raw.FindOne(ctx, mongoutils.Map("_id", 1)).Decode(&stored)
+	if _, ok := stored["email"].(primitive.Binary); !ok || stored["name"] != "John" {
+		t.Fatal("fail InsertOne encrypt")
+	}
+	if _, err := old.Decrypt(mustBinary(t, enc, "x")); !errors.Is(err, mongoutils.ErrUnknownKey) {
+		t.Fatal("fail Decrypt unknown key")
+	}
+
+	// Query
+	var found encryptedUser
+	if err := coll.FindOne(ctx, mongoutils.Map("email", "john@mail.com")).Decode(&found); err != nil {
+		t.Fatal(err)
+	}
+	if found.ID != 1 || found.SSN != "123" || found.Pin != 1234 || found.Profile.Phone != "555" {
+		t.Log(found)
+		t.Fatal("fail FindOne old key")
+	}
+	filter, _ := enc.In("email", "john@mail.com", "jack@mail.com")
+	if res, err := mongoutils.Find[primitive.M](ctx, raw, filter); err != nil || len(res) != 2 {
+		t.Fatal("fail In builder")
+	}
+	pin, _ := enc.Eq("pin", 1234)
+	if n, _ := raw.CountDocuments(ctx, pin); n != 2 {
+		t.Fatal("fail Eq numeric type")
+	}
+	if n, _ := coll.CountDocuments(ctx, mongoutils.Map("pin", int32(1234))); n != 2 {
+		t.Fatal("fail Filter numeric type")
+	}
+	if n, _ := coll.CountDocuments(ctx, mongoutils.Map("profile.phone", mongoutils.Map("$ne", "555"))); n != 0 {
+		t.Fatal("fail $ne")
+	}
+	res, err := mongoutils.Aggregate[encryptedUser](ctx, coll, mongoutils.NewPipe().Match(mongoutils.Map("email", "jack@mail.com")))
+	if err != nil || len(res) != 1 || res[0].Name != "Jack" || res[0].SSN != "123" {
+		t.Fatal("fail Aggregate")
+	}
+	for _, f := range []any{
+		mongoutils.Map("ssn", "123"),
+		mongoutils.Map("email", mongoutils.Map("$regex", "john")),
+		mongoutils.Map("profile", mongoutils.Map("phone", "555")),
+	} {
+		if _, err := coll.Find(ctx, f); !errors.Is(err, mongoutils.ErrEncryptedField) {
+			t.Log(f)
+			t.Fatal("fail Filter unsupported")
+		}
+	}
+
+	// Update
+	if _, err := coll.UpdateOne(ctx, mongoutils.Map("_id", 1), mongoutils.Set(mongoutils.Map("email", "j@mail.com"))); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := coll.CountDocuments(ctx, mongoutils.Map("email", "j@mail.com")); n != 1 {
+		t.Fatal("fail UpdateOne")
+	}
+	if _, err := coll.UpdateOne(ctx, mongoutils.Map("_id", 1), mongoutils.Map("$inc", mongoutils.Map("ssn", 1))); !errors.Is(err, mongoutils.ErrEncryptedField) {
+		t.Fatal("fail Update unsupported")
+	}
+}
+
+func mustBinary(t *testing.T, enc mongoutils.FieldEncryptor, v any) primitive.Binary {
+	b, err := enc.Encrypt(v, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
@@ -0,0 +1,633 @@
+package mongoutils
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	encryptVersion       byte = 1
+	encryptModeRandom    byte = 0
+	encryptModeDetermine byte = 1
+)
+
+type encryptionKey struct {
+	id  string
+	gcm cipher.AEAD
+	mac []byte
+}
+
+type fieldEncryptor struct {
+	primary *encryptionKey
+	keys    map[string]*encryptionKey
+	order   []string
+	// fields encrypted field paths, true for deterministic
+	fields map[string]bool
+}
+
+func newEncryptionKey(id string, key []byte) (*encryptionKey, error) {
+	if id == "" || len(id) > 255 {
+		return nil, fmt.Errorf("mongoutils: invalid encryption key id %q", id)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("mongoutils: invalid encryption key %q: %w", id, err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte("mongoutils deterministic nonce"))
+	return &encryptionKey{id: id, gcm: gcm, mac: h.Sum(nil)}, nil
+}
+
+// encryptedFields collect encrypt tagged field paths
+func encryptedFields(t reflect.Type, prefix string, res map[string]bool, visiting map[reflect.Type]bool) error {
+	if visiting[t] {
+		return nil
+	}
+	visiting[t] = true
+	defer delete(visiting, t)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, inline, skip := bsonField(f)
+		if skip {
+			continue
+		}
+		ft := indirectType(f.Type)
+		path := joinPath(prefix, name)
+		if inline {
+			path = prefix
+		}
+		if tag, ok := f.Tag.Lookup("encrypt"); ok && !inline {
+			switch tag {
+			case EncryptRandom:
+				res[path] = false
+			case EncryptDeterministic:
+				res[path] = true
+			default:
+				return fmt.Errorf("mongoutils: invalid encrypt tag %q on %s", tag, path)
+			}
+			continue
+		}
+		if ft.Kind() == reflect.Struct && !isLeafStruct(ft) {
+			if err := encryptedFields(ft, path, res, visiting); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// normalizeNumber convert integers to int64 and floats to float64 recursively,
+// deterministic ciphertext of same number must not depend on go or bson numeric type
+func normalizeNumber(v any) (any, error) {
+	switch val := v.(type) {
+	case primitive.D:
+		res := make(primitive.D, len(val))
+		for i, e := range val {
+			n, err := normalizeNumber(e.Value)
+			if err != nil {
+				return nil, err
+			}
+			res[i] = primitive.E{Key: e.Key, Value: n}
+		}
+		return res, nil
+	case primitive.A:
+		res := make(primitive.A, len(val))
+		for i, item := range val {
+			n, err := normalizeNumber(item)
+			if err != nil {
+				return nil, err
+			}
+			res[i] = n
+		}
+		return res, nil
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if rv.Uint() > math.MaxInt64 {
+			return nil, fmt.Errorf("mongoutils: %d overflows int64", rv.Uint())
+		}
+		return int64(rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), nil
+	}
+	return v, nil
+}
+
+func (me *fieldEncryptor) seal(key *encryptionKey, v any, deterministic bool) (primitive.Binary, error) {
+	v, err := toValue(v)
+	if err != nil {
+		return primitive.Binary{}, err
+	}
+	// random mode keep numeric type for round trip
+	if deterministic {
+		if v, err = normalizeNumber(v); err != nil {
+			return primitive.Binary{}, err
+		}
+	}
+	plain, err := bson.Marshal(primitive.D{{Key: "v", Value: v}})
+	if err != nil {
+		return primitive.Binary{}, err
+	}
+	nonce := make([]byte, key.gcm.NonceSize())
+	mode := encryptModeRandom
+	if deterministic {
+		mode = encryptModeDetermine
+		h := hmac.New(sha256.New, key.mac)
+		h.Write(plain)
+		copy(nonce, h.Sum(nil))
+	} else if _, err := rand.Read(nonce); err != nil {
+		return primitive.Binary{}, err
+	}
+	data := make([]byte, 0, 3+len(key.id)+len(nonce)+len(plain)+key.gcm.Overhead())
+	data = append(data, encryptVersion, mode, byte(len(key.id)))
+	data = append(data, key.id...)
+	header := data
+	data = append(data, nonce...)
+	data = key.gcm.Seal(data, nonce, plain, header)
+	return primitive.Binary{Subtype: EncryptedSubtype, Data: data}, nil
+}
+
+func (me *fieldEncryptor) Encrypt(v any, deterministic bool) (primitive.Binary, error) {
+	return me.seal(me.primary, v, deterministic)
+}
+
+func (me *fieldEncryptor) Decrypt(b primitive.Binary) (any, error) {
+	data := b.Data
+	if b.Subtype != EncryptedSubtype || len(data) < 3 || data[0] != encryptVersion {
+		return nil, ErrInvalidCiphertext
+	}
+	idLen := int(data[2])
+	if len(data) < 3+idLen {
+		return nil, ErrInvalidCiphertext
+	}
+	id := string(data[3 : 3+idLen])
+	key, ok := me.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownKey, id)
+	}
+	nonceEnd := 3 + idLen + key.gcm.NonceSize()
+	if len(data) < nonceEnd+key.gcm.Overhead() {
+		return nil, ErrInvalidCiphertext
+	}
+	plain, err := key.gcm.Open(nil, data[3+idLen:nonceEnd], data[nonceEnd:], data[:3+idLen])
+	if err != nil {
+		return nil, ErrInvalidCiphertext
+	}
+	var doc primitive.D
+	if err := bson.Unmarshal(plain, &doc); err != nil || len(doc) != 1 {
+		return nil, ErrInvalidCiphertext
+	}
+	return doc[0].Value, nil
+}
+
+// operands generate deterministic ciphertext of value for every key, primary key first
+func (me *fieldEncryptor) operands(v any) (primitive.A, error) {
+	if v == nil {
+		return primitive.A{nil}, nil
+	}
+	res := make(primitive.A, 0, len(me.order))
+	for _, id := range me.order {
+		b, err := me.seal(me.keys[id], v, true)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, b)
+	}
+	return res, nil
+}
+
+func isEncrypted(v any) bool {
+	b, ok := v.(primitive.Binary)
+	return ok && b.Subtype == EncryptedSubtype
+}
+
+func (me *fieldEncryptor) encryptAt(doc primitive.D, prefix string) (primitive.D, error) {
+	for path, deterministic := range me.fields {
+		if prefix != "" {
+			if !strings.HasPrefix(path, prefix+".") {
+				continue
+			}
+			path = path[len(prefix)+1:]
+		}
+		parts := strings.Split(path, ".")
+		v, ok := getValue(doc, parts)
+		if !ok || v == nil || isEncrypted(v) {
+			continue
+		}
+		b, err := me.Encrypt(v, deterministic)
+		if err != nil {
+			return nil, err
+		}
+		if doc, err = setValue(doc, parts, b); err != nil {
+			return nil, err
+		}
+	}
+	return doc, nil
+}
+
+func (me *fieldEncryptor) EncryptDocument(doc any) (primitive.D, error) {
+	d, err := toDocument(doc)
+	if err != nil {
+		return nil, err
+	}
+	return me.encryptAt(d, "")
+}
+
+func (me *fieldEncryptor) decryptValue(v any) (any, error) {
+	switch val := v.(type) {
+	case primitive.Binary:
+		if val.Subtype == EncryptedSubtype {
+			return me.Decrypt(val)
+		}
+	case primitive.D:
+		for i := range val {
+			item, err := me.decryptValue(val[i].Value)
+			if err != nil {
+				return nil, err
+			}
+			val[i].Value = item
+		}
+	case primitive.A:
+		for i := range val {
+			item, err := me.decryptValue(val[i])
+			if err != nil {
+				return nil, err
+			}
+			val[i] = item
+		}
+	}
+	return v, nil
+}
+
+func (me *fieldEncryptor) DecryptDocument(doc any) (primitive.D, error) {
+	d, err := toDocument(doc)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := me.decryptValue(d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// touches check if path is encrypted field or contains encrypted field
+func (me *fieldEncryptor) touches(path string) bool {
+	for f := range me.fields {
+		if f == path || strings.HasPrefix(f, path+".") || strings.HasPrefix(path, f+".") {
+			return true
+		}
+	}
+	return false
+}
+
+func (me *fieldEncryptor) Filter(filter any) (primitive.D, error) {
+	doc, err := toDocument(filter)
+	if err != nil {
+		return nil, err
+	}
+	return me.filter(doc)
+}
+
+func (me *fieldEncryptor) filter(doc primitive.D) (primitive.D, error) {
+	res := make(primitive.D, 0, len(doc))
+	for _, e := range doc {
+		switch e.Key {
+		case "$and", "$or", "$nor":
+			items, ok := e.Value.(primitive.A)
+			if !ok {
+				return nil, fmt.Errorf("%w: %s needs an array", ErrInvalidQuery, e.Key)
+			}
+			sub := make(primitive.A, len(items))
+			for i, item := range items {
+				d, ok := item.(primitive.D)
+				if !ok {
+					return nil, fmt.Errorf("%w: %s needs documents", ErrInvalidQuery, e.Key)
+				}
+				f, err := me.filter(d)
+				if err != nil {
+					return nil, err
+				}
+				sub[i] = f
+			}
+			res = append(res, primitive.E{Key: e.Key, Value: sub})
+			continue
+		}
+		deterministic, ok := me.fields[e.Key]
+		if !ok {
+			if !strings.HasPrefix(e.Key, "$") && me.touches(e.Key) {
+				return nil, fmt.Errorf("%w: %s", ErrEncryptedField, e.Key)
+			}
+			res = append(res, e)
+			continue
+		}
+		cond, err := me.condition(e.Key, deterministic, e.Value)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, primitive.E{Key: e.Key, Value: cond})
+	}
+	return res, nil
+}
+
+func (me *fieldEncryptor) condition(field string, deterministic bool, v any) (any, error) {
+	ops, isOp := isOperatorDoc(v)
+	if !isOp {
+		ops = primitive.D{{Key: "$eq", Value: v}}
+	}
+	res := make(primitive.D, 0, len(ops))
+	for _, op := range ops {
+		var values primitive.A
+		switch op.Key {
+		case "$exists":
+			res = append(res, op)
+			continue
+		case "$eq", "$ne":
+			values = primitive.A{op.Value}
+		case "$in", "$nin":
+			arr, ok := op.Value.(primitive.A)
+			if !ok {
+				return nil, fmt.Errorf("%w: %s needs an array", ErrInvalidQuery, op.Key)
+			}
+			values = arr
+		default:
+			return nil, fmt.Errorf("%w: %s on %s", ErrEncryptedField, op.Key, field)
+		}
+		if !deterministic {
+			return nil, fmt.Errorf("%w: %s is not deterministic", ErrEncryptedField, field)
+		}
+		cts := primitive.A{}
+		for _, item := range values {
+			items, err := me.operands(item)
+			if err != nil {
+				return nil, err
+			}
+			cts = append(cts, items...)
+		}
+		key := "$in"
+		if op.Key == "$ne" || op.Key == "$nin" {
+			key = "$nin"
+		}
+		res = append(res, primitive.E{Key: key, Value: cts})
+	}
+	return res, nil
+}
+
+func (me *fieldEncryptor) Update(update any) (any, error) {
+	v, err := toValue(update)
+	if err != nil {
+		return nil, err
+	}
+	doc, ok := v.(primitive.D)
+	if !ok {
+		if len(me.fields) > 0 {
+			return nil, fmt.Errorf("%w: pipeline update", ErrEncryptedField)
+		}
+		return update, nil
+	}
+	if _, isOp := isOperatorDoc(doc); !isOp {
+		return me.encryptAt(doc, "")
+	}
+	for _, op := range doc {
+		fields, _ := op.Value.(primitive.D)
+		for j, f := range fields {
+			if !me.touches(f.Key) {
+				continue
+			}
+			switch op.Key {
+			case "$set", "$setOnInsert":
+				if deterministic, ok := me.fields[f.Key]; ok {
+					if f.Value != nil && !isEncrypted(f.Value) {
+						if fields[j].Value, err = me.Encrypt(f.Value, deterministic); err != nil {
+							return nil, err
+						}
+					}
+					continue
+				}
+				sub, ok := f.Value.(primitive.D)
+				if !ok {
+					return nil, fmt.Errorf("%w: %s %s", ErrEncryptedField, op.Key, f.Key)
+				}
+				if fields[j].Value, err = me.encryptAt(sub, f.Key); err != nil {
+					return nil, err
+				}
+			case "$unset", "$rename":
+			default:
+				return nil, fmt.Errorf("%w: %s %s", ErrEncryptedField, op.Key, f.Key)
+			}
+		}
+	}
+	return doc, nil
+}
+
+func (me *fieldEncryptor) Eq(field string, v any) (primitive.M, error) {
+	return me.In(field, v)
+}
+
+func (me *fieldEncryptor) In(field string, v ...any) (primitive.M, error) {
+	if !me.fields[field] {
+		return nil, fmt.Errorf("%w: %s is not deterministic", ErrEncryptedField, field)
+	}
+	cts := primitive.A{}
+	for _, item := range v {
+		normalized, err := toValue(item)
+		if err != nil {
+			return nil, err
+		}
+		items, err := me.operands(normalized)
+		if err != nil {
+			return nil, err
+		}
+		cts = append(cts, items...)
+	}
+	return primitive.M{field: primitive.M{"$in": cts}}, nil
+}
+
+type encryptedCollection struct {
+	coll Collection
+	enc  FieldEncryptor
+}
+
+func (me *encryptedCollection) decryptCursor(ctx context.Context, cursor *mongo.Cursor, err error) (*mongo.Cursor, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	docs := make([]any, 0)
+	for cursor.Next(ctx) {
+		doc, err := me.enc.DecryptDocument(cursor.Current)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+	return mongo.NewCursorFromDocuments(docs, nil, nil)
+}
+
+func (me *encryptedCollection) Name() string {
+	return me.coll.Name()
+}
+
+func (me *encryptedCollection) InsertOne(ctx context.Context, document any, opts ...*options.InsertOneOptions) (*mongo.InsertOneResult, error) {
+	doc, err := me.enc.EncryptDocument(document)
+	if err != nil {
+		return nil, err
+	}
+	return me.coll.InsertOne(ctx, doc, opts...)
+}
+
+func (me *encryptedCollection) InsertMany(ctx context.Context, documents []any, opts ...*options.InsertManyOptions) (*mongo.InsertManyResult, error) {
+	docs := make([]any, len(documents))
+	for i, d := range documents {
+		doc, err := me.enc.EncryptDocument(d)
+		if err != nil {
+			return nil, err
+		}
+		docs[i] = doc
+	}
+	return me.coll.InsertMany(ctx, docs, opts...)
+}
+
+func (me *encryptedCollection) Find(ctx context.Context, filter any, opts ...*options.FindOptions) (*mongo.Cursor, error) {
+	f, err := me.enc.Filter(filter)
+	if err != nil {
+		return nil, err
+	}
+	cursor, err := me.coll.Find(ctx, f, opts...)
+	return me.decryptCursor(ctx, cursor, err)
+}
+
+func (me *encryptedCollection) FindOne(ctx context.Context, filter any, opts ...*options.FindOneOptions) *mongo.SingleResult {
+	f, err := me.enc.Filter(filter)
+	if err != nil {
+		return mongo.NewSingleResultFromDocument(primitive.D{}, err, nil)
+	}
+	raw, err := me.coll.FindOne(ctx, f, opts...).DecodeBytes()
+	if err != nil {
+		return mongo.NewSingleResultFromDocument(primitive.D{}, err, nil)
+	}
+	doc, err := me.enc.DecryptDocument(raw)
+	if err != nil {
+		return mongo.NewSingleResultFromDocument(primitive.D{}, err, nil)
+	}
+	return mongo.NewSingleResultFromDocument(doc, nil, nil)
+}
+
+func (me *encryptedCollection) update(ctx context.Context, filter any, update any, many bool, opts []*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	f, err := me.enc.Filter(filter)
+	if err != nil {
+		return nil, err
+	}
+	u, err := me.enc.Update(update)
+	if err != nil {
+		return nil, err
+	}
+	if many {
+		return me.coll.UpdateMany(ctx, f, u, opts...)
+	}
+	return me.coll.UpdateOne(ctx, f, u, opts...)
+}
+
+func (me *encryptedCollection) UpdateOne(ctx context.Context, filter any, update any, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	return me.update(ctx, filter, update, false, opts)
+}
+
+func (me *encryptedCollection) UpdateMany(ctx context.Context, filter any, update any, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	return me.update(ctx, filter, update, true, opts)
+}
+
+func (me *encryptedCollection) ReplaceOne(ctx context.Context, filter any, replacement any, opts ...*options.ReplaceOptions) (*mongo.UpdateResult, error) {
+	f, err := me.enc.Filter(filter)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := me.enc.EncryptDocument(replacement)
+	if err != nil {
+		return nil, err
+	}
+	return me.coll.ReplaceOne(ctx, f, doc, opts...)
+}
+
+func (me *encryptedCollection) DeleteOne(ctx context.Context, filter any, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error) {
+	f, err := me.enc.Filter(filter)
+	if err != nil {
+		return nil, err
+	}
+	return me.coll.DeleteOne(ctx, f, opts...)
+}
+
+func (me *encryptedCollection) DeleteMany(ctx context.Context, filter any, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error) {
+	f, err := me.enc.Filter(filter)
+	if err != nil {
+		return nil, err
+	}
+	return me.coll.DeleteMany(ctx, f, opts...)
+}
+
+func (me *encryptedCollection) CountDocuments(ctx context.Context, filter any, opts ...*options.CountOptions) (int64, error) {
+	f, err := me.enc.Filter(filter)
+	if err != nil {
+		return 0, err
+	}
+	return me.coll.CountDocuments(ctx, f, opts...)
+}
+
+func (me *encryptedCollection) Aggregate(ctx context.Context, pipeline any, opts ...*options.AggregateOptions) (*mongo.Cursor, error) {
+	stages, err := toPipeline(pipeline)
+	if err != nil {
+		return nil, err
+	}
+	for i, stage := range stages {
+		if stage[0].Key != "$match" {
+			continue
+		}
+		f, err := me.enc.Filter(stage[0].Value)
+		if err != nil {
+			return nil, err
+		}
+		stages[i] = primitive.D{{Key: "$match", Value: f}}
+	}
+	cursor, err := me.coll.Aggregate(ctx, stages, opts...)
+	return me.decryptCursor(ctx, cursor, err)
+}
+
+func (me *encryptedCollection) Distinct(ctx context.Context, fieldName string, filter any, opts ...*options.DistinctOptions) ([]any, error) {
+	f, err := me.enc.Filter(filter)
+	if err != nil {
+		return nil, err
+	}
+	values, err := me.coll.Distinct(ctx, fieldName, f, opts...)
+	if err != nil {
+		return nil, err
+	}
+	for i, v := range values {
+		if b, ok := v.(primitive.Binary); ok && b.Subtype == EncryptedSubtype {
+			if values[i], err = me.enc.Decrypt(b); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return values, nil
+}
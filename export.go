@@ -0,0 +1,115 @@
+package mongoutils
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+// CSVColumn csv column to document path mapping
+type CSVColumn struct {
+	// Name csv header name
+	Name string
+	// Path document field path (dot separated)
+	Path string
+}
+
+// ExportJSONL write iterator documents as JSON Lines of relaxed or canonical extended json
+//
+// returns written documents count
+func ExportJSONL(ctx context.Context, w io.Writer, it *Iterator[bson.Raw], canonical bool) (int64, error) {
+	bw := bufio.NewWriter(w)
+	count := int64(0)
+	err := Stream(ctx, it, func(doc bson.Raw) error {
+		line, err := bson.MarshalExtJSON(doc, canonical, false)
+		if err != nil {
+			return err
+		}
+		if _, err := bw.Write(line); err != nil {
+			return err
+		}
+		if err := bw.WriteByte('\n'); err != nil {
+			return err
+		}
+		count++
+		return nil
+	})
+	if err != nil {
+		return count, err
+	}
+	return count, bw.Flush()
+}
+
+// ExportCSV write iterator documents as csv with header
+//
+// ObjectID written as hex, date as RFC3339, nested documents and arrays as relaxed extended json
+// and missing or null fields as empty cell. returns written documents count
+func ExportCSV(ctx context.Context, w io.Writer, it *Iterator[bson.Raw], columns []CSVColumn) (int64, error) {
+	cw := csv.NewWriter(w)
+	header := make([]string, len(columns))
+	for i, c := range columns {
+		header[i] = c.Name
+	}
+	if err := cw.Write(header); err != nil {
+		return 0, err
+	}
+	count := int64(0)
+	err := Stream(ctx, it, func(doc bson.Raw) error {
+		row := make([]string, len(columns))
+		for i, c := range columns {
+			v, err := doc.LookupErr(strings.Split(c.Path, ".")...)
+			if err != nil {
+				continue
+			}
+			if row[i], err = csvCell(v); err != nil {
+				return fmt.Errorf("mongoutils: export %s: %w", c.Path, err)
+			}
+		}
+		count++
+		return cw.Write(row)
+	})
+	if err != nil {
+		return count, err
+	}
+	cw.Flush()
+	return count, cw.Error()
+}
+
+func csvCell(v bson.RawValue) (string, error) {
+	switch v.Type {
+	case bsontype.Null, bsontype.Undefined:
+		return "", nil
+	case bsontype.String:
+		return v.StringValue(), nil
+	case bsontype.ObjectID:
+		return v.ObjectID().Hex(), nil
+	case bsontype.DateTime:
+		return v.Time().UTC().Format(time.RFC3339Nano), nil
+	case bsontype.Boolean:
+		return strconv.FormatBool(v.Boolean()), nil
+	case bsontype.Int32:
+		return strconv.FormatInt(int64(v.Int32()), 10), nil
+	case bsontype.Int64:
+		return strconv.FormatInt(v.Int64(), 10), nil
+	case bsontype.Double:
+		return strconv.FormatFloat(v.Double(), 'f', -1, 64), nil
+	case bsontype.Decimal128:
+		return v.Decimal128().String(), nil
+	case bsontype.EmbeddedDocument, bsontype.Array:
+		// extended json writer can not write top level array, so value wrapped in document
+		res, err := bson.MarshalExtJSON(bson.D{{Key: "v", Value: v}}, false, false)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSuffix(strings.TrimPrefix(string(res), `{"v":`), "}"), nil
+	}
+	return v.String(), nil
+}
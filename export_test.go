@@ -0,0 +1,151 @@
+package mongoutils_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bopher/mongoutils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestExport(t *testing.T) {
+	ctx := context.TODO()
+	id, _ := primitive.ObjectIDFromHex("62a7a1b2c3d4e5f6a7b8c9d0")
+	date := time.Date(2022, 6, 13, 10, 0, 0, 0, time.UTC)
+	docs := []any{
+		mongoutils.Doc("_id", id, "name", "John", "age", 23, "created_at", date, "address", mongoutils.Doc("city", "London"), "tags", mongoutils.Array("a", "b")),
+		mongoutils.Doc("_id", 2, "name", "Jack"),
+	}
+	iterator := func() *mongoutils.Iterator[bson.Raw] {
+		cursor, err := mongo.NewCursorFromDocuments(docs, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return mongoutils.NewIterator[bson.Raw](ctx, cursor)
+	}
+
+	// ExportJSONL
+	buf := new(bytes.Buffer)
+	count, err := mongoutils.ExportJSONL(ctx, buf, iterator(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 || !strings.HasPrefix(buf.String(), `{"_id":{"$oid":"62a7a1b2c3d4e5f6a7b8c9d0"},"name":"John","age":{"$numberInt":"23"}`) {
+		t.Log(buf.String())
+		t.Fatal("fail ExportJSONL")
+	}
+
+	// ReadJSONL
+	lines := 0
+	err = mongoutils.ReadJSONL(strings.NewReader(buf.String()+"invalid\n"), map[string]string{"age": mongoutils.TypeLong}, func(line int, doc primitive.D, err error) error {
+		lines++
+		if line == 1 {
+			if err != nil || doc.Map()["age"] != int64(23) || doc.Map()["_id"] != id {
+				t.Fatal("fail ReadJSONL doc", err)
+			}
+		}
+		if line == 3 && err == nil {
+			t.Fatal("fail ReadJSONL invalid line")
+		}
+		return nil
+	})
+	if err != nil || lines != 3 {
+		t.Fatal("fail ReadJSONL", err)
+	}
+
+	// ExportCSV
+	columns := []mongoutils.CSVColumn{
+		{Name: "id", Path: "_id"},
+		{Name: "name", Path: "name"},
+		{Name: "city", Path: "address.city"},
+		{Name: "created", Path: "created_at"},
+		{Name: "tags", Path: "tags"},
+	}
+	buf.Reset()
+	if _, err := mongoutils.ExportCSV(ctx, buf, iterator(), columns); err != nil {
+		t.Fatal(err)
+	}
+	expected := "id,name,city,created,tags\n" +
+		"62a7a1b2c3d4e5f6a7b8c9d0,John,London,2022-06-13T10:00:00Z,\"[\"\"a\"\",\"\"b\"\"]\"\n" +
+		"2,Jack,,,\n"
+	if buf.String() != expected {
+		t.Log(buf.String())
+		t.Fatal("fail ExportCSV")
+	}
+
+	// ReadCSV
+	types := map[string]string{"_id": mongoutils.TypeObjectID, "created_at": mongoutils.TypeDate, "tags": mongoutils.TypeJSON}
+	result := make([]primitive.D, 0)
+	errs := 0
+	err = mongoutils.ReadCSV(strings.NewReader(buf.String()), columns, types, func(line int, doc primitive.D, err error) error {
+		if err != nil {
+			errs++
+			return nil
+		}
+		result = append(result, doc)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs != 1 || len(result) != 1 {
+		t.Fatal("fail ReadCSV count")
+	}
+	v, err := pretty(result[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != `[{"Key":"_id","Value":"62a7a1b2c3d4e5f6a7b8c9d0"},{"Key":"name","Value":"John"},{"Key":"address","Value":[{"Key":"city","Value":"London"}]},{"Key":"created_at","Value":"2022-06-13T10:00:00Z"},{"Key":"tags","Value":["a","b"]}]` {
+		t.Log(v)
+		t.Fatal("fail ReadCSV")
+	}
+
+	// ReadCSV malformed
+	parsed := make([]int, 0)
+	err = mongoutils.ReadCSV(strings.NewReader("name,age\na\"b,1\nJack,2\n"), nil, nil, func(line int, doc primitive.D, err error) error {
+		if err != nil {
+			parsed = append(parsed, -line)
+		} else {
+			parsed = append(parsed, line)
+		}
+		return nil
+	})
+	if err != nil || len(parsed) != 2 || parsed[0] != -2 || parsed[1] != 3 {
+		t.Log(parsed, err)
+		t.Fatal("fail ReadCSV malformed")
+	}
+
+	// Int conversion
+	input := `{"n":"3"}` + "\n" + `{"n":"3000000000"}` + "\n" + `{"n":1.5}` + "\n" + `{"n":4.0}` + "\n"
+	converted := make([]any, 0)
+	err = mongoutils.ReadJSONL(strings.NewReader(input), map[string]string{"n": mongoutils.TypeInt}, func(line int, doc primitive.D, err error) error {
+		if err != nil {
+			converted = append(converted, nil)
+		} else {
+			converted = append(converted, doc.Map()["n"])
+		}
+		return nil
+	})
+	if err != nil || len(converted) != 4 || converted[0] != int32(3) || converted[1] != nil || converted[2] != nil || converted[3] != int32(4) {
+		t.Fatal("fail TypeInt conversion", converted, err)
+	}
+
+	// Import
+	coll := mongoutils.NewMemoryCollection("people")
+	res, err := mongoutils.ImportJSONL(ctx, strings.NewReader(`{"_id":1}`+"\n"+`{"_id":1}`+"\n"+`{"_id":2}`+"\n"), coll, mongoutils.ImportConfig{BatchSize: 2})
+	if err != nil || res.Inserted != 2 || len(res.Errors) != 1 || res.Errors[0].Line != 2 {
+		t.Fatal("fail ImportJSONL insert", res, err)
+	}
+	res, err = mongoutils.ImportJSONL(ctx, strings.NewReader(`{"_id":2,"name":"B"}`+"\n"+`{"_id":3,"name":"C"}`+"\n"), coll, mongoutils.ImportConfig{UpsertKeys: []string{"_id"}})
+	if err != nil || res.Matched != 1 || res.Upserted != 1 || len(res.Errors) != 0 {
+		t.Fatal("fail ImportJSONL upsert", res, err)
+	}
+	if n, _ := coll.CountDocuments(ctx, mongoutils.Map("name", mongoutils.Map("$exists", true))); n != 2 {
+		t.Fatal("fail ImportJSONL upsert count", n)
+	}
+}
@@ -0,0 +1,184 @@
+package mongoutils
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// BeforeInserter model with BeforeInsert hook
+type BeforeInserter interface {
+	BeforeInsert(ctx context.Context) error
+}
+
+// AfterInserter model with AfterInsert hook
+type AfterInserter interface {
+	AfterInsert(ctx context.Context) error
+}
+
+// BeforeUpdater model with BeforeUpdate hook
+type BeforeUpdater interface {
+	BeforeUpdate(ctx context.Context) error
+}
+
+// AfterUpdater model with AfterUpdate hook
+type AfterUpdater interface {
+	AfterUpdate(old any, ctx context.Context) error
+}
+
+// BeforeDeleter model with BeforeDelete hook
+type BeforeDeleter interface {
+	BeforeDelete(ctx context.Context) error
+}
+
+// AfterDeleter model with AfterDelete hook
+type AfterDeleter interface {
+	AfterDelete(ctx context.Context) error
+}
+
+// legacy hook signatures without error, still called by model helpers
+type legacyBeforeInserter interface {
+	BeforeInsert(ctx context.Context)
+}
+
+type legacyAfterInserter interface {
+	AfterInsert(ctx context.Context)
+}
+
+type legacyBeforeUpdater interface {
+	BeforeUpdate(ctx context.Context)
+}
+
+type legacyAfterUpdater interface {
+	AfterUpdate(old any, ctx context.Context)
+}
+
+type legacyBeforeDeleter interface {
+	BeforeDelete(ctx context.Context)
+}
+
+type legacyAfterDeleter interface {
+	AfterDelete(ctx context.Context)
+}
+
+type cleaner interface {
+	Cleanup()
+}
+
+type insertPreparer interface {
+	PrepareInsert()
+}
+
+type updatePreparer interface {
+	PrepareUpdate(ghost bool)
+}
+
+// InsertModel prepare model and insert to collection
+//
+// Cleanup, PrepareInsert, BeforeInsert and ValidateModel called before insert and AfterInsert called after insert.
+// BeforeInsert or validation error abort insert, AfterInsert error abort transaction if ctx has running transaction
+func InsertModel(ctx context.Context, coll *mongo.Collection, v any, opts ...*options.InsertOneOptions) (*mongo.InsertOneResult, error) {
+	if m, ok := v.(cleaner); ok {
+		m.Cleanup()
+	}
+	if m, ok := v.(insertPreparer); ok {
+		m.PrepareInsert()
+	}
+	if m, ok := v.(BeforeInserter); ok {
+		if err := m.BeforeInsert(ctx); err != nil {
+			return nil, err
+		}
+	} else if m, ok := v.(legacyBeforeInserter); ok {
+		m.BeforeInsert(ctx)
+	}
+	if err := ValidateModel(ctx, v); err != nil {
+		return nil, err
+	}
+	res, err := coll.InsertOne(ctx, v, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if m, ok := v.(AfterInserter); ok {
+		if err := m.AfterInsert(ctx); err != nil {
+			return res, abortTx(ctx, err)
+		}
+	} else if m, ok := v.(legacyAfterInserter); ok {
+		m.AfterInsert(ctx)
+	}
+	return res, nil
+}
+
+// UpdateModel prepare model and replace document matched by filter
+//
+// Cleanup, PrepareUpdate, BeforeUpdate and ValidateModel called before update and AfterUpdate called after update.
+// BeforeUpdate or validation error abort update, AfterUpdate error abort transaction if ctx has running transaction.
+// in ghost mode updated_at field not changed
+func UpdateModel(ctx context.Context, coll *mongo.Collection, filter any, v any, old any, ghost bool, opts ...*options.ReplaceOptions) (*mongo.UpdateResult, error) {
+	if m, ok := v.(cleaner); ok {
+		m.Cleanup()
+	}
+	if m, ok := v.(updatePreparer); ok {
+		m.PrepareUpdate(ghost)
+	}
+	if m, ok := v.(BeforeUpdater); ok {
+		if err := m.BeforeUpdate(ctx); err != nil {
+			return nil, err
+		}
+	} else if m, ok := v.(legacyBeforeUpdater); ok {
+		m.BeforeUpdate(ctx)
+	}
+	if err := ValidateModel(ctx, v); err != nil {
+		return nil, err
+	}
+	res, err := coll.ReplaceOne(ctx, filter, v, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if m, ok := v.(AfterUpdater); ok {
+		if err := m.AfterUpdate(old, ctx); err != nil {
+			return res, abortTx(ctx, err)
+		}
+	} else if m, ok := v.(legacyAfterUpdater); ok {
+		m.AfterUpdate(old, ctx)
+	}
+	return res, nil
+}
+
+// DeleteModel delete document matched by filter
+//
+// BeforeDelete called before delete and AfterDelete called after delete.
+// BeforeDelete error abort delete, AfterDelete error abort transaction if ctx has running transaction
+func DeleteModel(ctx context.Context, coll *mongo.Collection, filter any, v any, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error) {
+	if m, ok := v.(BeforeDeleter); ok {
+		if err := m.BeforeDelete(ctx); err != nil {
+			return nil, err
+		}
+	} else if m, ok := v.(legacyBeforeDeleter); ok {
+		m.BeforeDelete(ctx)
+	}
+	res, err := coll.DeleteOne(ctx, filter, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if m, ok := v.(AfterDeleter); ok {
+		if err := m.AfterDelete(ctx); err != nil {
+			return res, abortTx(ctx, err)
+		}
+	} else if m, ok := v.(legacyAfterDeleter); ok {
+		m.AfterDelete(ctx)
+	}
+	return res, nil
+}
+
+// abortTx abort running transaction of context session and return err
+func abortTx(ctx context.Context, err error) error {
+	sess := mongo.SessionFromContext(ctx)
+	if sess == nil {
+		return err
+	}
+	if txRunning(sess) {
+		_ = sess.AbortTransaction(ctx)
+	}
+	return err
+}
@@ -0,0 +1,78 @@
+package mongoutils_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/bopher/mongoutils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var errHookAbort = errors.New("hook abort")
+
+type hookModel struct {
+	mongoutils.Model `bson:",inline"`
+	Name             string `bson:"name"`
+	calls            []string
+}
+
+func (me *hookModel) BeforeInsert(ctx context.Context) error {
+	me.calls = append(me.calls, "BeforeInsert")
+	return errHookAbort
+}
+
+func (me *hookModel) BeforeUpdate(ctx context.Context) error {
+	me.calls = append(me.calls, "BeforeUpdate")
+	return errHookAbort
+}
+
+func (me *hookModel) BeforeDelete(ctx context.Context) error {
+	me.calls = append(me.calls, "BeforeDelete")
+	return errHookAbort
+}
+
+type legacyHookModel struct {
+	mongoutils.Model `bson:",inline"`
+	Name             string `bson:"name"`
+	called           bool
+}
+
+func (me *legacyHookModel) BeforeInsert(ctx context.Context) {
+	me.called = true
+}
+
+func TestModelHooks(t *testing.T) {
+	ctx := context.TODO()
+	// client never connected, any write returns client error instead of hook error
+	client, err := mongo.NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	coll := client.Database("test").Collection("hooks")
+
+	m := new(hookModel)
+	if _, err := mongoutils.InsertModel(ctx, coll, m); !errors.Is(err, errHookAbort) {
+		t.Fatal("fail BeforeInsert abort", err)
+	}
+	if m.CreatedAt.IsZero() {
+		t.Fatal("fail PrepareInsert before hook")
+	}
+	if _, err := mongoutils.UpdateModel(ctx, coll, primitive.M{}, m, nil, false); !errors.Is(err, errHookAbort) {
+		t.Fatal("fail BeforeUpdate abort", err)
+	}
+	if _, err := mongoutils.DeleteModel(ctx, coll, primitive.M{}, m); !errors.Is(err, errHookAbort) {
+		t.Fatal("fail BeforeDelete abort", err)
+	}
+	if len(m.calls) != 3 {
+		t.Fatal("fail hook calls", m.calls)
+	}
+
+	// legacy signature still called, insert fails on client
+	legacy := new(legacyHookModel)
+	_, err = mongoutils.InsertModel(ctx, coll, legacy)
+	if err == nil || !legacy.called {
+		t.Fatal("fail legacy hook", err)
+	}
+}
@@ -0,0 +1,448 @@
+package mongoutils
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Import type hints
+const (
+	TypeString   = "string"
+	TypeInt      = "int"
+	TypeLong     = "long"
+	TypeDouble   = "double"
+	TypeBool     = "bool"
+	TypeObjectID = "objectid"
+	TypeDate     = "date"
+	TypeDecimal  = "decimal"
+	// TypeJSON relaxed extended json value (e.g exported nested document or array)
+	TypeJSON = "json"
+)
+
+// ImportConfig import options
+type ImportConfig struct {
+	// BatchSize insert or upsert batch size (default 1000)
+	BatchSize int
+	// UpsertKeys replace documents matched by key fields with upsert, insert if empty
+	UpsertKeys []string
+	// Types type hints by field path
+	Types map[string]string
+	// Columns csv header to field path mapping, header names used as path if empty
+	Columns []CSVColumn
+}
+
+// ImportLineError import error of line
+type ImportLineError struct {
+	Line int
+	Err  error
+}
+
+// Error implement error interface
+func (me ImportLineError) Error() string {
+	return fmt.Sprintf("line %d: %v", me.Line, me.Err)
+}
+
+// ImportResult import result
+type ImportResult struct {
+	Inserted int64
+	Matched  int64
+	Modified int64
+	Upserted int64
+	// Errors per line parse, conversion and write errors
+	Errors []ImportLineError
+}
+
+// ReadJSONL read JSON Lines of extended json documents and apply type hints
+//
+// fn called for each non empty line with parse error if line is invalid, returning error stop reading
+func ReadJSONL(r io.Reader, types map[string]string, fn func(line int, doc primitive.D, err error) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		var doc primitive.D
+		err := bson.UnmarshalExtJSON(data, false, &doc)
+		if err == nil {
+			err = applyTypes(doc, types)
+		}
+		if err := fn(line, doc, err); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// ReadCSV read csv with header and apply type hints
+//
+// columns map header names to field path (header names used as path if empty). empty cells ignored.
+// fields without type hint read as string. fn called for each record with conversion error, returning error stop reading
+func ReadCSV(r io.Reader, columns []CSVColumn, types map[string]string, fn func(line int, doc primitive.D, err error) error) error {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		return err
+	}
+	paths := make([]string, len(header))
+	for i, h := range header {
+		paths[i] = h
+		for _, c := range columns {
+			if c.Name == h {
+				paths[i] = c.Path
+				break
+			}
+		}
+	}
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			var perr *csv.ParseError
+			if errors.As(err, &perr) {
+				if err := fn(perr.StartLine, nil, err); err != nil {
+					return err
+				}
+				continue
+			}
+			return err
+		}
+		line, _ := cr.FieldPos(0)
+		doc := primitive.D{}
+		var convErr error
+		for i, cell := range record {
+			if i >= len(paths) || cell == "" || paths[i] == "" {
+				continue
+			}
+			var v any = cell
+			if typ, ok := types[paths[i]]; ok {
+				if v, err = convertType(cell, typ); err != nil {
+					convErr = fmt.Errorf("%s: %w", paths[i], err)
+					break
+				}
+			}
+			doc = setPath(doc, strings.Split(paths[i], "."), v)
+		}
+		if err := fn(line, doc, convErr); err != nil {
+			return err
+		}
+	}
+}
+
+// ImportJSONL import JSON Lines to collection, see ReadJSONL
+func ImportJSONL(ctx context.Context, r io.Reader, coll Collection, cfg ImportConfig) (ImportResult, error) {
+	imp := newImporter(coll, cfg)
+	err := ReadJSONL(r, cfg.Types, func(line int, doc primitive.D, err error) error {
+		return imp.add(ctx, line, doc, err)
+	})
+	return imp.finish(ctx, err)
+}
+
+// ImportCSV import csv to collection, see ReadCSV
+func ImportCSV(ctx context.Context, r io.Reader, coll Collection, cfg ImportConfig) (ImportResult, error) {
+	imp := newImporter(coll, cfg)
+	err := ReadCSV(r, cfg.Columns, cfg.Types, func(line int, doc primitive.D, err error) error {
+		return imp.add(ctx, line, doc, err)
+	})
+	return imp.finish(ctx, err)
+}
+
+// bulkWriter collection with bulk write support (e.g. *mongo.Collection)
+type bulkWriter interface {
+	BulkWrite(ctx context.Context, models []mongo.WriteModel, opts ...*options.BulkWriteOptions) (*mongo.BulkWriteResult, error)
+}
+
+type importer struct {
+	coll    Collection
+	cfg     ImportConfig
+	lines   []int
+	docs    []any
+	filters []primitive.D
+	result  ImportResult
+}
+
+func newImporter(coll Collection, cfg ImportConfig) *importer {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 1000
+	}
+	return &importer{coll: coll, cfg: cfg}
+}
+
+func (me *importer) add(ctx context.Context, line int, doc primitive.D, err error) error {
+	if err != nil {
+		me.result.Errors = append(me.result.Errors, ImportLineError{Line: line, Err: err})
+		return nil
+	}
+	if len(me.cfg.UpsertKeys) > 0 {
+		filter := primitive.D{}
+		for _, k := range me.cfg.UpsertKeys {
+			v, ok := getPath(doc, strings.Split(k, "."))
+			if !ok {
+				me.result.Errors = append(me.result.Errors, ImportLineError{Line: line, Err: fmt.Errorf("missing upsert key %s", k)})
+				return nil
+			}
+			filter = append(filter, primitive.E{Key: k, Value: v})
+		}
+		me.filters = append(me.filters, filter)
+	}
+	me.docs = append(me.docs, doc)
+	me.lines = append(me.lines, line)
+	if len(me.docs) >= me.cfg.BatchSize {
+		return me.flush(ctx)
+	}
+	return nil
+}
+
+// flush write batch with single bulk write if supported by collection,
+// otherwise with unordered InsertMany or ReplaceOne per upserted document
+func (me *importer) flush(ctx context.Context) error {
+	if len(me.docs) == 0 {
+		return nil
+	}
+	defer func() {
+		me.lines = me.lines[:0]
+		me.docs = me.docs[:0]
+		me.filters = me.filters[:0]
+	}()
+	if bw, ok := me.coll.(bulkWriter); ok {
+		models := make([]mongo.WriteModel, len(me.docs))
+		for i, doc := range me.docs {
+			if len(me.filters) > 0 {
+				models[i] = mongo.NewReplaceOneModel().SetFilter(me.filters[i]).SetReplacement(doc).SetUpsert(true)
+			} else {
+				models[i] = mongo.NewInsertOneModel().SetDocument(doc)
+			}
+		}
+		res, err := bw.BulkWrite(ctx, models, BulkWriteOption(false))
+		if res != nil {
+			me.result.Inserted += res.InsertedCount
+			me.result.Matched += res.MatchedCount
+			me.result.Modified += res.ModifiedCount
+			me.result.Upserted += res.UpsertedCount
+		}
+		return me.writeErrors(err)
+	}
+	if len(me.filters) == 0 {
+		res, err := me.coll.InsertMany(ctx, me.docs, options.InsertMany().SetOrdered(false))
+		if res != nil {
+			me.result.Inserted += int64(len(res.InsertedIDs))
+		}
+		return me.writeErrors(err)
+	}
+	for i, doc := range me.docs {
+		res, err := me.coll.ReplaceOne(ctx, me.filters[i], doc, options.Replace().SetUpsert(true))
+		if res != nil {
+			me.result.Matched += res.MatchedCount
+			me.result.Modified += res.ModifiedCount
+			me.result.Upserted += res.UpsertedCount
+		}
+		var we mongo.WriteException
+		if errors.As(err, &we) && we.WriteConcernError == nil {
+			me.result.Errors = append(me.result.Errors, ImportLineError{Line: me.lines[i], Err: err})
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeErrors record bulk write errors as line errors, other errors returned
+func (me *importer) writeErrors(err error) error {
+	if err == nil {
+		return nil
+	}
+	var bwe mongo.BulkWriteException
+	if !errors.As(err, &bwe) || bwe.WriteConcernError != nil {
+		return err
+	}
+	for _, we := range bwe.WriteErrors {
+		line := 0
+		if we.Index >= 0 && we.Index < len(me.lines) {
+			line = me.lines[we.Index]
+		}
+		me.result.Errors = append(me.result.Errors, ImportLineError{Line: line, Err: we})
+	}
+	return nil
+}
+
+func (me *importer) finish(ctx context.Context, err error) (ImportResult, error) {
+	if err != nil {
+		return me.result, err
+	}
+	return me.result, me.flush(ctx)
+}
+
+// applyTypes convert document fields by type hints
+func applyTypes(doc primitive.D, types map[string]string) error {
+	for path, typ := range types {
+		parts := strings.Split(path, ".")
+		v, ok := getPath(doc, parts)
+		if !ok || v == nil {
+			continue
+		}
+		converted, err := convertType(v, typ)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		setPath(doc, parts, converted)
+	}
+	return nil
+}
+
+// convertType convert value by type hint
+func convertType(v any, typ string) (any, error) {
+	s, isString := v.(string)
+	switch typ {
+	case TypeString:
+		if isString {
+			return s, nil
+		}
+		return fmt.Sprint(v), nil
+	case TypeInt, TypeLong:
+		var n int64
+		var err error
+		switch val := v.(type) {
+		case string:
+			n, err = strconv.ParseInt(strings.TrimSpace(val), 10, 64)
+		case int32:
+			n = int64(val)
+		case int64:
+			n = val
+		case float64:
+			if val != math.Trunc(val) || val < math.MinInt64 || val >= math.MaxInt64 {
+				return nil, fmt.Errorf("cannot convert %v to %s without loss", val, typ)
+			}
+			n = int64(val)
+		default:
+			err = fmt.Errorf("cannot convert %T to %s", v, typ)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if typ == TypeInt {
+			if n < math.MinInt32 || n > math.MaxInt32 {
+				return nil, fmt.Errorf("%d out of %s range", n, typ)
+			}
+			return int32(n), nil
+		}
+		return n, nil
+	case TypeDouble:
+		switch val := v.(type) {
+		case string:
+			return strconv.ParseFloat(strings.TrimSpace(val), 64)
+		case int32:
+			return float64(val), nil
+		case int64:
+			return float64(val), nil
+		case float64:
+			return val, nil
+		}
+	case TypeBool:
+		switch val := v.(type) {
+		case string:
+			return strconv.ParseBool(strings.TrimSpace(val))
+		case bool:
+			return val, nil
+		}
+	case TypeObjectID:
+		switch val := v.(type) {
+		case string:
+			return primitive.ObjectIDFromHex(strings.TrimSpace(val))
+		case primitive.ObjectID:
+			return val, nil
+		}
+	case TypeDate:
+		switch val := v.(type) {
+		case string:
+			for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02"} {
+				if t, err := time.Parse(layout, strings.TrimSpace(val)); err == nil {
+					return primitive.NewDateTimeFromTime(t), nil
+				}
+			}
+			return nil, fmt.Errorf("invalid date %q", val)
+		case primitive.DateTime:
+			return val, nil
+		case int64:
+			return primitive.DateTime(val), nil
+		}
+	case TypeDecimal:
+		switch val := v.(type) {
+		case primitive.Decimal128:
+			return val, nil
+		default:
+			return primitive.ParseDecimal128(strings.TrimSpace(fmt.Sprint(val)))
+		}
+	case TypeJSON:
+		if !isString {
+			return v, nil
+		}
+		var wrapper primitive.D
+		if err := bson.UnmarshalExtJSON([]byte(`{"v":`+s+`}`), false, &wrapper); err != nil {
+			return nil, err
+		}
+		return wrapper[0].Value, nil
+	default:
+		return nil, fmt.Errorf("unknown type hint %q", typ)
+	}
+	return nil, fmt.Errorf("cannot convert %T to %s", v, typ)
+}
+
+// getPath get nested value of document
+func getPath(doc primitive.D, parts []string) (any, bool) {
+	for _, e := range doc {
+		if e.Key != parts[0] {
+			continue
+		}
+		if len(parts) == 1 {
+			return e.Value, true
+		}
+		if sub, ok := e.Value.(primitive.D); ok {
+			return getPath(sub, parts[1:])
+		}
+		return nil, false
+	}
+	return nil, false
+}
+
+// setPath set nested value of document, missing nested documents created
+func setPath(doc primitive.D, parts []string, v any) primitive.D {
+	for i, e := range doc {
+		if e.Key != parts[0] {
+			continue
+		}
+		if len(parts) == 1 {
+			doc[i].Value = v
+		} else {
+			sub, _ := e.Value.(primitive.D)
+			doc[i].Value = setPath(sub, parts[1:], v)
+		}
+		return doc
+	}
+	if len(parts) == 1 {
+		return append(doc, primitive.E{Key: parts[0], Value: v})
+	}
+	return append(doc, primitive.E{Key: parts[0], Value: setPath(primitive.D{}, parts[1:], v)})
+}
@@ -0,0 +1,354 @@
+package mongoutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// IndexDef index definition
+type IndexDef struct {
+	Name   string
+	Keys   primitive.D
+	Unique bool
+	Sparse bool
+	// TTL expireAfterSeconds, nil for non ttl index
+	TTL *int32
+}
+
+// IndexPlan difference between declared and existing indexes
+type IndexPlan struct {
+	// Create missing indexes
+	Create []IndexDef
+	// Rebuild indexes with changed options
+	Rebuild []IndexDef
+	// Unmanaged existing index names not declared in model
+	Unmanaged []string
+}
+
+// IsEmpty check if plan has no change
+func (me IndexPlan) IsEmpty() bool {
+	return len(me.Create) == 0 && len(me.Rebuild) == 0 && len(me.Unmanaged) == 0
+}
+
+// IndexesFor parse index definitions from mongo struct tag
+//
+// tag options: index, index=group (compound index by group name in fields order),
+// desc, unique, sparse, ttl=seconds, text (all text fields merged to one index) and 2dsphere
+func IndexesFor(model any) ([]IndexDef, error) {
+	if model == nil {
+		return nil, errors.New("mongoutils: nil model passed to IndexesFor")
+	}
+	t := indirectType(reflect.TypeOf(model))
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("mongoutils: IndexesFor expect struct, %s given", t)
+	}
+	res := make([]IndexDef, 0)
+	groups := make(map[string]int)
+	var text *IndexDef
+	visiting := make(map[reflect.Type]bool)
+	var collect func(t reflect.Type, prefix string) error
+	collect = func(t reflect.Type, prefix string) error {
+		// self referencing types collected once
+		if visiting[t] {
+			return nil
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name, inline, skip := bsonField(f)
+			if skip {
+				continue
+			}
+			ft := indirectType(f.Type)
+			if inline {
+				if ft.Kind() == reflect.Struct {
+					if err := collect(ft, prefix); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			path := joinPath(prefix, name)
+			if tag, ok := f.Tag.Lookup("mongo"); ok && tag != "" {
+				idx, group, err := parseIndexTag(path, tag)
+				if err != nil {
+					return err
+				}
+				if idx.Keys[0].Value == "text" {
+					if text == nil {
+						text = &IndexDef{}
+					}
+					text.Keys = append(text.Keys, idx.Keys...)
+					continue
+				}
+				if group == "" {
+					res = append(res, idx)
+					continue
+				}
+				if pos, ok := groups[group]; ok {
+					res[pos].Keys = append(res[pos].Keys, idx.Keys...)
+					res[pos].Unique = res[pos].Unique || idx.Unique
+					res[pos].Sparse = res[pos].Sparse || idx.Sparse
+					if idx.TTL != nil {
+						res[pos].TTL = idx.TTL
+					}
+				} else {
+					groups[group] = len(res)
+					res = append(res, idx)
+				}
+			}
+			if ft.Kind() == reflect.Struct && !isLeafStruct(ft) {
+				if err := collect(ft, path); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	if err := collect(t, ""); err != nil {
+		return nil, err
+	}
+	if text != nil {
+		res = append(res, *text)
+	}
+	for i := range res {
+		res[i].Name = indexName(res[i].Keys)
+	}
+	return res, nil
+}
+
+// PlanIndexes compare declared and existing indexes
+//
+// indexes compared by keys, _id index ignored
+func PlanIndexes(declared []IndexDef, existing []IndexDef) IndexPlan {
+	plan := IndexPlan{}
+	current := make(map[string]IndexDef)
+	for _, idx := range existing {
+		if idx.Name == "_id_" {
+			continue
+		}
+		current[indexKey(idx.Keys)] = idx
+	}
+	for _, idx := range declared {
+		key := indexKey(idx.Keys)
+		old, ok := current[key]
+		if !ok {
+			plan.Create = append(plan.Create, idx)
+			continue
+		}
+		delete(current, key)
+		if old.Unique != idx.Unique || old.Sparse != idx.Sparse || !sameTTL(old.TTL, idx.TTL) {
+			idx.Name = old.Name
+			plan.Rebuild = append(plan.Rebuild, idx)
+		}
+	}
+	for _, idx := range current {
+		plan.Unmanaged = append(plan.Unmanaged, idx.Name)
+	}
+	sort.Strings(plan.Unmanaged)
+	return plan
+}
+
+// ParseIndexSpec parse index definition from listIndexes result document
+func ParseIndexSpec(doc primitive.D) IndexDef {
+	spec := doc.Map()
+	res := IndexDef{}
+	res.Name, _ = spec["name"].(string)
+	res.Unique, _ = spec["unique"].(bool)
+	res.Sparse, _ = spec["sparse"].(bool)
+	if v, ok := toInt64(spec["expireAfterSeconds"]); ok {
+		ttl := int32(v)
+		res.TTL = &ttl
+	}
+	keys := toD(spec["key"])
+	for _, e := range keys {
+		switch e.Key {
+		case "_fts":
+			// text index keys stored in weights
+			weights := toD(spec["weights"])
+			sort.Slice(weights, func(i, j int) bool { return weights[i].Key < weights[j].Key })
+			for _, w := range weights {
+				res.Keys = append(res.Keys, primitive.E{Key: w.Key, Value: "text"})
+			}
+		case "_ftsx":
+		default:
+			if v, ok := toInt64(e.Value); ok {
+				res.Keys = append(res.Keys, primitive.E{Key: e.Key, Value: int32(v)})
+			} else {
+				res.Keys = append(res.Keys, e)
+			}
+		}
+	}
+	return res
+}
+
+// EnsureIndexes sync collection indexes with model mongo tags
+//
+// missing indexes created. if drop is true, unmanaged indexes dropped and indexes with changed options rebuilt,
+// otherwise only reported in plan
+func EnsureIndexes(ctx context.Context, coll *mongo.Collection, model any, drop bool) (IndexPlan, error) {
+	declared, err := IndexesFor(model)
+	if err != nil {
+		return IndexPlan{}, err
+	}
+	cursor, err := coll.Indexes().List(ctx)
+	if err != nil {
+		return IndexPlan{}, err
+	}
+	specs := make([]primitive.D, 0)
+	if err := cursor.All(ctx, &specs); err != nil {
+		return IndexPlan{}, err
+	}
+	existing := make([]IndexDef, len(specs))
+	for i, spec := range specs {
+		existing[i] = ParseIndexSpec(spec)
+	}
+	plan := PlanIndexes(declared, existing)
+	if drop {
+		for _, name := range plan.Unmanaged {
+			if _, err := coll.Indexes().DropOne(ctx, name); err != nil {
+				return plan, err
+			}
+		}
+		for _, idx := range plan.Rebuild {
+			if _, err := coll.Indexes().DropOne(ctx, idx.Name); err != nil {
+				return plan, err
+			}
+		}
+	}
+	models := make([]mongo.IndexModel, 0)
+	for _, idx := range plan.Create {
+		models = append(models, idx.Model())
+	}
+	if drop {
+		for _, idx := range plan.Rebuild {
+			models = append(models, idx.Model())
+		}
+	}
+	if len(models) > 0 {
+		if _, err := coll.Indexes().CreateMany(ctx, models); err != nil {
+			return plan, err
+		}
+	}
+	return plan, nil
+}
+
+// Model generate mongo index model
+func (me IndexDef) Model() mongo.IndexModel {
+	opt := options.Index().SetName(me.Name)
+	if me.Unique {
+		opt.SetUnique(true)
+	}
+	if me.Sparse {
+		opt.SetSparse(true)
+	}
+	if me.TTL != nil {
+		opt.SetExpireAfterSeconds(*me.TTL)
+	}
+	return mongo.IndexModel{Keys: me.Keys, Options: opt}
+}
+
+func parseIndexTag(path string, tag string) (IndexDef, string, error) {
+	idx := IndexDef{}
+	group := ""
+	var direction any = int32(1)
+	for _, opt := range strings.Split(tag, ",") {
+		name, param, _ := strings.Cut(strings.TrimSpace(opt), "=")
+		switch name {
+		case "index":
+			group = param
+		case "desc":
+			direction = int32(-1)
+		case "unique":
+			idx.Unique = true
+		case "sparse":
+			idx.Sparse = true
+		case "ttl":
+			v, err := strconv.ParseInt(param, 10, 32)
+			if err != nil {
+				return idx, "", fmt.Errorf("mongoutils: invalid ttl %q for %s", param, path)
+			}
+			ttl := int32(v)
+			idx.TTL = &ttl
+		case "text", "2dsphere":
+			direction = name
+		case "":
+		default:
+			return idx, "", fmt.Errorf("mongoutils: unknown index option %q for %s", name, path)
+		}
+	}
+	idx.Keys = primitive.D{{Key: path, Value: direction}}
+	return idx, group, nil
+}
+
+// indexName generate default mongo index name
+func indexName(keys primitive.D) string {
+	parts := make([]string, 0, len(keys)*2)
+	for _, k := range keys {
+		parts = append(parts, k.Key, fmt.Sprint(k.Value))
+	}
+	return strings.Join(parts, "_")
+}
+
+// indexKey generate comparable key of index keys
+func indexKey(keys primitive.D) string {
+	parts := make([]string, 0, len(keys))
+	text := make([]string, 0)
+	for _, k := range keys {
+		if k.Value == "text" {
+			text = append(text, k.Key)
+			continue
+		}
+		parts = append(parts, k.Key+":"+fmt.Sprint(k.Value))
+	}
+	if len(text) > 0 {
+		sort.Strings(text)
+		parts = append(parts, "text:"+strings.Join(text, "|"))
+	}
+	return strings.Join(parts, ",")
+}
+
+func sameTTL(a, b *int32) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func toInt64(v any) (int64, bool) {
+	switch n := v.(type) {
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	case int:
+		return int64(n), true
+	case float64:
+		return int64(n), true
+	}
+	return 0, false
+}
+
+func toD(v any) primitive.D {
+	switch d := v.(type) {
+	case primitive.D:
+		return d
+	case primitive.M:
+		res := make(primitive.D, 0, len(d))
+		for k, v := range d {
+			res = append(res, primitive.E{Key: k, Value: v})
+		}
+		return res
+	}
+	return nil
+}
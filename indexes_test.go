@@ -0,0 +1,83 @@
+package mongoutils_test
+
+import (
+	"testing"
+
+	"github.com/bopher/mongoutils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type indexLocation struct {
+	Point primitive.M `bson:"point" mongo:"2dsphere"`
+}
+
+type indexPerson struct {
+	mongoutils.Model `bson:",inline"`
+	Email            string        `bson:"email" mongo:"unique"`
+	Company          string        `bson:"company" mongo:"index=company_name"`
+	Name             string        `bson:"name" mongo:"index=company_name,desc"`
+	Title            string        `bson:"title" mongo:"text"`
+	Bio              string        `bson:"bio" mongo:"text"`
+	Token            string        `bson:"token" mongo:"ttl=3600,sparse"`
+	Location         indexLocation `bson:"location"`
+}
+
+type indexNode struct {
+	Name   string     `bson:"name" mongo:"index"`
+	Parent *indexNode `bson:"parent"`
+}
+
+func TestIndexes(t *testing.T) {
+	declared, err := mongoutils.IndexesFor(indexPerson{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := pretty(declared)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != `[{"Name":"email_1","Keys":[{"Key":"email","Value":1}],"Unique":true,"Sparse":false,"TTL":null},`+
+		`{"Name":"company_1_name_-1","Keys":[{"Key":"company","Value":1},{"Key":"name","Value":-1}],"Unique":false,"Sparse":false,"TTL":null},`+
+		`{"Name":"token_1","Keys":[{"Key":"token","Value":1}],"Unique":false,"Sparse":true,"TTL":3600},`+
+		`{"Name":"location.point_2dsphere","Keys":[{"Key":"location.point","Value":"2dsphere"}],"Unique":false,"Sparse":false,"TTL":null},`+
+		`{"Name":"title_text_bio_text","Keys":[{"Key":"title","Value":"text"},{"Key":"bio","Value":"text"}],"Unique":false,"Sparse":false,"TTL":null}]` {
+		t.Log(v)
+		t.Fatal("fail IndexesFor")
+	}
+
+	// self referencing model
+	if nodes, err := mongoutils.IndexesFor(indexNode{}); err != nil || len(nodes) != 1 || nodes[0].Name != "name_1" {
+		t.Fatal("fail IndexesFor recursive", nodes, err)
+	}
+
+	existing := []mongoutils.IndexDef{
+		mongoutils.ParseIndexSpec(primitive.D{{Key: "name", Value: "_id_"}, {Key: "key", Value: primitive.D{{Key: "_id", Value: int32(1)}}}}),
+		mongoutils.ParseIndexSpec(primitive.D{{Key: "name", Value: "email_1"}, {Key: "key", Value: primitive.D{{Key: "email", Value: int32(1)}}}}),
+		mongoutils.ParseIndexSpec(primitive.D{{Key: "name", Value: "token_1"}, {Key: "key", Value: primitive.D{{Key: "token", Value: 1.0}}}, {Key: "sparse", Value: true}, {Key: "expireAfterSeconds", Value: int32(3600)}}),
+		mongoutils.ParseIndexSpec(primitive.D{
+			{Key: "name", Value: "title_text_bio_text"},
+			{Key: "key", Value: primitive.D{{Key: "_fts", Value: "text"}, {Key: "_ftsx", Value: int32(1)}}},
+			{Key: "weights", Value: primitive.D{{Key: "bio", Value: int32(1)}, {Key: "title", Value: int32(1)}}},
+		}),
+		mongoutils.ParseIndexSpec(primitive.D{{Key: "name", Value: "legacy_1"}, {Key: "key", Value: primitive.D{{Key: "legacy", Value: int32(1)}}}}),
+	}
+	plan := mongoutils.PlanIndexes(declared, existing)
+	if len(plan.Create) != 2 || plan.Create[0].Name != "company_1_name_-1" || plan.Create[1].Name != "location.point_2dsphere" {
+		t.Log(plan.Create)
+		t.Fatal("fail PlanIndexes create")
+	}
+	if len(plan.Rebuild) != 1 || plan.Rebuild[0].Name != "email_1" || !plan.Rebuild[0].Unique {
+		t.Log(plan.Rebuild)
+		t.Fatal("fail PlanIndexes rebuild")
+	}
+	if len(plan.Unmanaged) != 1 || plan.Unmanaged[0] != "legacy_1" {
+		t.Log(plan.Unmanaged)
+		t.Fatal("fail PlanIndexes unmanaged")
+	}
+
+	if _, err := mongoutils.IndexesFor(struct {
+		Name string `bson:"name" mongo:"ttl=abc"`
+	}{}); err == nil {
+		t.Fatal("fail IndexesFor invalid tag")
+	}
+}
@@ -1,10 +1,15 @@
 package mongoutils
 
 import (
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
-	"go.mongodb.org/mongo-driver/mongo/readconcern"
-	"go.mongodb.org/mongo-driver/mongo/writeconcern"
 )
 
 // NewPipe new mongo pipe builder
@@ -17,6 +22,112 @@ func NewDoc() MongoDoc {
 	return new(mDoc)
 }
 
+// NewStrictDoc new mongo doc builder rejecting $ prefixed or dotted keys and values with such keys, regex patterns escaped
+func NewStrictDoc() StrictDoc {
+	return &mDoc{strict: true}
+}
+
+// NewSort new mongo sort builder
+func NewSort() MongoSort {
+	return new(mSort)
+}
+
+// NewProjection new mongo projection builder
+func NewProjection() MongoProjection {
+	return new(mProjection)
+}
+
+// NewMemoryDatabase new in-memory database for tests
+func NewMemoryDatabase() MemoryDatabase {
+	return new(memoryDatabase)
+}
+
+// NewMemoryCollection new in-memory collection for tests
+func NewMemoryCollection(name string) Collection {
+	return new(memoryDatabase).Collection(name)
+}
+
+// NewTenantCollection new collection scoped to context tenant
+//
+// tenant injected into filters, pipelines, $lookup sub-pipelines and inserted documents
+func NewTenantCollection(coll Collection, scope TenantScope) Collection {
+	return &tenantCollection{coll: coll, scope: scope}
+}
+
+// NewFieldPolicy new role based field access policy for model struct
+//
+// readable fields resolved from model bson tags, non struct model has no readable field
+func NewFieldPolicy(model any) FieldPolicy {
+	res := new(fieldPolicy)
+	res.roles = make(map[string]*policyRole)
+	if model != nil {
+		if t := indirectType(reflect.TypeOf(model)); t.Kind() == reflect.Struct {
+			res.fields = policyFields(t, "", make(map[reflect.Type]bool))
+		}
+	}
+	return res
+}
+
+// NewFieldEncryptor new AES-GCM field encryptor for model struct encrypt tags
+//
+// keys map key id to 16, 24 or 32 bytes AES key, new values encrypted with primary key,
+// old keys kept in ring to decrypt and query values encrypted before rotation
+func NewFieldEncryptor(model any, primary string, keys map[string][]byte) (FieldEncryptor, error) {
+	res := new(fieldEncryptor)
+	res.keys = make(map[string]*encryptionKey, len(keys))
+	res.fields = make(map[string]bool)
+	ids := make([]string, 0, len(keys))
+	for id := range keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		key, err := newEncryptionKey(id, keys[id])
+		if err != nil {
+			return nil, err
+		}
+		res.keys[id] = key
+		if id == primary {
+			res.primary = key
+			res.order = append([]string{id}, res.order...)
+		} else {
+			res.order = append(res.order, id)
+		}
+	}
+	if res.primary == nil {
+		return nil, fmt.Errorf("%w %q", ErrUnknownKey, primary)
+	}
+	if model != nil {
+		if t := indirectType(reflect.TypeOf(model)); t.Kind() == reflect.Struct {
+			if err := encryptedFields(t, "", res.fields, make(map[reflect.Type]bool)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return res, nil
+}
+
+// NewEncryptedCollection new collection encrypting tagged fields on write and decrypting results on read
+//
+// filters of deterministic fields encrypted transparently, find and aggregate results buffered to decrypt
+func NewEncryptedCollection(coll Collection, enc FieldEncryptor) Collection {
+	return &encryptedCollection{coll: coll, enc: enc}
+}
+
+// NewCommandMonitor new command monitor logging commands with rendered filter or pipeline, duration, result counts and errors
+//
+// use with options.Client().SetMonitor
+func NewCommandMonitor(opt MonitorOptions) *event.CommandMonitor {
+	if opt.Logger == nil {
+		opt.Logger = stdLogger{}
+	}
+	m := &commandMonitor{opt: opt, redact: make(map[string]bool, len(opt.Redact))}
+	for _, f := range opt.Redact {
+		m.redact[f] = true
+	}
+	return &event.CommandMonitor{Started: m.started, Succeeded: m.succeeded, Failed: m.failed}
+}
+
 // NewMetaCounter new mongo meta counter
 func NewMetaCounter() MetaCounter {
 	res := new(metaCounter)
@@ -31,6 +142,33 @@ func NewMetaSetter() MetaSetter {
 	return res
 }
 
+// NewMigrator new schema migration runner
+//
+// applied migrations stored in collection ("migrations" if empty) and lock stored in collection_lock
+func NewMigrator(db *mongo.Database, collection string) Migrator {
+	res := new(migrator)
+	res.db = db
+	if collection == "" {
+		collection = "migrations"
+	}
+	res.tracking = db.Collection(collection)
+	res.lockColl = db.Collection(collection + "_lock")
+	res.lockTTL = 10 * time.Minute
+	return res
+}
+
+// NewBatchMigrator new batched data migrator
+//
+// migration position stored in state collection with name as id, per document updates written with bulk write if coll supports it (e.g. *mongo.Collection)
+func NewBatchMigrator(name string, coll Collection, state Collection) BatchMigrator {
+	res := new(batchMigrator)
+	res.name = name
+	res.coll = coll
+	res.state = state
+	res.size = 1000
+	return res
+}
+
 // ParseObjectID parse object id from string
 func ParseObjectID(id string) *primitive.ObjectID {
 	if oId, err := primitive.ObjectIDFromHex(id); err == nil && !oId.IsZero() {
@@ -45,9 +183,14 @@ func IsValidObjectId(id *primitive.ObjectID) bool {
 }
 
 // FindOption generate find option with sorts params
-func FindOption(sort any, skip int64, limit int64) *options.FindOptions {
+//
+// allowDiskUse enabled by default, use OptNoDiskUse to disable
+func FindOption(sort any, skip int64, limit int64, opts ...OptionFunc) *options.FindOptions {
+	c := newOpConfig(opts)
 	opt := new(options.FindOptions)
-	opt.SetAllowDiskUse(true)
+	if !c.noDiskUse {
+		opt.SetAllowDiskUse(true)
+	}
 	opt.SetSkip(skip)
 	if limit > 0 {
 		opt.SetLimit(limit)
@@ -55,19 +198,65 @@ func FindOption(sort any, skip int64, limit int64) *options.FindOptions {
 	if sort != nil {
 		opt.SetSort(sort)
 	}
+	if c.projection != nil {
+		opt.SetProjection(c.projection)
+	}
+	if c.collation != nil {
+		opt.SetCollation(c.collation)
+	}
+	if c.maxTime != nil {
+		opt.SetMaxTime(*c.maxTime)
+	}
+	if c.hint != nil {
+		opt.SetHint(c.hint)
+	}
+	if c.comment != nil {
+		opt.SetComment(*c.comment)
+	}
 	return opt
 }
 
 // AggregateOption generate aggregation options
-func AggregateOption() *options.AggregateOptions {
-	return new(options.AggregateOptions).
-		SetAllowDiskUse(true)
+//
+// allowDiskUse enabled by default, use OptNoDiskUse to disable
+func AggregateOption(opts ...OptionFunc) *options.AggregateOptions {
+	c := newOpConfig(opts)
+	opt := new(options.AggregateOptions)
+	if !c.noDiskUse {
+		opt.SetAllowDiskUse(true)
+	}
+	if c.collation != nil {
+		opt.SetCollation(c.collation)
+	}
+	if c.maxTime != nil {
+		opt.SetMaxTime(*c.maxTime)
+	}
+	if c.hint != nil {
+		opt.SetHint(c.hint)
+	}
+	if c.comment != nil {
+		opt.SetComment(*c.comment)
+	}
+	if c.bypass {
+		opt.SetBypassDocumentValidation(true)
+	}
+	return opt
 }
 
 // TxOption generate transaction option with majority write and snapshot read
-func TxOption() *options.TransactionOptions {
-	return options.
+//
+// use option funcs to change defaults, e.g TxOption(TxW(1), TxLocalRead())
+func TxOption(opts ...TxOptionFunc) *options.TransactionOptions {
+	c := newTxConfig(opts)
+	res := options.
 		Transaction().
-		SetWriteConcern(writeconcern.New(writeconcern.WMajority())).
-		SetReadConcern(readconcern.Snapshot())
+		SetWriteConcern(c.writeConcern()).
+		SetReadConcern(c.readConcern)
+	if c.readPref != nil {
+		res.SetReadPreference(c.readPref)
+	}
+	if c.maxCommitTime != nil {
+		res.SetMaxCommitTime(c.maxCommitTime)
+	}
+	return res
 }
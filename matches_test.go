@@ -0,0 +1,63 @@
+package mongoutils_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bopher/mongoutils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type matchUser struct {
+	ID      primitive.ObjectID `bson:"_id"`
+	Name    string             `bson:"name"`
+	Score   float64            `bson:"score"`
+	Roles   []string           `bson:"roles"`
+	Created time.Time          `bson:"created_at"`
+	Address *struct {
+		City string `bson:"city"`
+	} `bson:"address"`
+}
+
+func TestMatches(t *testing.T) {
+	id := primitive.NewObjectID()
+	created := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	user := matchUser{ID: id, Name: "John", Score: 10, Roles: []string{"admin", "editor"}, Created: created}
+	raw, _ := bson.Marshal(user)
+
+	cases := []struct {
+		filter any
+		match  bool
+	}{
+		{mongoutils.Map("_id", id), true},
+		{mongoutils.Map("score", 10), true},
+		{mongoutils.Map("score", mongoutils.Map("$gt", int64(9))), true},
+		{mongoutils.Map("score", mongoutils.Map("$gt", "9")), false},
+		{mongoutils.Map("name", mongoutils.Map("$lt", 1)), false},
+		{mongoutils.Map("created_at", mongoutils.Map("$gte", created)), true},
+		{mongoutils.Map("created_at", mongoutils.Map("$gte", created.Add(time.Second))), false},
+		{mongoutils.Map("address", nil), true},
+		{mongoutils.Map("address.city", mongoutils.Map("$exists", true)), false},
+		{mongoutils.Map("address", mongoutils.Map("$type", "null")), true},
+		{mongoutils.In("roles", "owner", "editor"), true},
+		{mongoutils.RegexFor("name", "^jo", "i"), true},
+		{mongoutils.NewDoc().Add("name", "John").Array("roles", "admin", "editor").Build(), true},
+		{mongoutils.Map("roles", mongoutils.Array("editor", "admin")), false},
+		{mongoutils.Map("$expr", mongoutils.Map("$gt", mongoutils.Array("$score", 5))), true},
+	}
+	for i, c := range cases {
+		for _, doc := range []any{user, &user, raw, bson.Raw(raw)} {
+			res, err := mongoutils.Matches(doc, c.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res != c.match {
+				t.Fatalf("fail case %d with %T: %v", i, doc, c.filter)
+			}
+		}
+	}
+	if _, err := mongoutils.Matches(mongoutils.Map("a", 1), mongoutils.Map("a", mongoutils.Map("$near", 1))); err == nil {
+		t.Fatal("fail unsupported operator")
+	}
+}
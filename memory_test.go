@@ -0,0 +1,293 @@
+package mongoutils_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/bopher/mongoutils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type memoryPerson struct {
+	ID    int      `bson:"_id"`
+	Name  string   `bson:"name"`
+	Age   int      `bson:"age"`
+	Tags  []string `bson:"tags"`
+	Items []struct {
+		Sku string `bson:"sku"`
+		Qty int    `bson:"qty"`
+	} `bson:"items,omitempty"`
+}
+
+func memoryNames(t *testing.T, coll mongoutils.Collection, filter any, opts ...*options.FindOptions) string {
+	t.Helper()
+	res, err := mongoutils.Find[memoryPerson](context.TODO(), coll, filter, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := ""
+	for _, p := range res {
+		names += p.Name
+	}
+	return names
+}
+
+func TestMemoryCollection(t *testing.T) {
+	ctx := context.TODO()
+	coll := mongoutils.NewMemoryCollection("people")
+	_, err := coll.InsertMany(ctx, []any{
+		mongoutils.Map("_id", 1, "name", "A", "age", 20, "tags", mongoutils.Array("x", "y"), "items", mongoutils.Array(mongoutils.Map("sku", "s1", "qty", 2))),
+		mongoutils.Map("_id", 2, "name", "B", "age", 30.0, "tags", mongoutils.Array("y")),
+		mongoutils.Map("_id", 3, "name", "C", "age", int64(40), "items", mongoutils.Array(mongoutils.Map("sku", "s2", "qty", 5), mongoutils.Map("sku", "s1", "qty", 7))),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := coll.InsertOne(ctx, mongoutils.Map("_id", 1)); !mongo.IsDuplicateKeyError(err) {
+		t.Fatal("fail duplicate _id")
+	}
+
+	// filters
+	sortByID := options.Find().SetSort(mongoutils.Doc("_id", 1))
+	cases := []struct {
+		filter any
+		names  string
+	}{
+		{mongoutils.Map("age", mongoutils.Map("$gte", 30)), "BC"},
+		{mongoutils.Map("age", mongoutils.Map("$gt", "1")), ""},
+		{mongoutils.Map("tags", "y"), "AB"},
+		{mongoutils.Map("tags", mongoutils.Array("y")), "B"},
+		{mongoutils.Map("tags", mongoutils.Map("$size", 2)), "A"},
+		{mongoutils.Map("tags", mongoutils.Map("$all", mongoutils.Array("x", "y"))), "A"},
+		{mongoutils.Map("tags", mongoutils.Map("$exists", false)), "C"},
+		{mongoutils.Map("tags", nil), "C"},
+		{mongoutils.Map("tags", mongoutils.Map("$ne", "x")), "BC"},
+		{mongoutils.Map("items.sku", "s1"), "AC"},
+		{mongoutils.Map("items.1.qty", 7), "C"},
+		{mongoutils.Map("items", mongoutils.Map("$elemMatch", mongoutils.Map("sku", "s1", "qty", mongoutils.Map("$gt", 5)))), "C"},
+		{mongoutils.Map("$or", mongoutils.Array(mongoutils.Map("_id", 1), mongoutils.Map("name", "C"))), "AC"},
+		{mongoutils.Map("$nor", mongoutils.Array(mongoutils.Map("_id", 1))), "BC"},
+		{mongoutils.In("_id", 1, 2), "AB"},
+		{mongoutils.RegexFor("name", "^[ab]$", "i"), "AB"},
+		{mongoutils.Map("name", mongoutils.Map("$not", mongoutils.Regex("A", ""))), "BC"},
+		{mongoutils.Map("age", mongoutils.Map("$type", "double")), "B"},
+		{mongoutils.Map("age", mongoutils.Map("$mod", mongoutils.Array(20, 0))), "AC"},
+		{mongoutils.NewDoc().Add("age", 20).Regex("name", "^A", "").Build(), "A"},
+	}
+	for i, c := range cases {
+		if names := memoryNames(t, coll, c.filter, sortByID); names != c.names {
+			t.Fatalf("fail filter %d: %v got %q", i, c.filter, names)
+		}
+	}
+	if _, err := coll.Find(ctx, mongoutils.Map("$where", "true")); !errors.Is(err, mongoutils.ErrUnsupportedQuery) {
+		t.Fatal("fail unsupported operator")
+	}
+
+	// sort, skip, limit
+	if names := memoryNames(t, coll, nil, mongoutils.FindOption(mongoutils.Doc("age", -1), 1, 1)); names != "B" {
+		t.Fatal("fail sort skip limit")
+	}
+
+	// projection
+	var doc primitive.D
+	err = coll.FindOne(ctx, mongoutils.Map("_id", 3), options.FindOne().SetProjection(mongoutils.Map("items.sku", 1, "_id", 0))).Decode(&doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc) != 1 || len(doc[0].Value.(primitive.A)[0].(primitive.D)) != 1 {
+		t.Log(doc)
+		t.Fatal("fail inclusion projection")
+	}
+	var m primitive.M
+	err = coll.FindOne(ctx, mongoutils.Map("_id", 1), options.FindOne().SetProjection(mongoutils.Map("tags", mongoutils.Map("$slice", -1), "items", 0))).Decode(&m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tags, ok := m["tags"].(primitive.A); len(m) != 4 || !ok || len(tags) != 1 {
+		t.Log(m)
+		t.Fatal("fail exclusion projection")
+	}
+	m = nil
+	err = coll.FindOne(ctx, mongoutils.Map("_id", 2), options.FindOne().SetProjection(mongoutils.Map(
+		"label", mongoutils.Map("$concat", mongoutils.Array("$name", "-", "x")),
+		"age", mongoutils.Map("$cond", mongoutils.Array(true, "***", "$age")),
+	))).Decode(&m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m) != 3 || m["_id"] != int32(2) || m["label"] != "B-x" || m["age"] != "***" {
+		t.Log(m)
+		t.Fatal("fail computed projection")
+	}
+
+	// update
+	res, err := coll.UpdateMany(ctx, mongoutils.Map("age", mongoutils.Map("$lt", 35)), mongoutils.Map(
+		"$inc", mongoutils.Map("age", 1),
+		"$push", mongoutils.Map("tags", mongoutils.Map("$each", mongoutils.Array("z"), "$slice", -2)),
+		"$set", mongoutils.Map("meta.updated", true),
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.MatchedCount != 2 || res.ModifiedCount != 2 {
+		t.Fatal("fail UpdateMany result")
+	}
+	p, err := mongoutils.FindByID[memoryPerson](ctx, coll, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Age != 21 || len(p.Tags) != 2 || p.Tags[1] != "z" {
+		t.Log(p)
+		t.Fatal("fail update operators")
+	}
+	if _, err := coll.UpdateOne(ctx, mongoutils.Map("_id", 3), mongoutils.Map("$pull", mongoutils.Map("items", mongoutils.Map("qty", mongoutils.Map("$gt", 6))))); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := coll.CountDocuments(ctx, mongoutils.Map("items.qty", 7)); n != 0 {
+		t.Fatal("fail $pull")
+	}
+	if _, err := coll.UpdateOne(ctx, mongoutils.Map("_id", 1), mongoutils.Map("name", "X")); !errors.Is(err, mongoutils.ErrInvalidQuery) {
+		t.Fatal("fail update without operators")
+	}
+
+	// upsert
+	res, err = coll.UpdateOne(ctx, mongoutils.Map("_id", 4, "name", "D"), mongoutils.Map("$setOnInsert", mongoutils.Map("age", 50)), mongoutils.UpdateOption(mongoutils.OptUpsert()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.UpsertedID != int32(4) {
+		t.Fatal("fail upsert")
+	}
+	if p, err := mongoutils.FindByID[memoryPerson](ctx, coll, 4); err != nil || p.Name != "D" || p.Age != 50 {
+		t.Fatal("fail upsert document")
+	}
+
+	// replace and delete
+	if res, err := coll.ReplaceOne(ctx, mongoutils.Map("_id", 2), mongoutils.Map("name", "E")); err != nil || res.ModifiedCount != 1 {
+		t.Fatal("fail ReplaceOne")
+	}
+	if _, err := mongoutils.FindOne[memoryPerson](ctx, coll, mongoutils.Map("name", "B")); !errors.Is(err, mongoutils.ErrNotFound) {
+		t.Fatal("fail ReplaceOne replaced document")
+	}
+	if res, err := coll.DeleteMany(ctx, mongoutils.Map("age", mongoutils.Map("$gte", 40))); err != nil || res.DeletedCount != 2 {
+		t.Fatal("fail DeleteMany")
+	}
+	if names := memoryNames(t, coll, nil, sortByID); names != "AE" {
+		t.Fatal("fail DeleteMany result")
+	}
+	if values, err := coll.Distinct(ctx, "tags", nil); err != nil || len(values) != 2 {
+		t.Log(values)
+		t.Fatal("fail Distinct")
+	}
+
+	// large integers compared exactly
+	counters := mongoutils.NewMemoryCollection("counters")
+	counters.InsertMany(ctx, []any{
+		mongoutils.Map("_id", 1, "name", "A", "n", int64(1<<53)),
+		mongoutils.Map("_id", 2, "name", "B", "n", int64(1<<53+1)),
+		mongoutils.Map("_id", 3, "name", "C", "n", int64(1<<60+1)),
+	})
+	for i, c := range []struct {
+		filter any
+		names  string
+	}{
+		{mongoutils.Map("n", int64(1<<53+1)), "B"},
+		{mongoutils.Map("n", mongoutils.Map("$gt", int64(1<<53))), "BC"},
+		{mongoutils.Map("n", float64(1<<53)), "A"},
+		{mongoutils.Map("n", mongoutils.Map("$gt", float64(1<<53))), "BC"},
+		{mongoutils.Map("n", mongoutils.Map("$lte", float64(1<<53))), "A"},
+		{mongoutils.Map("n", mongoutils.Map("$mod", mongoutils.Array(2, 1))), "BC"},
+	} {
+		if names := memoryNames(t, counters, c.filter, sortByID); names != c.names {
+			t.Fatalf("fail large integer %d: got %q", i, names)
+		}
+	}
+}
+
+func TestMemoryPipeline(t *testing.T) {
+	ctx := context.TODO()
+	db := mongoutils.NewMemoryDatabase()
+	users := db.Collection("users")
+	orders := db.Collection("orders")
+	users.InsertMany(ctx, []any{
+		mongoutils.Map("_id", 1, "name", "John"),
+		mongoutils.Map("_id", 2, "name", "Jack"),
+	})
+	orders.InsertMany(ctx, []any{
+		mongoutils.Doc("_id", 1, "user", 1, "items", mongoutils.Array(mongoutils.Map("sku", "a", "qty", 2), mongoutils.Map("sku", "b", "qty", 1))),
+		mongoutils.Doc("_id", 2, "user", 1, "items", mongoutils.Array(mongoutils.Map("sku", "a", "qty", 3))),
+		mongoutils.Doc("_id", 3, "user", 2, "items", mongoutils.Array()),
+	})
+
+	type report struct {
+		ID    string `bson:"_id"`
+		Total int    `bson:"total"`
+		Users []int  `bson:"users"`
+		Name  string `bson:"name"`
+	}
+	pipe := mongoutils.NewPipe().
+		Unwind("$items", false).
+		LoadRelation("users", "user", "_id", "owner").
+		Group(func(d mongoutils.MongoDoc) mongoutils.MongoDoc {
+			return d.
+				Add("_id", "$items.sku").
+				Nested("total", "$sum", "$items.qty").
+				Nested("users", "$addToSet", "$user").
+				Nested("name", "$first", "$owner.name")
+		}).
+		Sort(mongoutils.Doc("_id", 1))
+	res, err := mongoutils.Aggregate[report](ctx, orders, pipe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 2 || res[0].ID != "a" || res[0].Total != 5 || len(res[0].Users) != 1 || res[0].Name != "John" || res[1].Total != 1 {
+		t.Log(res)
+		t.Fatal("fail unwind lookup group")
+	}
+
+	// $lookup pipeline, $addFields, $project and $facet
+	docs, err := mongoutils.EvalPipeline(ctx, db, []any{mongoutils.Map("_id", 1, "name", "John")}, mongo.Pipeline{
+		mongoutils.Doc("$lookup", mongoutils.Doc(
+			"from", "orders",
+			"let", mongoutils.Doc("uid", "$_id"),
+			"pipeline", mongo.Pipeline{mongoutils.Doc("$match", mongoutils.Doc("$expr", mongoutils.Doc("$eq", mongoutils.Array("$user", "$$uid"))))},
+			"as", "orders",
+		)),
+		mongoutils.Doc("$addFields", mongoutils.Doc("count", mongoutils.Doc("$size", "$orders"))),
+		mongoutils.Doc("$project", mongoutils.Doc("_id", 0, "count", 1, "title", mongoutils.Doc("$concat", mongoutils.Array("$name", "!")))),
+		mongoutils.Doc("$facet", mongoutils.Doc("all", mongo.Pipeline{mongoutils.Doc("$count", "n")})),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 1 {
+		t.Fatal("fail $facet")
+	}
+	all := docs[0][0].Value.(primitive.A)
+	if len(all) != 1 {
+		t.Fatal("fail $facet count")
+	}
+	docs, _ = mongoutils.EvalPipeline(ctx, db, []any{mongoutils.Map("_id", 1, "name", "John")}, mongo.Pipeline{
+		mongoutils.Doc("$lookup", mongoutils.Doc(
+			"from", "orders",
+			"let", mongoutils.Doc("uid", "$_id"),
+			"pipeline", mongo.Pipeline{mongoutils.Doc("$match", mongoutils.Doc("$expr", mongoutils.Doc("$eq", mongoutils.Array("$user", "$$uid"))))},
+			"as", "orders",
+		)),
+		mongoutils.Doc("$project", mongoutils.Doc("_id", 0, "count", mongoutils.Doc("$size", "$orders"), "title", mongoutils.Doc("$concat", mongoutils.Array("$name", "!")))),
+	})
+	if len(docs) != 1 || len(docs[0]) != 2 || docs[0][0].Value != int32(2) || docs[0][1].Value != "John!" {
+		t.Log(docs)
+		t.Fatal("fail $lookup pipeline and $project")
+	}
+
+	// unsupported stage
+	_, err = orders.Aggregate(ctx, mongo.Pipeline{mongoutils.Doc("$match", mongoutils.Map()), mongoutils.Doc("$out", "copy")})
+	var pErr *mongoutils.PipelineError
+	if !errors.As(err, &pErr) || pErr.Stage != 1 || !errors.Is(err, mongoutils.ErrUnsupportedQuery) {
+		t.Fatal("fail unsupported stage error")
+	}
+}
@@ -0,0 +1,447 @@
+package mongoutils
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type memoryDatabase struct {
+	mutex       sync.Mutex
+	collections map[string]*memoryCollection
+}
+
+func (me *memoryDatabase) Collection(name string) Collection {
+	return me.collection(name)
+}
+
+func (me *memoryDatabase) collection(name string) *memoryCollection {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	if me.collections == nil {
+		me.collections = make(map[string]*memoryCollection)
+	}
+	coll, ok := me.collections[name]
+	if !ok {
+		coll = &memoryCollection{name: name, db: me}
+		me.collections[name] = coll
+	}
+	return coll
+}
+
+func (me *memoryDatabase) Drop() {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	me.collections = nil
+}
+
+type memoryCollection struct {
+	name  string
+	db    *memoryDatabase
+	mutex sync.RWMutex
+	docs  []primitive.D
+}
+
+func (me *memoryCollection) Name() string {
+	return me.name
+}
+
+func (me *memoryCollection) InsertOne(ctx context.Context, document any, opts ...*options.InsertOneOptions) (*mongo.InsertOneResult, error) {
+	doc, id, err := prepareInsert(document)
+	if err != nil {
+		return nil, err
+	}
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	if err := me.checkDuplicate(id); err != nil {
+		return nil, mongo.WriteException{WriteErrors: mongo.WriteErrors{*err}}
+	}
+	me.docs = append(me.docs, doc)
+	return &mongo.InsertOneResult{InsertedID: id}, nil
+}
+
+func (me *memoryCollection) InsertMany(ctx context.Context, documents []any, opts ...*options.InsertManyOptions) (*mongo.InsertManyResult, error) {
+	if len(documents) == 0 {
+		return nil, mongo.ErrEmptySlice
+	}
+	opt := options.MergeInsertManyOptions(opts...)
+	ordered := opt.Ordered == nil || *opt.Ordered
+	res := &mongo.InsertManyResult{}
+	var writeErrors []mongo.BulkWriteError
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	for i, document := range documents {
+		doc, id, err := prepareInsert(document)
+		if err != nil {
+			return res, err
+		}
+		if we := me.checkDuplicate(id); we != nil {
+			we.Index = i
+			writeErrors = append(writeErrors, mongo.BulkWriteError{WriteError: *we, Request: mongo.NewInsertOneModel().SetDocument(doc)})
+			if ordered {
+				break
+			}
+			continue
+		}
+		me.docs = append(me.docs, doc)
+		res.InsertedIDs = append(res.InsertedIDs, id)
+	}
+	if len(writeErrors) > 0 {
+		return res, mongo.BulkWriteException{WriteErrors: writeErrors}
+	}
+	return res, nil
+}
+
+func (me *memoryCollection) Find(ctx context.Context, filter any, opts ...*options.FindOptions) (*mongo.Cursor, error) {
+	opt := options.MergeFindOptions(opts...)
+	skip, limit := int64(0), int64(0)
+	if opt.Skip != nil {
+		skip = *opt.Skip
+	}
+	if opt.Limit != nil {
+		limit = *opt.Limit
+		if limit < 0 {
+			limit = -limit
+		}
+	}
+	docs, err := me.find(filter, opt.Sort, skip, limit, opt.Projection)
+	if err != nil {
+		return nil, err
+	}
+	return documentsCursor(docs)
+}
+
+func (me *memoryCollection) FindOne(ctx context.Context, filter any, opts ...*options.FindOneOptions) *mongo.SingleResult {
+	opt := options.MergeFindOneOptions(opts...)
+	skip := int64(0)
+	if opt.Skip != nil {
+		skip = *opt.Skip
+	}
+	docs, err := me.find(filter, opt.Sort, skip, 1, opt.Projection)
+	if err == nil && len(docs) == 0 {
+		err = mongo.ErrNoDocuments
+	}
+	if err != nil {
+		return mongo.NewSingleResultFromDocument(primitive.D{}, err, nil)
+	}
+	return mongo.NewSingleResultFromDocument(docs[0], nil, nil)
+}
+
+func (me *memoryCollection) UpdateOne(ctx context.Context, filter any, update any, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	return me.update(filter, update, false, options.MergeUpdateOptions(opts...))
+}
+
+func (me *memoryCollection) UpdateMany(ctx context.Context, filter any, update any, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	return me.update(filter, update, true, options.MergeUpdateOptions(opts...))
+}
+
+func (me *memoryCollection) ReplaceOne(ctx context.Context, filter any, replacement any, opts ...*options.ReplaceOptions) (*mongo.UpdateResult, error) {
+	opt := options.MergeReplaceOptions(opts...)
+	doc, err := toDocument(replacement)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range doc {
+		if strings.HasPrefix(e.Key, "$") {
+			return nil, fmt.Errorf("%w: replacement document cannot contain operators", ErrInvalidQuery)
+		}
+	}
+	f, err := toDocument(filter)
+	if err != nil {
+		return nil, err
+	}
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	return me.write(f, false, opt.Upsert != nil && *opt.Upsert, func(old primitive.D, insert bool) (primitive.D, error) {
+		if id, ok := getValue(doc, []string{"_id"}); ok {
+			return withID(copyValue(doc).(primitive.D), id), nil
+		}
+		if id, ok := getValue(old, []string{"_id"}); ok {
+			return withID(copyValue(doc).(primitive.D), id), nil
+		}
+		return copyValue(doc).(primitive.D), nil
+	})
+}
+
+func (me *memoryCollection) DeleteOne(ctx context.Context, filter any, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error) {
+	return me.delete(filter, false)
+}
+
+func (me *memoryCollection) DeleteMany(ctx context.Context, filter any, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error) {
+	return me.delete(filter, true)
+}
+
+func (me *memoryCollection) CountDocuments(ctx context.Context, filter any, opts ...*options.CountOptions) (int64, error) {
+	opt := options.MergeCountOptions(opts...)
+	skip, limit := int64(0), int64(0)
+	if opt.Skip != nil {
+		skip = *opt.Skip
+	}
+	if opt.Limit != nil {
+		limit = *opt.Limit
+	}
+	docs, err := me.find(filter, nil, skip, limit, nil)
+	return int64(len(docs)), err
+}
+
+func (me *memoryCollection) Distinct(ctx context.Context, fieldName string, filter any, opts ...*options.DistinctOptions) ([]any, error) {
+	docs, err := me.find(filter, nil, 0, 0, nil)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]any, 0)
+	for _, doc := range docs {
+		vals, _ := resolvePath(doc, strings.Split(fieldName, "."))
+		for _, v := range vals {
+			items := []any{v}
+			if arr, ok := v.(primitive.A); ok {
+				items = arr
+			}
+			for _, item := range items {
+				if !containsValue(res, item) {
+					res = append(res, item)
+				}
+			}
+		}
+	}
+	return res, nil
+}
+
+func (me *memoryCollection) Aggregate(ctx context.Context, pipeline any, opts ...*options.AggregateOptions) (*mongo.Cursor, error) {
+	env := &pipelineEnv{ctx: ctx, db: me.db}
+	docs, err := env.run(me.snapshot(), pipeline)
+	if err != nil {
+		return nil, err
+	}
+	return documentsCursor(docs)
+}
+
+// snapshot get copy of all documents
+func (me *memoryCollection) snapshot() []primitive.D {
+	me.mutex.RLock()
+	defer me.mutex.RUnlock()
+	res := make([]primitive.D, len(me.docs))
+	for i, doc := range me.docs {
+		res[i] = copyValue(doc).(primitive.D)
+	}
+	return res
+}
+
+// find filter, sort, skip, limit and project documents
+func (me *memoryCollection) find(filter any, sortSpec any, skip, limit int64, projection any) ([]primitive.D, error) {
+	f, err := toDocument(filter)
+	if err != nil {
+		return nil, err
+	}
+	docs := make([]primitive.D, 0)
+	for _, doc := range me.snapshot() {
+		matched, err := matchDocument(doc, f)
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			docs = append(docs, doc)
+		}
+	}
+	if sortSpec != nil {
+		spec, err := toDocument(sortSpec)
+		if err != nil {
+			return nil, err
+		}
+		if err := sortDocuments(docs, spec); err != nil {
+			return nil, err
+		}
+	}
+	if skip > 0 {
+		if skip > int64(len(docs)) {
+			skip = int64(len(docs))
+		}
+		docs = docs[skip:]
+	}
+	if limit > 0 && limit < int64(len(docs)) {
+		docs = docs[:limit]
+	}
+	if projection != nil {
+		proj, err := toDocument(projection)
+		if err != nil {
+			return nil, err
+		}
+		if len(proj) > 0 {
+			// find projection accepts aggregation expressions like $project
+			env := &pipelineEnv{ctx: context.TODO(), db: me.db}
+			if docs, err = env.project(docs, proj); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return docs, nil
+}
+
+func (me *memoryCollection) update(filter any, update any, many bool, opt *options.UpdateOptions) (*mongo.UpdateResult, error) {
+	switch update.(type) {
+	case mongo.Pipeline, primitive.A, []primitive.D, []any:
+		return nil, fmt.Errorf("%w: update pipeline", ErrUnsupportedQuery)
+	}
+	if opt.ArrayFilters != nil {
+		return nil, fmt.Errorf("%w: arrayFilters", ErrUnsupportedQuery)
+	}
+	u, err := toDocument(update)
+	if err != nil {
+		return nil, err
+	}
+	if len(u) == 0 {
+		return nil, fmt.Errorf("%w: empty update document", ErrInvalidQuery)
+	}
+	for _, e := range u {
+		if !strings.HasPrefix(e.Key, "$") {
+			return nil, fmt.Errorf("%w: update document requires operators", ErrInvalidQuery)
+		}
+	}
+	f, err := toDocument(filter)
+	if err != nil {
+		return nil, err
+	}
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	return me.write(f, many, opt.Upsert != nil && *opt.Upsert, func(old primitive.D, insert bool) (primitive.D, error) {
+		return applyUpdate(old, u, insert)
+	})
+}
+
+// write apply change to matched documents or upsert, caller must hold write lock
+func (me *memoryCollection) write(filter primitive.D, many bool, upsert bool, change func(old primitive.D, insert bool) (primitive.D, error)) (*mongo.UpdateResult, error) {
+	res := &mongo.UpdateResult{}
+	for i, doc := range me.docs {
+		matched, err := matchDocument(doc, filter)
+		if err != nil {
+			return nil, err
+		}
+		if !matched {
+			continue
+		}
+		res.MatchedCount++
+		next, err := change(doc, false)
+		if err != nil {
+			return res, err
+		}
+		oldID, _ := getValue(doc, []string{"_id"})
+		if newID, _ := getValue(next, []string{"_id"}); compareValues(oldID, newID) != 0 {
+			return res, fmt.Errorf("%w: field _id is immutable", ErrInvalidQuery)
+		}
+		if !documentsEqual(doc, next) {
+			me.docs[i] = withID(next, oldID)
+			res.ModifiedCount++
+		}
+		if !many {
+			break
+		}
+	}
+	if res.MatchedCount > 0 || !upsert {
+		return res, nil
+	}
+
+	base, err := upsertDocument(filter)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := change(base, true)
+	if err != nil {
+		return nil, err
+	}
+	id, ok := getValue(doc, []string{"_id"})
+	if !ok {
+		id = primitive.NewObjectID()
+	}
+	if we := me.checkDuplicate(id); we != nil {
+		return nil, mongo.WriteException{WriteErrors: mongo.WriteErrors{*we}}
+	}
+	me.docs = append(me.docs, withID(doc, id))
+	res.UpsertedCount = 1
+	res.UpsertedID = id
+	return res, nil
+}
+
+func (me *memoryCollection) delete(filter any, many bool) (*mongo.DeleteResult, error) {
+	f, err := toDocument(filter)
+	if err != nil {
+		return nil, err
+	}
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	res := &mongo.DeleteResult{}
+	docs := make([]primitive.D, 0, len(me.docs))
+	for _, doc := range me.docs {
+		if many || res.DeletedCount == 0 {
+			matched, err := matchDocument(doc, f)
+			if err != nil {
+				return nil, err
+			}
+			if matched {
+				res.DeletedCount++
+				continue
+			}
+		}
+		docs = append(docs, doc)
+	}
+	me.docs = docs
+	return res, nil
+}
+
+// checkDuplicate check _id uniqueness, caller must hold lock
+func (me *memoryCollection) checkDuplicate(id any) *mongo.WriteError {
+	for _, doc := range me.docs {
+		if v, _ := getValue(doc, []string{"_id"}); compareValues(v, id) == 0 {
+			return &mongo.WriteError{
+				Code:    11000,
+				Message: fmt.Sprintf("E11000 duplicate key error collection: %s index: _id_ dup key: { _id: %v }", me.name, id),
+			}
+		}
+	}
+	return nil
+}
+
+// prepareInsert convert document and generate _id if missing
+func prepareInsert(document any) (primitive.D, any, error) {
+	doc, err := toDocument(document)
+	if err != nil {
+		return nil, nil, err
+	}
+	id, ok := getValue(doc, []string{"_id"})
+	if !ok {
+		id = primitive.NewObjectID()
+	}
+	return withID(doc, id), id, nil
+}
+
+// withID move or add _id as first field
+func withID(doc primitive.D, id any) primitive.D {
+	res := make(primitive.D, 0, len(doc)+1)
+	res = append(res, primitive.E{Key: "_id", Value: id})
+	for _, e := range doc {
+		if e.Key != "_id" {
+			res = append(res, e)
+		}
+	}
+	return res
+}
+
+func documentsEqual(a, b primitive.D) bool {
+	x, err1 := bson.Marshal(a)
+	y, err2 := bson.Marshal(b)
+	return err1 == nil && err2 == nil && bytes.Equal(x, y)
+}
+
+func documentsCursor(docs []primitive.D) (*mongo.Cursor, error) {
+	items := make([]any, len(docs))
+	for i, doc := range docs {
+		items[i] = doc
+	}
+	return mongo.NewCursorFromDocuments(items, nil, nil)
+}
@@ -0,0 +1,71 @@
+package mongoutils
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	// ErrMigrationLocked migration lock held by another instance
+	ErrMigrationLocked = errors.New("mongoutils: migration locked by another instance")
+	// ErrIrreversibleMigration migration has no Down function
+	ErrIrreversibleMigration = errors.New("mongoutils: migration is irreversible")
+	// ErrMigrationNotFound migration not registered
+	ErrMigrationNotFound = errors.New("mongoutils: migration not found")
+	// ErrMigrationLockLost migration lock expired or taken by another instance while running
+	ErrMigrationLockLost = errors.New("mongoutils: migration lock lost")
+)
+
+// MigrationFunc migration up or down function
+type MigrationFunc func(ctx mongo.SessionContext, db *mongo.Database) error
+
+// Migration schema migration
+type Migration struct {
+	// ID unique migration id, migrations run in id order
+	ID string
+	// Description used with id and version to calculate checksum
+	Description string
+	// Version explicit migration version used in checksum, change when Up or Down body changed
+	Version string
+	Up      MigrationFunc
+	// Down optional rollback function
+	Down MigrationFunc
+	// Tx run migration inside transaction with options (e.g TxOption()) if not nil
+	Tx *options.TransactionOptions
+}
+
+// MigrationStatus migration state
+type MigrationStatus struct {
+	ID          string
+	Description string
+	Applied     bool
+	AppliedAt   *time.Time
+	Checksum    string
+	// Changed checksum (id, description and version) of applied migration differs from registered migration,
+	// edited Up or Down logic not detected unless Version changed
+	Changed bool
+	// Missing applied migration not registered
+	Missing bool
+}
+
+// Migrator schema migration runner
+type Migrator interface {
+	// Register add migrations, returns error on empty or duplicate id and nothing registered on error
+	Register(migrations ...Migration) error
+	// Store set tracking and lock collections (e.g. in-memory collections), default <collection> and <collection>_lock of database
+	Store(tracking Collection, lock Collection) Migrator
+	// LockTTL set lock expiration time (default 10 minutes), lock extended every third of ttl while running
+	LockTTL(ttl time.Duration) Migrator
+	// Status get migrations status
+	Status(ctx context.Context) ([]MigrationStatus, error)
+	// Up apply n pending migrations (all pending if n <= 0) and returns applied ids
+	Up(ctx context.Context, n int) ([]string, error)
+	// Down rollback n last applied migrations (all applied if n <= 0) and returns rolled back ids
+	Down(ctx context.Context, n int) ([]string, error)
+	// To apply or rollback migrations until migration with id is last applied migration
+	To(ctx context.Context, id string) ([]string, error)
+}
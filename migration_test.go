@@ -0,0 +1,126 @@
+package mongoutils_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bopher/mongoutils"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestMigrator(t *testing.T) {
+	ctx := context.TODO()
+	// sessions started client side, no server needed with in-memory store
+	client, err := mongo.NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Connect(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer client.Disconnect(ctx)
+	db := mongoutils.NewMemoryDatabase()
+	calls := make([]string, 0)
+	step := func(name string) mongoutils.MigrationFunc {
+		return func(ctx mongo.SessionContext, db *mongo.Database) error {
+			calls = append(calls, name)
+			return nil
+		}
+	}
+	migration := func(id string) mongoutils.Migration {
+		return mongoutils.Migration{ID: id, Up: step("up " + id), Down: step("down " + id)}
+	}
+	newMigrator := func() mongoutils.Migrator {
+		return mongoutils.NewMigrator(client.Database("test"), "").Store(db.Collection("migrations"), db.Collection("migrations_lock"))
+	}
+	migrator := newMigrator()
+
+	// Register
+	if err := migrator.Register(migration("002_posts"), migration("001_users")); err != nil {
+		t.Fatal(err)
+	}
+	if err := migrator.Register(migration("001_users")); err == nil {
+		t.Fatal("fail Register duplicate")
+	}
+	if err := migrator.Register(mongoutils.Migration{Up: step("")}); err == nil {
+		t.Fatal("fail Register empty id")
+	}
+	if err := migrator.Register(migration("004_tags"), mongoutils.Migration{ID: "005_likes"}); err == nil {
+		t.Fatal("fail Register without up")
+	}
+	if err := migrator.Register(migration("004_tags"), migration("004_tags")); err == nil {
+		t.Fatal("fail Register duplicate in batch")
+	}
+	if err := migrator.Register(migration("003_comments")); err != nil {
+		t.Fatal(err)
+	}
+	status, err := migrator.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]string, 0)
+	for _, st := range status {
+		ids = append(ids, st.ID)
+	}
+	if strings.Join(ids, ",") != "001_users,002_posts,003_comments" {
+		t.Fatal("fail Register partial or unsorted", ids)
+	}
+	if _, err := migrator.To(ctx, "004_unknown"); !errors.Is(err, mongoutils.ErrMigrationNotFound) {
+		t.Fatal("fail To unknown", err)
+	}
+
+	// Up and Down order
+	if applied, err := migrator.Up(ctx, 2); err != nil || strings.Join(applied, ",") != "001_users,002_posts" {
+		t.Fatal("fail Up n", applied, err)
+	}
+	if applied, err := migrator.Up(ctx, 0); err != nil || strings.Join(applied, ",") != "003_comments" {
+		t.Fatal("fail Up pending", applied, err)
+	}
+	if rolled, err := migrator.Down(ctx, 0); err != nil || strings.Join(rolled, ",") != "003_comments,002_posts,001_users" {
+		t.Fatal("fail Down", rolled, err)
+	}
+	if applied, err := migrator.To(ctx, "002_posts"); err != nil || strings.Join(applied, ",") != "001_users,002_posts" {
+		t.Fatal("fail To up", applied, err)
+	}
+	if rolled, err := migrator.To(ctx, "001_users"); err != nil || strings.Join(rolled, ",") != "002_posts" {
+		t.Fatal("fail To down", rolled, err)
+	}
+	if strings.Join(calls, ",") != "up 001_users,up 002_posts,up 003_comments,down 003_comments,down 002_posts,down 001_users,up 001_users,up 002_posts,down 002_posts" {
+		t.Fatal("fail run order", calls)
+	}
+
+	// Lock contention
+	lock := db.Collection("migrations_lock")
+	if _, err := lock.InsertOne(ctx, mongoutils.Map("_id", "lock", "owner", "other", "expires_at", time.Now().Add(time.Hour))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(ctx, 0); !errors.Is(err, mongoutils.ErrMigrationLocked) {
+		t.Fatal("fail locked", err)
+	}
+	if _, err := lock.UpdateOne(ctx, mongoutils.Map("_id", "lock"), mongoutils.SetNested("expires_at", time.Now().Add(-time.Second))); err != nil {
+		t.Fatal(err)
+	}
+	if applied, err := migrator.Up(ctx, 0); err != nil || len(applied) != 2 {
+		t.Fatal("fail expired lock", applied, err)
+	}
+	if n, _ := lock.CountDocuments(ctx, mongoutils.Map()); n != 0 {
+		t.Fatal("fail unlock")
+	}
+
+	// Checksum mismatch and missing
+	changed := newMigrator()
+	edited := migration("001_users")
+	edited.Version = "2"
+	if err := changed.Register(edited, migration("002_posts")); err != nil {
+		t.Fatal(err)
+	}
+	if status, err = changed.Status(ctx); err != nil || len(status) != 3 {
+		t.Fatal("fail Status", status, err)
+	}
+	if !status[0].Changed || status[1].Changed || !status[2].Missing || status[2].ID != "003_comments" {
+		t.Fatal("fail Status changed", status)
+	}
+}
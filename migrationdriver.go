@@ -0,0 +1,320 @@
+package mongoutils
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type migrationRecord struct {
+	ID        string    `bson:"_id"`
+	Checksum  string    `bson:"checksum"`
+	AppliedAt time.Time `bson:"applied_at"`
+}
+
+type migrator struct {
+	db         *mongo.Database
+	tracking   Collection
+	lockColl   Collection
+	lockTTL    time.Duration
+	migrations []Migration
+}
+
+func (me *migrator) Register(migrations ...Migration) error {
+	// validate whole batch before register
+	ids := make(map[string]bool, len(migrations))
+	for _, m := range migrations {
+		if m.ID == "" {
+			return fmt.Errorf("mongoutils: migration id is required")
+		}
+		if m.Up == nil {
+			return fmt.Errorf("mongoutils: migration %s has no up function", m.ID)
+		}
+		if ids[m.ID] || me.find(m.ID) != nil {
+			return fmt.Errorf("mongoutils: duplicate migration %s", m.ID)
+		}
+		ids[m.ID] = true
+	}
+	me.migrations = append(me.migrations, migrations...)
+	sort.Slice(me.migrations, func(i, j int) bool {
+		return me.migrations[i].ID < me.migrations[j].ID
+	})
+	return nil
+}
+
+func (me *migrator) Store(tracking Collection, lock Collection) Migrator {
+	if tracking != nil {
+		me.tracking = tracking
+	}
+	if lock != nil {
+		me.lockColl = lock
+	}
+	return me
+}
+
+func (me *migrator) LockTTL(ttl time.Duration) Migrator {
+	if ttl > 0 {
+		me.lockTTL = ttl
+	}
+	return me
+}
+
+func (me *migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	applied, err := me.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]MigrationStatus, 0, len(me.migrations))
+	for _, m := range me.migrations {
+		st := MigrationStatus{ID: m.ID, Description: m.Description, Checksum: migrationChecksum(m)}
+		if rec, ok := applied[m.ID]; ok {
+			appliedAt := rec.AppliedAt
+			st.Applied = true
+			st.AppliedAt = &appliedAt
+			st.Changed = rec.Checksum != st.Checksum
+			delete(applied, m.ID)
+		}
+		res = append(res, st)
+	}
+	for _, rec := range applied {
+		appliedAt := rec.AppliedAt
+		res = append(res, MigrationStatus{
+			ID:        rec.ID,
+			Applied:   true,
+			AppliedAt: &appliedAt,
+			Checksum:  rec.Checksum,
+			Missing:   true,
+		})
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].ID < res[j].ID })
+	return res, nil
+}
+
+func (me *migrator) Up(ctx context.Context, n int) ([]string, error) {
+	return me.locked(ctx, func(ctx context.Context, applied map[string]migrationRecord) ([]string, error) {
+		return me.up(ctx, applied, n, "")
+	})
+}
+
+func (me *migrator) Down(ctx context.Context, n int) ([]string, error) {
+	return me.locked(ctx, func(ctx context.Context, applied map[string]migrationRecord) ([]string, error) {
+		return me.down(ctx, applied, n, "")
+	})
+}
+
+func (me *migrator) To(ctx context.Context, id string) ([]string, error) {
+	if me.find(id) == nil {
+		return nil, fmt.Errorf("%w: %s", ErrMigrationNotFound, id)
+	}
+	return me.locked(ctx, func(ctx context.Context, applied map[string]migrationRecord) ([]string, error) {
+		if _, ok := applied[id]; ok {
+			return me.down(ctx, applied, 0, id)
+		}
+		return me.up(ctx, applied, 0, id)
+	})
+}
+
+// up apply pending migrations, stop after n migrations or after migration with until id
+func (me *migrator) up(ctx context.Context, applied map[string]migrationRecord, n int, until string) ([]string, error) {
+	res := make([]string, 0)
+	for _, m := range me.migrations {
+		if n > 0 && len(res) >= n {
+			break
+		}
+		if _, ok := applied[m.ID]; ok {
+			continue
+		}
+		if err := me.run(ctx, m, true); err != nil {
+			return res, err
+		}
+		res = append(res, m.ID)
+		if m.ID == until {
+			break
+		}
+	}
+	return res, nil
+}
+
+// down rollback applied migrations, stop after n migrations or before migration with until id
+func (me *migrator) down(ctx context.Context, applied map[string]migrationRecord, n int, until string) ([]string, error) {
+	res := make([]string, 0)
+	for i := len(me.migrations) - 1; i >= 0; i-- {
+		m := me.migrations[i]
+		if m.ID == until || (n > 0 && len(res) >= n) {
+			break
+		}
+		if _, ok := applied[m.ID]; !ok {
+			continue
+		}
+		if m.Down == nil {
+			return res, fmt.Errorf("%w: %s", ErrIrreversibleMigration, m.ID)
+		}
+		if err := me.run(ctx, m, false); err != nil {
+			return res, err
+		}
+		res = append(res, m.ID)
+	}
+	return res, nil
+}
+
+// run execute migration and update tracking collection in same session
+func (me *migrator) run(ctx context.Context, m Migration, up bool) error {
+	sess, err := me.db.Client().StartSession()
+	if err != nil {
+		return err
+	}
+	defer sess.EndSession(ctx)
+
+	fn := func(sc mongo.SessionContext) error {
+		col := me.tracking
+		if up {
+			if err := m.Up(sc, me.db); err != nil {
+				return fmt.Errorf("mongoutils: migration %s up failed: %w", m.ID, err)
+			}
+			_, err := col.InsertOne(sc, migrationRecord{
+				ID:        m.ID,
+				Checksum:  migrationChecksum(m),
+				AppliedAt: time.Now().UTC(),
+			})
+			return err
+		}
+		if err := m.Down(sc, me.db); err != nil {
+			return fmt.Errorf("mongoutils: migration %s down failed: %w", m.ID, err)
+		}
+		_, err := col.DeleteOne(sc, primitive.M{"_id": m.ID})
+		return err
+	}
+
+	if m.Tx != nil {
+		_, err = sess.WithTransaction(ctx, func(sc mongo.SessionContext) (any, error) {
+			return nil, fn(sc)
+		}, m.Tx)
+		return err
+	}
+	return mongo.WithSession(ctx, sess, fn)
+}
+
+// locked run fn while holding migration lock
+//
+// lock expiration extended by heartbeat every third of lock ttl, fn context canceled if lock lost
+func (me *migrator) locked(ctx context.Context, fn func(ctx context.Context, applied map[string]migrationRecord) ([]string, error)) ([]string, error) {
+	owner, err := me.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer me.unlock(owner)
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	lost := make(chan struct{})
+	done := make(chan struct{})
+	defer close(done)
+	go me.heartbeat(runCtx, owner, done, func() {
+		close(lost)
+		cancel()
+	})
+
+	applied, err := me.applied(runCtx)
+	var res []string
+	if err == nil {
+		res, err = fn(runCtx, applied)
+	}
+	if err != nil {
+		select {
+		case <-lost:
+			err = fmt.Errorf("%w: %v", ErrMigrationLockLost, err)
+		default:
+		}
+	}
+	return res, err
+}
+
+// heartbeat extend lock expiration until done, onLost called if lock owned by another instance or expired
+func (me *migrator) heartbeat(ctx context.Context, owner string, done <-chan struct{}, onLost func()) {
+	ticker := time.NewTicker(me.lockTTL / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			now := time.Now().UTC()
+			res, err := me.lockColl.UpdateOne(
+				ctx,
+				primitive.M{"_id": "lock", "owner": owner, "expires_at": primitive.M{"$gte": now}},
+				primitive.M{"$set": primitive.M{"expires_at": now.Add(me.lockTTL)}},
+			)
+			if err == nil && res.MatchedCount == 0 {
+				onLost()
+				return
+			}
+		}
+	}
+}
+
+func (me *migrator) lock(ctx context.Context) (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	owner := hex.EncodeToString(buf)
+	now := time.Now().UTC()
+	_, err := me.lockColl.UpdateOne(
+		ctx,
+		primitive.M{"_id": "lock", "expires_at": primitive.M{"$lt": now}},
+		primitive.M{"$set": primitive.M{"owner": owner, "locked_at": now, "expires_at": now.Add(me.lockTTL)}},
+		options.Update().SetUpsert(true),
+	)
+	if mongo.IsDuplicateKeyError(err) {
+		return "", ErrMigrationLocked
+	}
+	return owner, err
+}
+
+// unlock release lock only if still owned by owner
+func (me *migrator) unlock(owner string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, _ = me.lockColl.DeleteOne(ctx, primitive.M{"_id": "lock", "owner": owner})
+}
+
+func (me *migrator) applied(ctx context.Context) (map[string]migrationRecord, error) {
+	cursor, err := me.tracking.Find(ctx, primitive.M{})
+	if err != nil {
+		return nil, err
+	}
+	records := make([]migrationRecord, 0)
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+	res := make(map[string]migrationRecord, len(records))
+	for _, rec := range records {
+		res[rec.ID] = rec
+	}
+	return res, nil
+}
+
+func (me *migrator) find(id string) *Migration {
+	for i := range me.migrations {
+		if me.migrations[i].ID == id {
+			return &me.migrations[i]
+		}
+	}
+	return nil
+}
+
+// migrationChecksum hash id, description and version, function bodies can not be hashed
+func migrationChecksum(m Migration) string {
+	sum := sha256.Sum256([]byte(m.ID + "\n" + m.Description + "\n" + m.Version))
+	return hex.EncodeToString(sum[:])
+}
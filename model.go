@@ -26,22 +26,46 @@ func (me Model) IsDeletable() bool {
 }
 
 // BeforeInsert function to call before insert
-func (me *Model) BeforeInsert(ctx context.Context) {}
+//
+// returning error abort insert
+func (me *Model) BeforeInsert(ctx context.Context) error {
+	return nil
+}
 
 // AfterInsert function to call after insert
-func (me Model) AfterInsert(ctx context.Context) {}
+//
+// returning error inside transaction abort transaction
+func (me Model) AfterInsert(ctx context.Context) error {
+	return nil
+}
 
 // BeforeUpdate function to call before update
-func (me *Model) BeforeUpdate(ctx context.Context) {}
+//
+// returning error abort update
+func (me *Model) BeforeUpdate(ctx context.Context) error {
+	return nil
+}
 
 // AfterUpdate function to call after update
-func (me Model) AfterUpdate(old any, ctx context.Context) {}
+//
+// returning error inside transaction abort transaction
+func (me Model) AfterUpdate(old any, ctx context.Context) error {
+	return nil
+}
 
 // BeforeDelete function to call before delete
-func (me *Model) BeforeDelete(ctx context.Context) {}
+//
+// returning error abort delete
+func (me *Model) BeforeDelete(ctx context.Context) error {
+	return nil
+}
 
 // AfterDelete function to call after delete
-func (me Model) AfterDelete(ctx context.Context) {}
+//
+// returning error inside transaction abort transaction
+func (me Model) AfterDelete(ctx context.Context) error {
+	return nil
+}
 
 // Cleanup document before save
 //
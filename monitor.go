@@ -0,0 +1,28 @@
+package mongoutils
+
+import "time"
+
+// Logger slog style structured logger, args are alternating key and value pairs
+//
+// *slog.Logger satisfies this interface
+type Logger interface {
+	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+// MonitorOptions command monitor options
+type MonitorOptions struct {
+	// Logger command logger, standard logger used if nil
+	Logger Logger
+	// SlowThreshold commands running longer logged as warning, slow detection disabled if zero
+	SlowThreshold time.Duration
+	// Sample log every nth succeeded command, slow and failed commands always logged, all commands logged if zero or one
+	Sample int64
+	// SlowOnly log slow and failed commands only
+	SlowOnly bool
+	// Redact field names or dotted paths replaced with "[REDACTED]" at any depth of rendered command, e.g. "password" or "profile.phone"
+	Redact []string
+	// MaxLength rendered command truncated to length, unlimited if zero
+	MaxLength int
+}
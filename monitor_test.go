@@ -0,0 +1,75 @@
+package mongoutils_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bopher/mongoutils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+type memoryLogger struct {
+	lines []string
+}
+
+func (me *memoryLogger) log(level, msg string, args []any) {
+	me.lines = append(me.lines, fmt.Sprint(level, " ", msg, " ", args))
+}
+
+func (me *memoryLogger) Info(msg string, args ...any)  { me.log("INFO", msg, args) }
+func (me *memoryLogger) Warn(msg string, args ...any)  { me.log("WARN", msg, args) }
+func (me *memoryLogger) Error(msg string, args ...any) { me.log("ERROR", msg, args) }
+
+func TestCommandMonitor(t *testing.T) {
+	ctx := context.TODO()
+	logger := new(memoryLogger)
+	monitor := mongoutils.NewCommandMonitor(mongoutils.MonitorOptions{
+		Logger:        logger,
+		SlowThreshold: 100 * time.Millisecond,
+		Sample:        2,
+		Redact:        []string{"password", "profile.phone"},
+	})
+	run := func(id int64, cmd any, duration time.Duration, reply any, failure string) string {
+		raw, _ := bson.Marshal(cmd)
+		monitor.Started(ctx, &event.CommandStartedEvent{Command: raw, DatabaseName: "app", CommandName: "find", RequestID: id, ConnectionID: "c1"})
+		finished := event.CommandFinishedEvent{DurationNanos: duration.Nanoseconds(), CommandName: "find", RequestID: id, ConnectionID: "c1"}
+		before := len(logger.lines)
+		if failure != "" {
+			monitor.Failed(ctx, &event.CommandFailedEvent{CommandFinishedEvent: finished, Failure: failure})
+		} else {
+			res, _ := bson.Marshal(reply)
+			monitor.Succeeded(ctx, &event.CommandSucceededEvent{CommandFinishedEvent: finished, Reply: res})
+		}
+		if len(logger.lines) == before {
+			return ""
+		}
+		return logger.lines[len(logger.lines)-1]
+	}
+
+	find := mongoutils.Map("find", "users", "filter", mongoutils.Map("password", "secret", "profile.phone", "555", "profile", mongoutils.Map("phone", "555", "bio", "dev")), "lsid", mongoutils.Map("id", 1))
+	reply := mongoutils.Map("cursor", mongoutils.Map("firstBatch", mongoutils.Array(1, 2, 3)), "ok", 1)
+	line := run(1, find, time.Millisecond, reply, "")
+	if !strings.HasPrefix(line, "INFO mongo command") || !strings.Contains(line, "count 3") || !strings.Contains(line, "database app") {
+		t.Log(line)
+		t.Fatal("fail Succeeded")
+	}
+	if strings.Contains(line, "secret") || strings.Contains(line, "555") || strings.Contains(line, "lsid") || !strings.Contains(line, "dev") {
+		t.Log(line)
+		t.Fatal("fail Redact")
+	}
+	if line := run(2, find, time.Millisecond, reply, ""); line != "" {
+		t.Fatal("fail Sample")
+	}
+	if line := run(3, find, time.Second, mongoutils.Map("n", 5, "nModified", 2), ""); !strings.HasPrefix(line, "WARN mongo slow command") || !strings.Contains(line, "n 5 nModified 2") {
+		t.Log(line)
+		t.Fatal("fail slow")
+	}
+	if line := run(4, find, time.Millisecond, nil, "boom"); !strings.HasPrefix(line, "ERROR mongo command failed") || !strings.Contains(line, "error boom") {
+		t.Log(line)
+		t.Fatal("fail Failed")
+	}
+}
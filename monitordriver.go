@@ -0,0 +1,190 @@
+package mongoutils
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+const redactedValue = "[REDACTED]"
+
+// monitorMetaKeys command keys not rendered
+var monitorMetaKeys = map[string]bool{
+	"lsid": true, "$clusterTime": true, "$db": true, "txnNumber": true, "autocommit": true,
+	"startTransaction": true, "$readPreference": true, "signature": true,
+}
+
+type stdLogger struct{}
+
+func (stdLogger) print(level, msg string, args []any) {
+	var sb strings.Builder
+	for i := 0; i+1 < len(args); i += 2 {
+		fmt.Fprintf(&sb, " %v=%v", args[i], args[i+1])
+	}
+	log.Printf("%s %s%s", level, msg, sb.String())
+}
+
+func (me stdLogger) Info(msg string, args ...any)  { me.print("INFO", msg, args) }
+func (me stdLogger) Warn(msg string, args ...any)  { me.print("WARN", msg, args) }
+func (me stdLogger) Error(msg string, args ...any) { me.print("ERROR", msg, args) }
+
+type monitorKey struct {
+	conn string
+	id   int64
+}
+
+type monitorCommand struct {
+	database string
+	command  bson.Raw
+}
+
+type commandMonitor struct {
+	opt     MonitorOptions
+	redact  map[string]bool
+	pending sync.Map
+	count   int64
+}
+
+func (me *commandMonitor) started(_ context.Context, evt *event.CommandStartedEvent) {
+	cmd := make(bson.Raw, len(evt.Command))
+	copy(cmd, evt.Command)
+	me.pending.Store(monitorKey{evt.ConnectionID, evt.RequestID}, monitorCommand{database: evt.DatabaseName, command: cmd})
+}
+
+func (me *commandMonitor) take(evt event.CommandFinishedEvent) monitorCommand {
+	v, _ := me.pending.LoadAndDelete(monitorKey{evt.ConnectionID, evt.RequestID})
+	cmd, _ := v.(monitorCommand)
+	return cmd
+}
+
+func (me *commandMonitor) attrs(evt event.CommandFinishedEvent, cmd monitorCommand) []any {
+	duration := time.Duration(evt.DurationNanos)
+	return []any{
+		"command", evt.CommandName,
+		"database", cmd.database,
+		"request_id", evt.RequestID,
+		"duration", duration,
+		"query", me.render(cmd.command),
+	}
+}
+
+func (me *commandMonitor) slow(evt event.CommandFinishedEvent) bool {
+	return me.opt.SlowThreshold > 0 && time.Duration(evt.DurationNanos) >= me.opt.SlowThreshold
+}
+
+func (me *commandMonitor) succeeded(_ context.Context, evt *event.CommandSucceededEvent) {
+	cmd := me.take(evt.CommandFinishedEvent)
+	slow := me.slow(evt.CommandFinishedEvent)
+	if !slow {
+		if me.opt.SlowOnly {
+			return
+		}
+		if me.opt.Sample > 1 && (atomic.AddInt64(&me.count, 1)-1)%me.opt.Sample != 0 {
+			return
+		}
+	}
+	args := append(me.attrs(evt.CommandFinishedEvent, cmd), replyCounts(evt.Reply)...)
+	if slow {
+		me.opt.Logger.Warn("mongo slow command", append(args, "threshold", me.opt.SlowThreshold)...)
+	} else {
+		me.opt.Logger.Info("mongo command", args...)
+	}
+}
+
+func (me *commandMonitor) failed(_ context.Context, evt *event.CommandFailedEvent) {
+	cmd := me.take(evt.CommandFinishedEvent)
+	args := append(me.attrs(evt.CommandFinishedEvent, cmd), "error", evt.Failure)
+	if me.slow(evt.CommandFinishedEvent) {
+		args = append(args, "slow", true)
+	}
+	me.opt.Logger.Error("mongo command failed", args...)
+}
+
+// replyCounts get returned documents, matched and modified counts of command reply
+func replyCounts(reply bson.Raw) []any {
+	res := make([]any, 0)
+	for _, path := range [][]string{{"cursor", "firstBatch"}, {"cursor", "nextBatch"}, {"values"}} {
+		if v, err := reply.LookupErr(path...); err == nil {
+			if arr, ok := v.ArrayOK(); ok {
+				values, _ := arr.Values()
+				res = append(res, "count", len(values))
+				break
+			}
+		}
+	}
+	for _, key := range []string{"n", "nModified", "nUpserted"} {
+		if v, err := reply.LookupErr(key); err == nil {
+			if n, ok := v.AsInt64OK(); ok {
+				res = append(res, key, n)
+			}
+		}
+	}
+	return res
+}
+
+// render get redacted extended json of command without session and cluster meta keys
+func (me *commandMonitor) render(cmd bson.Raw) string {
+	if len(cmd) == 0 {
+		return ""
+	}
+	var doc primitive.D
+	if err := bson.Unmarshal(cmd, &doc); err != nil {
+		return ""
+	}
+	res := make(primitive.D, 0, len(doc))
+	for _, e := range doc {
+		if !monitorMetaKeys[e.Key] {
+			res = append(res, e)
+		}
+	}
+	out, err := bson.MarshalExtJSON(me.redactValue("", res), false, false)
+	if err != nil {
+		return ""
+	}
+	if me.opt.MaxLength > 0 && len(out) > me.opt.MaxLength {
+		return string(out[:me.opt.MaxLength]) + "..."
+	}
+	return string(out)
+}
+
+// redacted check if path or path suffix is redacted
+func (me *commandMonitor) redacted(path string) bool {
+	for f := range me.redact {
+		if path == f || strings.HasSuffix(path, "."+f) {
+			return true
+		}
+	}
+	return false
+}
+
+func (me *commandMonitor) redactValue(path string, v any) any {
+	switch val := v.(type) {
+	case primitive.D:
+		res := make(primitive.D, len(val))
+		for i, e := range val {
+			sub := joinPath(path, e.Key)
+			if me.redacted(sub) {
+				res[i] = primitive.E{Key: e.Key, Value: redactedValue}
+			} else {
+				res[i] = primitive.E{Key: e.Key, Value: me.redactValue(sub, e.Value)}
+			}
+		}
+		return res
+	case primitive.A:
+		res := make(primitive.A, len(val))
+		for i, item := range val {
+			// array items keep parent path so documents, updates and pipelines redacted by field path
+			res[i] = me.redactValue(path, item)
+		}
+		return res
+	}
+	return v
+}
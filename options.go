@@ -0,0 +1,262 @@
+package mongoutils
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// OptionFunc collection operation option modifier
+//
+// options not supported by operation ignored
+type OptionFunc func(c *opConfig)
+
+type opConfig struct {
+	collation    *options.Collation
+	maxTime      *time.Duration
+	hint         any
+	comment      *string
+	projection   any
+	sort         any
+	noDiskUse    bool
+	upsert       bool
+	arrayFilters []any
+	returnAfter  bool
+	bypass       bool
+}
+
+func newOpConfig(opts []OptionFunc) *opConfig {
+	c := new(opConfig)
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// OptCollation set collation
+func OptCollation(collation *options.Collation) OptionFunc {
+	return func(c *opConfig) {
+		c.collation = collation
+	}
+}
+
+// OptMaxTime set maxTimeMS
+func OptMaxTime(d time.Duration) OptionFunc {
+	return func(c *opConfig) {
+		c.maxTime = &d
+	}
+}
+
+// OptHint set index hint (index name or keys document)
+func OptHint(hint any) OptionFunc {
+	return func(c *opConfig) {
+		c.hint = hint
+	}
+}
+
+// OptComment set comment (find and aggregate only)
+func OptComment(comment string) OptionFunc {
+	return func(c *opConfig) {
+		c.comment = &comment
+	}
+}
+
+// OptProjection set projection
+func OptProjection(projection any) OptionFunc {
+	return func(c *opConfig) {
+		c.projection = projection
+	}
+}
+
+// OptSort set sort (find one and find one and update only)
+func OptSort(sort any) OptionFunc {
+	return func(c *opConfig) {
+		c.sort = sort
+	}
+}
+
+// OptNoDiskUse disable forced allowDiskUse of find and aggregate
+func OptNoDiskUse() OptionFunc {
+	return func(c *opConfig) {
+		c.noDiskUse = true
+	}
+}
+
+// OptUpsert enable upsert
+func OptUpsert() OptionFunc {
+	return func(c *opConfig) {
+		c.upsert = true
+	}
+}
+
+// OptArrayFilters set update array filters
+func OptArrayFilters(filters ...any) OptionFunc {
+	return func(c *opConfig) {
+		c.arrayFilters = filters
+	}
+}
+
+// OptReturnAfter return document after update in find one and update
+func OptReturnAfter() OptionFunc {
+	return func(c *opConfig) {
+		c.returnAfter = true
+	}
+}
+
+// OptBypassValidation bypass document validation
+func OptBypassValidation() OptionFunc {
+	return func(c *opConfig) {
+		c.bypass = true
+	}
+}
+
+// FindOneOption generate find one option
+func FindOneOption(sort any, opts ...OptionFunc) *options.FindOneOptions {
+	c := newOpConfig(opts)
+	opt := options.FindOne()
+	if sort != nil {
+		opt.SetSort(sort)
+	} else if c.sort != nil {
+		opt.SetSort(c.sort)
+	}
+	if c.projection != nil {
+		opt.SetProjection(c.projection)
+	}
+	if c.collation != nil {
+		opt.SetCollation(c.collation)
+	}
+	if c.maxTime != nil {
+		opt.SetMaxTime(*c.maxTime)
+	}
+	if c.hint != nil {
+		opt.SetHint(c.hint)
+	}
+	if c.comment != nil {
+		opt.SetComment(*c.comment)
+	}
+	return opt
+}
+
+// UpdateOption generate update one and update many option
+func UpdateOption(opts ...OptionFunc) *options.UpdateOptions {
+	c := newOpConfig(opts)
+	opt := options.Update()
+	if c.upsert {
+		opt.SetUpsert(true)
+	}
+	if len(c.arrayFilters) > 0 {
+		opt.SetArrayFilters(options.ArrayFilters{Filters: c.arrayFilters})
+	}
+	if c.collation != nil {
+		opt.SetCollation(c.collation)
+	}
+	if c.hint != nil {
+		opt.SetHint(c.hint)
+	}
+	if c.bypass {
+		opt.SetBypassDocumentValidation(true)
+	}
+	return opt
+}
+
+// FindOneAndUpdateOption generate find one and update option
+func FindOneAndUpdateOption(opts ...OptionFunc) *options.FindOneAndUpdateOptions {
+	c := newOpConfig(opts)
+	opt := options.FindOneAndUpdate()
+	if c.returnAfter {
+		opt.SetReturnDocument(options.After)
+	}
+	if c.upsert {
+		opt.SetUpsert(true)
+	}
+	if c.projection != nil {
+		opt.SetProjection(c.projection)
+	}
+	if c.sort != nil {
+		opt.SetSort(c.sort)
+	}
+	if len(c.arrayFilters) > 0 {
+		opt.SetArrayFilters(options.ArrayFilters{Filters: c.arrayFilters})
+	}
+	if c.collation != nil {
+		opt.SetCollation(c.collation)
+	}
+	if c.maxTime != nil {
+		opt.SetMaxTime(*c.maxTime)
+	}
+	if c.hint != nil {
+		opt.SetHint(c.hint)
+	}
+	if c.bypass {
+		opt.SetBypassDocumentValidation(true)
+	}
+	return opt
+}
+
+// DeleteOption generate delete one and delete many option
+func DeleteOption(opts ...OptionFunc) *options.DeleteOptions {
+	c := newOpConfig(opts)
+	opt := options.Delete()
+	if c.collation != nil {
+		opt.SetCollation(c.collation)
+	}
+	if c.hint != nil {
+		opt.SetHint(c.hint)
+	}
+	return opt
+}
+
+// CountOption generate count documents option
+func CountOption(skip int64, limit int64, opts ...OptionFunc) *options.CountOptions {
+	c := newOpConfig(opts)
+	opt := options.Count()
+	if skip > 0 {
+		opt.SetSkip(skip)
+	}
+	if limit > 0 {
+		opt.SetLimit(limit)
+	}
+	if c.collation != nil {
+		opt.SetCollation(c.collation)
+	}
+	if c.maxTime != nil {
+		opt.SetMaxTime(*c.maxTime)
+	}
+	if c.hint != nil {
+		opt.SetHint(c.hint)
+	}
+	return opt
+}
+
+// DistinctOption generate distinct option
+func DistinctOption(opts ...OptionFunc) *options.DistinctOptions {
+	c := newOpConfig(opts)
+	opt := options.Distinct()
+	if c.collation != nil {
+		opt.SetCollation(c.collation)
+	}
+	if c.maxTime != nil {
+		opt.SetMaxTime(*c.maxTime)
+	}
+	return opt
+}
+
+// BulkWriteOption generate bulk write option
+func BulkWriteOption(ordered bool, opts ...OptionFunc) *options.BulkWriteOptions {
+	c := newOpConfig(opts)
+	opt := options.BulkWrite().SetOrdered(ordered)
+	if c.bypass {
+		opt.SetBypassDocumentValidation(true)
+	}
+	return opt
+}
+
+// InsertManyOption generate insert many option
+func InsertManyOption(ordered bool, opts ...OptionFunc) *options.InsertManyOptions {
+	c := newOpConfig(opts)
+	opt := options.InsertMany().SetOrdered(ordered)
+	if c.bypass {
+		opt.SetBypassDocumentValidation(true)
+	}
+	return opt
+}
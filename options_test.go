@@ -0,0 +1,60 @@
+package mongoutils_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bopher/mongoutils"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestOptions(t *testing.T) {
+	collation := &options.Collation{Locale: "en"}
+
+	// FindOption
+	find := mongoutils.FindOption(mongoutils.Map("name", 1), 10, 5,
+		mongoutils.OptProjection(mongoutils.Map("name", 1)),
+		mongoutils.OptCollation(collation),
+		mongoutils.OptHint("name_1"),
+		mongoutils.OptMaxTime(time.Second),
+		mongoutils.OptComment("list"),
+	)
+	if find.AllowDiskUse == nil || !*find.AllowDiskUse || *find.Skip != 10 || *find.Limit != 5 {
+		t.Fatal("fail FindOption")
+	}
+	if find.Projection == nil || find.Collation != collation || find.Hint != "name_1" || *find.MaxTime != time.Second || *find.Comment != "list" {
+		t.Fatal("fail FindOption options")
+	}
+	if find = mongoutils.FindOption(nil, 0, 0, mongoutils.OptNoDiskUse()); find.AllowDiskUse != nil {
+		t.Fatal("fail FindOption OptNoDiskUse")
+	}
+	if agg := mongoutils.AggregateOption(mongoutils.OptNoDiskUse()); agg.AllowDiskUse != nil {
+		t.Fatal("fail AggregateOption OptNoDiskUse")
+	}
+
+	// UpdateOption
+	update := mongoutils.UpdateOption(mongoutils.OptUpsert(), mongoutils.OptArrayFilters(mongoutils.Map("e.id", 1)), mongoutils.OptHint("_id_"))
+	if !*update.Upsert || len(update.ArrayFilters.Filters) != 1 || update.Hint != "_id_" {
+		t.Fatal("fail UpdateOption")
+	}
+
+	// FindOneAndUpdateOption
+	fau := mongoutils.FindOneAndUpdateOption(mongoutils.OptReturnAfter(), mongoutils.OptUpsert(), mongoutils.OptProjection(mongoutils.Map("name", 1)))
+	if *fau.ReturnDocument != options.After || !*fau.Upsert || fau.Projection == nil {
+		t.Fatal("fail FindOneAndUpdateOption")
+	}
+
+	// CountOption
+	count := mongoutils.CountOption(0, 100, mongoutils.OptMaxTime(time.Second))
+	if count.Skip != nil || *count.Limit != 100 || *count.MaxTime != time.Second {
+		t.Fatal("fail CountOption")
+	}
+
+	// BulkWriteOption and InsertManyOption
+	if bulk := mongoutils.BulkWriteOption(false); *bulk.Ordered {
+		t.Fatal("fail BulkWriteOption")
+	}
+	if insert := mongoutils.InsertManyOption(true, mongoutils.OptBypassValidation()); !*insert.Ordered || !*insert.BypassDocumentValidation {
+		t.Fatal("fail InsertManyOption")
+	}
+}
@@ -34,6 +34,10 @@ type MongoPipeline interface {
 	MergeRoot(fields ...any) MongoPipeline
 	// UnProject generate $project stage to remove fields from result
 	UnProject(fields ...string) MongoPipeline
+	// Project add $project stage from projection builder
+	//
+	// invalid projection added as $invalidProjection stage rejected by server
+	Project(p MongoProjection) MongoPipeline
 	// Build generate mongo pipeline
 	Build() mongo.Pipeline
 }
@@ -1,6 +1,8 @@
 package mongoutils_test
 
 import (
+	"context"
+	"errors"
 	"testing"
 
 	"github.com/bopher/mongoutils"
@@ -157,4 +159,27 @@ func TestPipeline(t *testing.T) {
 		t.Log(v)
 		t.Fatal("fail UnProject")
 	}
+
+	// Project
+	v, err = pretty(mongoutils.NewPipe().Project(mongoutils.NewProjection().Include("name")).Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != `[[{"Key":"$project","Value":[{"Key":"name","Value":1}]}]]` {
+		t.Log(v)
+		t.Fatal("fail Project")
+	}
+
+	// Project invalid
+	pipe := mongoutils.NewPipe().Project(mongoutils.NewProjection().Include("name").Exclude("email"))
+	if stages := pipe.Build(); len(stages) != 1 || stages[0][0].Key != "$invalidProjection" {
+		t.Fatal("fail Project invalid", stages)
+	}
+	coll := mongoutils.NewMemoryCollection("users")
+	if _, err := coll.Aggregate(context.TODO(), pipe.Build()); err == nil {
+		t.Fatal("fail invalid pipeline run")
+	}
+	if _, err := mongoutils.Aggregate[map[string]any](context.TODO(), coll, pipe); !errors.Is(err, mongoutils.ErrInvalidProjection) {
+		t.Fatal("fail Aggregate invalid pipeline", err)
+	}
 }
@@ -6,6 +6,7 @@ import (
 
 type mPipe struct {
 	data mongo.Pipeline
+	err  error
 }
 
 func (me *mPipe) Add(cb func(d MongoDoc) MongoDoc) MongoPipeline {
@@ -133,6 +134,33 @@ func (me *mPipe) UnProject(fields ...string) MongoPipeline {
 	})
 }
 
+func (me *mPipe) Project(p MongoProjection) MongoPipeline {
+	if err := p.Err(); err != nil {
+		if me.err == nil {
+			me.err = err
+		}
+		return me.Add(func(d MongoDoc) MongoDoc {
+			return d.Add("$invalidProjection", err.Error())
+		})
+	}
+	return me.Add(func(d MongoDoc) MongoDoc {
+		return d.Add("$project", p.Build())
+	})
+}
+
+// Err get first invalid stage error
+func (me mPipe) Err() error {
+	return me.err
+}
+
+// pipeErr get builder error of pipeline
+func pipeErr(pipe MongoPipeline) error {
+	if p, ok := pipe.(interface{ Err() error }); ok {
+		return p.Err()
+	}
+	return nil
+}
+
 func (me mPipe) Build() mongo.Pipeline {
 	return me.data
 }
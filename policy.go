@@ -0,0 +1,35 @@
+package mongoutils
+
+import (
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ErrFieldForbidden field not readable or writable by role
+var ErrFieldForbidden = errors.New("mongoutils: field forbidden")
+
+// FieldPolicy role based field access policy of model
+//
+// fields not readable by role removed from projections and pipelines, masked fields returned as $concat of visible prefix and mask
+type FieldPolicy interface {
+	// Read add readable field paths of role, "*" for all model fields
+	Read(role string, fields ...string) FieldPolicy
+	// Write add writable field paths of role, "*" for all fields
+	Write(role string, fields ...string) FieldPolicy
+	// Mask add masked readable field of role, first visible characters kept and mask appended
+	Mask(role string, field string, visible int, mask string) FieldPolicy
+	// Projection generate inclusion projection of readable and masked fields, optionally limited to requested fields
+	//
+	// returns ErrFieldForbidden if role has no readable field
+	Projection(role string, fields ...string) (MongoProjection, error)
+	// Pipeline generate pipeline starting with role projection stage followed by pipe stages
+	Pipeline(role string, pipe MongoPipeline) (MongoPipeline, error)
+	// CheckWrite check update operators, update pipeline or replacement document fields are writable by role
+	//
+	// sub document value writable only if parent path writable, replacement document requires all model fields writable.
+	// returns ErrFieldForbidden for not writable fields
+	CheckWrite(role string, update any) error
+	// Set generate $set map after CheckWrite
+	Set(role string, v any) (primitive.M, error)
+}
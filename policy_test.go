@@ -0,0 +1,106 @@
+package mongoutils_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/bopher/mongoutils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type policyUser struct {
+	ID      int    `bson:"_id"`
+	Name    string `bson:"name"`
+	Email   string `bson:"email"`
+	Salary  int    `bson:"salary"`
+	Profile struct {
+		Bio   string `bson:"bio"`
+		Phone string `bson:"phone"`
+	} `bson:"profile"`
+	mongoutils.Model `bson:",inline"`
+}
+
+func TestFieldPolicy(t *testing.T) {
+	ctx := context.TODO()
+	policy := mongoutils.NewFieldPolicy(policyUser{}).
+		Read("admin", "*").
+		Write("admin", "*").
+		Read("support", "_id", "name", "profile").
+		Mask("support", "email", 2, "***").
+		Mask("support", "profile.phone", 0, "hidden").
+		Write("support", "name", "profile.bio")
+
+	// Projection
+	proj, err := policy.Projection("support")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, _ := pretty(proj.Build())
+	if v != `[{"Key":"_id","Value":1},{"Key":"name","Value":1},{"Key":"email","Value":[{"Key":"$cond","Value":[[{"Key":"$eq","Value":[[{"Key":"$type","Value":"$email"}],"string"]}],[{"Key":"$concat","Value":[[{"Key":"$substrCP","Value":["$email",0,2]}],"***"]}],null]}]},{"Key":"profile.bio","Value":1},{"Key":"profile.phone","Value":[{"Key":"$cond","Value":[[{"Key":"$eq","Value":[[{"Key":"$type","Value":"$profile.phone"}],"string"]}],[{"Key":"$concat","Value":[[{"Key":"$substrCP","Value":["$profile.phone",0,0]}],"hidden"]}],null]}]}]` {
+		t.Log(v)
+		t.Fatal("fail Projection")
+	}
+	if proj, _ = policy.Projection("support", "name", "salary"); len(proj.Build()) != 2 {
+		t.Fatal("fail Projection requested")
+	}
+	if _, err := policy.Projection("guest"); !errors.Is(err, mongoutils.ErrFieldForbidden) {
+		t.Fatal("fail Projection unknown role")
+	}
+
+	// Query
+	coll := mongoutils.NewMemoryCollection("users")
+	coll.InsertOne(ctx, mongoutils.Map("_id", 1, "name", "John", "email", "john@mail.com", "salary", 100, "profile", mongoutils.Map("bio", "dev", "phone", "123"), "created_at", primitive.NewDateTimeFromTime(primitive.NilObjectID.Timestamp())))
+	var doc primitive.M
+	proj, _ = policy.Projection("support")
+	if err := coll.FindOne(ctx, primitive.D{}, options.FindOne().SetProjection(proj.Build())).Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ = pretty(doc); v != `{"_id":1,"email":"jo***","name":"John","profile":{"bio":"dev","phone":"hidden"}}` {
+		t.Log(v)
+		t.Fatal("fail Query")
+	}
+	pipe, err := policy.Pipeline("admin", mongoutils.NewPipe().Match(mongoutils.Map("salary", mongoutils.Map("$gt", 50))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := mongoutils.Aggregate[primitive.M](ctx, coll, pipe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || res[0]["salary"] != int32(100) || res[0]["created_at"] == nil {
+		t.Log(res)
+		t.Fatal("fail Pipeline")
+	}
+
+	// Write
+	if _, err := policy.Set("support", mongoutils.Map("name", "Jack", "profile.bio", "ops")); err != nil {
+		t.Fatal(err)
+	}
+	for _, update := range []any{
+		mongoutils.Set(mongoutils.Map("salary", 1)),
+		mongoutils.Set(mongoutils.Map("profile", mongoutils.Map("bio", "x", "phone", "1"))),
+		mongoutils.Map("$rename", mongoutils.Map("name", "salary")),
+		mongoutils.Map("$unset", mongoutils.Map("profile.phone", "")),
+		mongoutils.Map("name", "x", "email", "y"),
+		mongoutils.Set(mongoutils.Map("profile", mongoutils.Map("bio", "x"))),
+		mongoutils.Map("profile", mongoutils.Map("bio", "x")),
+		mongoutils.Map("name", "x"),
+		mongoutils.Array(mongoutils.Map("$set", mongoutils.Map("name", "x"))),
+	} {
+		if err := policy.CheckWrite("support", update); !errors.Is(err, mongoutils.ErrFieldForbidden) {
+			t.Log(update)
+			t.Fatal("fail Write forbidden")
+		}
+	}
+	if err := policy.CheckWrite("support", mongoutils.Set(mongoutils.Map("profile.bio", "x"))); err != nil {
+		t.Fatal("fail Write nested")
+	}
+	if err := policy.CheckWrite("admin", mongoutils.Map("name", "x", "profile", mongoutils.Map("bio", "x"))); err != nil {
+		t.Fatal("fail Write admin replacement")
+	}
+	if err := policy.CheckWrite("admin", mongoutils.Map("$inc", mongoutils.Map("salary", 1))); err != nil {
+		t.Fatal("fail Write admin")
+	}
+}
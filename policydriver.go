@@ -0,0 +1,295 @@
+package mongoutils
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type policyField struct {
+	path     string
+	children []*policyField
+}
+
+type policyMask struct {
+	visible int
+	mask    string
+}
+
+type policyRole struct {
+	read  map[string]bool
+	write map[string]bool
+	masks map[string]policyMask
+}
+
+type fieldPolicy struct {
+	fields []*policyField
+	roles  map[string]*policyRole
+}
+
+// policyFields collect document fields tree from struct bson tags
+func policyFields(t reflect.Type, prefix string, visiting map[reflect.Type]bool) []*policyField {
+	if visiting[t] {
+		return nil
+	}
+	visiting[t] = true
+	defer delete(visiting, t)
+	res := make([]*policyField, 0)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, inline, skip := bsonField(f)
+		if skip {
+			continue
+		}
+		ft := indirectType(f.Type)
+		if inline {
+			if ft.Kind() == reflect.Struct {
+				res = append(res, policyFields(ft, prefix, visiting)...)
+			}
+			continue
+		}
+		field := &policyField{path: joinPath(prefix, name)}
+		if ft.Kind() == reflect.Struct && !isLeafStruct(ft) {
+			field.children = policyFields(ft, field.path, visiting)
+		}
+		res = append(res, field)
+	}
+	return res
+}
+
+func (me *fieldPolicy) role(role string) *policyRole {
+	r, ok := me.roles[role]
+	if !ok {
+		r = &policyRole{read: make(map[string]bool), write: make(map[string]bool), masks: make(map[string]policyMask)}
+		me.roles[role] = r
+	}
+	return r
+}
+
+func (me *fieldPolicy) Read(role string, fields ...string) FieldPolicy {
+	r := me.role(role)
+	for _, f := range fields {
+		r.read[f] = true
+	}
+	return me
+}
+
+func (me *fieldPolicy) Write(role string, fields ...string) FieldPolicy {
+	r := me.role(role)
+	for _, f := range fields {
+		r.write[f] = true
+	}
+	return me
+}
+
+func (me *fieldPolicy) Mask(role string, field string, visible int, mask string) FieldPolicy {
+	me.role(role).masks[field] = policyMask{visible: visible, mask: mask}
+	return me
+}
+
+// covered check if path or parent path in set
+func covered(set map[string]bool, path string) bool {
+	if set["*"] || set[path] {
+		return true
+	}
+	for i := strings.LastIndexByte(path, '.'); i > 0; i = strings.LastIndexByte(path[:i], '.') {
+		if set[path[:i]] {
+			return true
+		}
+	}
+	return false
+}
+
+// hasChild check if set has sub path of path
+func hasChild[T any](set map[string]T, path string) bool {
+	for k := range set {
+		if strings.HasPrefix(k, path+".") {
+			return true
+		}
+	}
+	return false
+}
+
+func (me *fieldPolicy) Projection(role string, fields ...string) (MongoProjection, error) {
+	r, ok := me.roles[role]
+	if !ok {
+		return nil, fmt.Errorf("%w: role %q has no readable field", ErrFieldForbidden, role)
+	}
+	var requested map[string]bool
+	if len(fields) > 0 {
+		requested = make(map[string]bool, len(fields))
+		for _, f := range fields {
+			requested[f] = true
+		}
+	}
+	res := NewProjection()
+	included, hasID := false, false
+	var walk func(nodes []*policyField)
+	walk = func(nodes []*policyField) {
+		for _, n := range nodes {
+			full := requested == nil || covered(requested, n.path)
+			if !full && !hasChild(requested, n.path) {
+				continue
+			}
+			if m, ok := r.masks[n.path]; ok {
+				if full {
+					res.Computed(n.path, maskExpr(n.path, m))
+					included = true
+				}
+				continue
+			}
+			readable := covered(r.read, n.path)
+			masked := hasChild(r.masks, n.path)
+			if readable && full && !masked {
+				res.Include(n.path)
+				included = true
+				hasID = hasID || n.path == "_id"
+				continue
+			}
+			if len(n.children) > 0 && (readable || masked || hasChild(r.read, n.path)) {
+				walk(n.children)
+			}
+		}
+	}
+	walk(me.fields)
+	if !included {
+		return nil, fmt.Errorf("%w: role %q has no readable field", ErrFieldForbidden, role)
+	}
+	if !hasID {
+		res.Exclude("_id")
+	}
+	return res, res.Err()
+}
+
+// maskExpr generate $concat mask expression, non string values masked as null
+func maskExpr(path string, m policyMask) primitive.D {
+	field := "$" + path
+	concat := primitive.D{{Key: "$concat", Value: primitive.A{
+		primitive.D{{Key: "$substrCP", Value: primitive.A{field, 0, m.visible}}},
+		m.mask,
+	}}}
+	isString := primitive.D{{Key: "$eq", Value: primitive.A{primitive.D{{Key: "$type", Value: field}}, "string"}}}
+	return primitive.D{{Key: "$cond", Value: primitive.A{isString, concat, nil}}}
+}
+
+func (me *fieldPolicy) Pipeline(role string, pipe MongoPipeline) (MongoPipeline, error) {
+	proj, err := me.Projection(role)
+	if err != nil {
+		return nil, err
+	}
+	res := NewPipe().Project(proj)
+	if pipe != nil {
+		if err := pipeErr(pipe); err != nil {
+			return nil, err
+		}
+		for _, stage := range pipe.Build() {
+			stage := stage
+			res.Add(func(d MongoDoc) MongoDoc {
+				for _, e := range stage {
+					d.Add(e.Key, e.Value)
+				}
+				return d
+			})
+		}
+	}
+	return res, nil
+}
+
+func (me *fieldPolicy) CheckWrite(role string, update any) error {
+	r, ok := me.roles[role]
+	if !ok {
+		return fmt.Errorf("%w: role %q has no writable field", ErrFieldForbidden, role)
+	}
+	v, err := toValue(update)
+	if err != nil {
+		return err
+	}
+	forbidden := func(path string) error {
+		return fmt.Errorf("%w: role %q can not write %s", ErrFieldForbidden, role, path)
+	}
+	// parent path replace whole sub document, writable only if path itself writable
+	check := func(path string) error {
+		path = writePath(path)
+		if covered(r.write, path) {
+			return nil
+		}
+		return forbidden(path)
+	}
+
+	switch val := v.(type) {
+	case primitive.A:
+		// update pipeline can write any field
+		if !r.write["*"] {
+			return forbidden("pipeline update")
+		}
+		return nil
+	case primitive.D:
+		for _, e := range val {
+			if !strings.HasPrefix(e.Key, "$") {
+				// replacement document drop omitted fields
+				if path := me.unwritable(r, me.fields); path != "" {
+					return forbidden(path)
+				}
+				if err := check(e.Key); err != nil {
+					return err
+				}
+				continue
+			}
+			fields, _ := e.Value.(primitive.D)
+			for _, f := range fields {
+				if err := check(f.Key); err != nil {
+					return err
+				}
+				if to, ok := f.Value.(string); ok && e.Key == "$rename" {
+					if err := check(to); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: update must be a document or pipeline", ErrInvalidQuery)
+}
+
+// unwritable get first model field path not writable by role
+func (me *fieldPolicy) unwritable(r *policyRole, nodes []*policyField) string {
+	for _, n := range nodes {
+		if covered(r.write, n.path) {
+			continue
+		}
+		if len(n.children) == 0 {
+			return n.path
+		}
+		if path := me.unwritable(r, n.children); path != "" {
+			return path
+		}
+	}
+	return ""
+}
+
+// writePath remove positional and index parts of update path
+func writePath(path string) string {
+	parts := strings.Split(path, ".")
+	res := parts[:0]
+	for _, p := range parts {
+		if strings.HasPrefix(p, "$") {
+			continue
+		}
+		if _, err := strconv.Atoi(p); err == nil {
+			continue
+		}
+		res = append(res, p)
+	}
+	return strings.Join(res, ".")
+}
+
+func (me *fieldPolicy) Set(role string, v any) (primitive.M, error) {
+	if err := me.CheckWrite(role, Set(v)); err != nil {
+		return nil, err
+	}
+	return Set(v), nil
+}
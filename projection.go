@@ -0,0 +1,29 @@
+package mongoutils
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// MongoProjection projection doc (primitive.D) builder
+//
+// inclusion and exclusion can not mixed except _id exclusion
+type MongoProjection interface {
+	// Include add included fields
+	Include(fields ...string) MongoProjection
+	// Exclude add excluded fields
+	Exclude(fields ...string) MongoProjection
+	// Positional add positional $ projection for array field
+	Positional(field string) MongoProjection
+	// Slice add $slice projection with limit (negative limit for last items)
+	Slice(field string, limit int) MongoProjection
+	// SliceRange add $slice projection with skip and limit
+	SliceRange(field string, skip int, limit int) MongoProjection
+	// ElemMatch add $elemMatch projection
+	ElemMatch(field string, cond any) MongoProjection
+	// Meta add $meta projection
+	Meta(field string, meta string) MongoProjection
+	// Computed add computed field with expression
+	Computed(field string, expr any) MongoProjection
+	// Err get illegal inclusion and exclusion mixing error
+	Err() error
+	// Build generate projection doc
+	Build() primitive.D
+}
@@ -0,0 +1,57 @@
+package mongoutils_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bopher/mongoutils"
+)
+
+type projectionDTO struct {
+	mongoutils.Model `bson:",inline"`
+	Name             string `bson:"name"`
+	Email            string `bson:"email,omitempty"`
+	Secret           string `bson:"-"`
+}
+
+func TestProjection(t *testing.T) {
+	var v string
+	var err error
+
+	// Include
+	p := mongoutils.NewProjection().
+		Include("name", "items").
+		Exclude("_id").
+		Positional("tags").
+		Slice("comments", -5).
+		SliceRange("logs", 10, 5).
+		ElemMatch("scores", mongoutils.Map("$gt", 10)).
+		Meta("score", "textScore").
+		Computed("full_name", mongoutils.Map("$concat", mongoutils.Array("$first", " ", "$last")))
+	if p.Err() != nil {
+		t.Fatal(p.Err())
+	}
+	v, err = pretty(p.Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != `[{"Key":"name","Value":1},{"Key":"items","Value":1},{"Key":"_id","Value":0},{"Key":"tags.$","Value":1},{"Key":"comments","Value":{"$slice":-5}},{"Key":"logs","Value":{"$slice":[10,5]}},{"Key":"scores","Value":{"$elemMatch":{"$gt":10}}},{"Key":"score","Value":{"$meta":"textScore"}},{"Key":"full_name","Value":{"$concat":["$first"," ","$last"]}}]` {
+		t.Log(v)
+		t.Fatal("fail Include")
+	}
+
+	// Mixing
+	if err := mongoutils.NewProjection().Include("name").Exclude("email").Err(); !errors.Is(err, mongoutils.ErrInvalidProjection) {
+		t.Fatal("fail Mixing")
+	}
+
+	// ProjectionFor
+	v, err = pretty(mongoutils.ProjectionFor(projectionDTO{}).Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != `[{"Key":"created_at","Value":1},{"Key":"updated_at","Value":1},{"Key":"name","Value":1},{"Key":"email","Value":1}]` {
+		t.Log(v)
+		t.Fatal("fail ProjectionFor")
+	}
+}
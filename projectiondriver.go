@@ -0,0 +1,128 @@
+package mongoutils
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ErrInvalidProjection invalid projection
+var ErrInvalidProjection = errors.New("mongoutils: invalid projection")
+
+type mProjection struct {
+	data      primitive.D
+	inclusion bool
+	exclusion bool
+	err       error
+}
+
+func (me *mProjection) add(k string, v any) MongoProjection {
+	for i, e := range me.data {
+		if e.Key == k {
+			me.data[i].Value = v
+			return me
+		}
+	}
+	me.data = append(me.data, primitive.E{Key: k, Value: v})
+	return me
+}
+
+func (me *mProjection) mode(field string, include bool) {
+	if include {
+		me.inclusion = true
+	} else if field != "_id" {
+		me.exclusion = true
+	}
+	if me.inclusion && me.exclusion && me.err == nil {
+		me.err = fmt.Errorf("%w: cannot mix inclusion and exclusion on %q", ErrInvalidProjection, field)
+	}
+}
+
+func (me *mProjection) Include(fields ...string) MongoProjection {
+	for _, f := range fields {
+		me.mode(f, true)
+		me.add(f, 1)
+	}
+	return me
+}
+
+func (me *mProjection) Exclude(fields ...string) MongoProjection {
+	for _, f := range fields {
+		me.mode(f, false)
+		me.add(f, 0)
+	}
+	return me
+}
+
+func (me *mProjection) Positional(field string) MongoProjection {
+	me.mode(field, true)
+	return me.add(field+".$", 1)
+}
+
+func (me *mProjection) Slice(field string, limit int) MongoProjection {
+	return me.add(field, primitive.M{"$slice": limit})
+}
+
+func (me *mProjection) SliceRange(field string, skip int, limit int) MongoProjection {
+	return me.add(field, primitive.M{"$slice": primitive.A{skip, limit}})
+}
+
+func (me *mProjection) ElemMatch(field string, cond any) MongoProjection {
+	return me.add(field, primitive.M{"$elemMatch": cond})
+}
+
+func (me *mProjection) Meta(field string, meta string) MongoProjection {
+	return me.add(field, primitive.M{"$meta": meta})
+}
+
+func (me *mProjection) Computed(field string, expr any) MongoProjection {
+	me.mode(field, true)
+	return me.add(field, expr)
+}
+
+func (me mProjection) Err() error {
+	return me.err
+}
+
+func (me mProjection) Build() primitive.D {
+	return me.data
+}
+
+// ProjectionFor generate inclusion projection from struct bson tags
+//
+// inline struct fields included, nested structs included as whole field
+func ProjectionFor(v any) MongoProjection {
+	res := NewProjection()
+	if v == nil {
+		return res
+	}
+	t := indirectType(reflect.TypeOf(v))
+	if t.Kind() != reflect.Struct {
+		return res
+	}
+	for _, f := range structFields(t) {
+		res.Include(f)
+	}
+	return res
+}
+
+// structFields get top level bson field names of struct including inline fields
+func structFields(t reflect.Type) []string {
+	res := make([]string, 0)
+	for i := 0; i < t.NumField(); i++ {
+		name, inline, skip := bsonField(t.Field(i))
+		if skip {
+			continue
+		}
+		if inline {
+			if ft := indirectType(t.Field(i).Type); ft.Kind() == reflect.Struct {
+				res = append(res, structFields(ft)...)
+			}
+			continue
+		}
+		res = append(res, name)
+	}
+	return res
+}
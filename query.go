@@ -0,0 +1,477 @@
+package mongoutils
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ErrInvalidQuery malformed filter, update or projection error
+var ErrInvalidQuery = errors.New("mongoutils: invalid query")
+
+// ErrUnsupportedQuery operator not supported by in-memory query engine error
+var ErrUnsupportedQuery = errors.New("mongoutils: unsupported query operator")
+
+// bson type aliases used by $type operator
+var typeAliases = map[string]int32{
+	"double":     1,
+	"string":     2,
+	"object":     3,
+	"array":      4,
+	"binData":    5,
+	"undefined":  6,
+	"objectId":   7,
+	"bool":       8,
+	"date":       9,
+	"null":       10,
+	"regex":      11,
+	"javascript": 13,
+	"symbol":     14,
+	"int":        16,
+	"timestamp":  17,
+	"long":       18,
+	"decimal":    19,
+	"minKey":     -1,
+	"maxKey":     127,
+}
+
+// Matches check if document satisfies filter without database
+//
+// doc can be struct (bson tags), map, primitive.D or bson.Raw. filter evaluated with mongodb
+// comparison and type ordering rules, see in-memory collection for supported operators
+func Matches(doc any, filter any) (bool, error) {
+	d, err := toDocument(doc)
+	if err != nil {
+		return false, err
+	}
+	f, err := toDocument(filter)
+	if err != nil {
+		return false, err
+	}
+	return matchDocument(d, f)
+}
+
+// matchDocument evaluate filter against document
+func matchDocument(doc primitive.D, filter primitive.D) (bool, error) {
+	for _, e := range filter {
+		ok, err := matchElement(doc, e)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func matchElement(doc primitive.D, e primitive.E) (bool, error) {
+	switch e.Key {
+	case "$and", "$or", "$nor":
+		filters, ok := e.Value.(primitive.A)
+		if !ok || len(filters) == 0 {
+			return false, fmt.Errorf("%w: %s must be a nonempty array", ErrInvalidQuery, e.Key)
+		}
+		for _, f := range filters {
+			sub, ok := f.(primitive.D)
+			if !ok {
+				return false, fmt.Errorf("%w: %s entries must be documents", ErrInvalidQuery, e.Key)
+			}
+			matched, err := matchDocument(doc, sub)
+			if err != nil {
+				return false, err
+			}
+			switch {
+			case e.Key == "$and" && !matched:
+				return false, nil
+			case e.Key == "$or" && matched:
+				return true, nil
+			case e.Key == "$nor" && matched:
+				return false, nil
+			}
+		}
+		return e.Key != "$or", nil
+	case "$expr":
+		v, err := evalExpr(newExprScope(doc, nil), e.Value)
+		if err != nil {
+			return false, err
+		}
+		return exprTruthy(v), nil
+	case "$comment":
+		return true, nil
+	}
+	if strings.HasPrefix(e.Key, "$") {
+		return false, fmt.Errorf("%w: %s", ErrUnsupportedQuery, e.Key)
+	}
+	vals, found := resolvePath(doc, strings.Split(e.Key, "."))
+	return matchCondition(vals, found, e.Value)
+}
+
+// resolvePath get values of dotted path, arrays in the middle of path traversed for each document element
+//
+// numeric path parts index into arrays. found is false if path not exists
+func resolvePath(v any, parts []string) ([]any, bool) {
+	if len(parts) == 0 {
+		return []any{v}, true
+	}
+	switch val := v.(type) {
+	case primitive.D:
+		for _, e := range val {
+			if e.Key == parts[0] {
+				return resolvePath(e.Value, parts[1:])
+			}
+		}
+	case primitive.A:
+		if idx, err := strconv.Atoi(parts[0]); err == nil && idx >= 0 {
+			if idx < len(val) {
+				return resolvePath(val[idx], parts[1:])
+			}
+			return nil, false
+		}
+		var res []any
+		found := false
+		for _, item := range val {
+			if _, ok := item.(primitive.D); !ok {
+				continue
+			}
+			if vals, ok := resolvePath(item, parts); ok {
+				res = append(res, vals...)
+				found = true
+			}
+		}
+		return res, found
+	}
+	return nil, false
+}
+
+// candidates get values with elements of array values
+func candidates(vals []any) []any {
+	res := make([]any, 0, len(vals))
+	for _, v := range vals {
+		res = append(res, v)
+		if arr, ok := v.(primitive.A); ok {
+			res = append(res, arr...)
+		}
+	}
+	return res
+}
+
+// isOperatorDoc check if value is document of query operators
+func isOperatorDoc(v any) (primitive.D, bool) {
+	doc, ok := v.(primitive.D)
+	if !ok || len(doc) == 0 || !strings.HasPrefix(doc[0].Key, "$") {
+		return nil, false
+	}
+	return doc, true
+}
+
+// matchCondition evaluate field condition (operator document or equality value) against path values
+func matchCondition(vals []any, found bool, cond any) (bool, error) {
+	ops, ok := isOperatorDoc(cond)
+	if !ok {
+		return matchEqual(vals, found, cond)
+	}
+	for _, op := range ops {
+		matched, err := matchOperator(vals, found, op, ops)
+		if err != nil || !matched {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// matchEqual check if any value or array element equals v, regex values matched against strings
+func matchEqual(vals []any, found bool, v any) (bool, error) {
+	switch val := v.(type) {
+	case primitive.Regex:
+		re, err := queryRegex(val.Pattern, val.Options)
+		if err != nil {
+			return false, err
+		}
+		return matchRegex(vals, re), nil
+	case nil, primitive.Null, primitive.Undefined:
+		if !found {
+			return true, nil
+		}
+	}
+	for _, c := range candidates(vals) {
+		if compareValues(c, v) == 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func matchRegex(vals []any, re *regexp.Regexp) bool {
+	for _, c := range candidates(vals) {
+		switch s := c.(type) {
+		case string:
+			if re.MatchString(s) {
+				return true
+			}
+		case primitive.Symbol:
+			if re.MatchString(string(s)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// queryRegex compile mongodb regex with i, m and s options
+func queryRegex(pattern, options string) (*regexp.Regexp, error) {
+	flags := ""
+	for _, o := range options {
+		switch o {
+		case 'i', 'm', 's':
+			flags += string(o)
+		case 'u':
+		default:
+			return nil, fmt.Errorf("%w: regex option %q", ErrUnsupportedQuery, o)
+		}
+	}
+	if flags != "" {
+		pattern = "(?" + flags + ")" + pattern
+	}
+	re, err := compileRegex(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidQuery, err)
+	}
+	return re, nil
+}
+
+func matchOperator(vals []any, found bool, op primitive.E, ops primitive.D) (bool, error) {
+	switch op.Key {
+	case "$eq":
+		return matchEqual(vals, found, op.Value)
+	case "$ne":
+		matched, err := matchEqual(vals, found, op.Value)
+		return !matched && err == nil, err
+	case "$gt", "$gte", "$lt", "$lte":
+		if canonicalType(op.Value) == 2 {
+			if op.Key == "$gte" || op.Key == "$lte" {
+				return matchEqual(vals, found, nil)
+			}
+			return false, nil
+		}
+		for _, c := range candidates(vals) {
+			if canonicalType(c) != canonicalType(op.Value) {
+				continue
+			}
+			cmp := compareValues(c, op.Value)
+			if (op.Key == "$gt" && cmp > 0) || (op.Key == "$gte" && cmp >= 0) ||
+				(op.Key == "$lt" && cmp < 0) || (op.Key == "$lte" && cmp <= 0) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case "$in", "$nin":
+		arr, ok := op.Value.(primitive.A)
+		if !ok {
+			return false, fmt.Errorf("%w: %s needs an array", ErrInvalidQuery, op.Key)
+		}
+		in := false
+		for _, item := range arr {
+			matched, err := matchEqual(vals, found, item)
+			if err != nil {
+				return false, err
+			}
+			if matched {
+				in = true
+				break
+			}
+		}
+		return in == (op.Key == "$in"), nil
+	case "$exists":
+		return isTruthy(op.Value) == found, nil
+	case "$type":
+		types, ok := op.Value.(primitive.A)
+		if !ok {
+			types = primitive.A{op.Value}
+		}
+		for _, t := range types {
+			for _, c := range candidates(vals) {
+				matched, err := matchType(c, t)
+				if err != nil || matched {
+					return matched, err
+				}
+			}
+		}
+		return false, nil
+	case "$regex":
+		pattern, options := "", ""
+		switch val := op.Value.(type) {
+		case string:
+			pattern = val
+		case primitive.Regex:
+			pattern, options = val.Pattern, val.Options
+		default:
+			return false, fmt.Errorf("%w: $regex needs a string", ErrInvalidQuery)
+		}
+		for _, e := range ops {
+			if e.Key == "$options" {
+				options, _ = e.Value.(string)
+			}
+		}
+		re, err := queryRegex(pattern, options)
+		if err != nil {
+			return false, err
+		}
+		return matchRegex(vals, re), nil
+	case "$options", "$comment":
+		return true, nil
+	case "$not":
+		var matched bool
+		var err error
+		switch val := op.Value.(type) {
+		case primitive.Regex:
+			matched, err = matchEqual(vals, found, val)
+		case primitive.D:
+			if _, ok := isOperatorDoc(val); !ok {
+				return false, fmt.Errorf("%w: $not needs an operator document", ErrInvalidQuery)
+			}
+			matched, err = matchCondition(vals, found, val)
+		default:
+			return false, fmt.Errorf("%w: $not needs a regex or document", ErrInvalidQuery)
+		}
+		return !matched && err == nil, err
+	case "$size":
+		size, ok := toInteger(op.Value)
+		if !ok {
+			return false, fmt.Errorf("%w: $size needs a number", ErrInvalidQuery)
+		}
+		for _, v := range vals {
+			if arr, ok := v.(primitive.A); ok && int64(len(arr)) == size {
+				return true, nil
+			}
+		}
+		return false, nil
+	case "$all":
+		arr, ok := op.Value.(primitive.A)
+		if !ok {
+			return false, fmt.Errorf("%w: $all needs an array", ErrInvalidQuery)
+		}
+		if len(arr) == 0 {
+			return false, nil
+		}
+		for _, item := range arr {
+			var matched bool
+			var err error
+			if sub, ok := isOperatorDoc(item); ok && sub[0].Key == "$elemMatch" {
+				matched, err = matchOperator(vals, found, sub[0], sub)
+			} else {
+				matched, err = matchEqual(vals, found, item)
+			}
+			if err != nil || !matched {
+				return false, err
+			}
+		}
+		return true, nil
+	case "$elemMatch":
+		cond, ok := op.Value.(primitive.D)
+		if !ok {
+			return false, fmt.Errorf("%w: $elemMatch needs a document", ErrInvalidQuery)
+		}
+		_, isOps := isOperatorDoc(cond)
+		for _, v := range vals {
+			arr, ok := v.(primitive.A)
+			if !ok {
+				continue
+			}
+			for _, item := range arr {
+				var matched bool
+				var err error
+				if isOps {
+					matched, err = matchCondition([]any{item}, true, cond)
+				} else if sub, ok := item.(primitive.D); ok {
+					matched, err = matchDocument(sub, cond)
+				}
+				if err != nil || matched {
+					return matched, err
+				}
+			}
+		}
+		return false, nil
+	case "$mod":
+		arr, ok := op.Value.(primitive.A)
+		if !ok || len(arr) != 2 {
+			return false, fmt.Errorf("%w: $mod needs [divisor, remainder]", ErrInvalidQuery)
+		}
+		divisor, ok1 := truncInteger(arr[0])
+		remainder, ok2 := truncInteger(arr[1])
+		if !ok1 || !ok2 || divisor == 0 {
+			return false, fmt.Errorf("%w: invalid $mod arguments", ErrInvalidQuery)
+		}
+		for _, c := range candidates(vals) {
+			if n, ok := truncInteger(c); ok && n%divisor == remainder {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return false, fmt.Errorf("%w: %s", ErrUnsupportedQuery, op.Key)
+}
+
+// matchType check value bson type by number or alias, "number" match all numeric types
+func matchType(v any, t any) (bool, error) {
+	var code int32
+	if alias, ok := t.(string); ok {
+		if alias == "number" {
+			return isNumber(v), nil
+		}
+		if code, ok = typeAliases[alias]; !ok {
+			return false, fmt.Errorf("%w: unknown $type %q", ErrInvalidQuery, alias)
+		}
+	} else if n, ok := toInteger(t); ok {
+		code = int32(n)
+	} else {
+		return false, fmt.Errorf("%w: invalid $type", ErrInvalidQuery)
+	}
+	return bsonTypeOf(v) == code, nil
+}
+
+// bsonTypeOf get bson type number of value
+func bsonTypeOf(v any) int32 {
+	switch v.(type) {
+	case float64, float32:
+		return 1
+	case string:
+		return 2
+	case primitive.D:
+		return 3
+	case primitive.A:
+		return 4
+	case primitive.Binary:
+		return 5
+	case primitive.Undefined:
+		return 6
+	case primitive.ObjectID:
+		return 7
+	case bool:
+		return 8
+	case primitive.DateTime:
+		return 9
+	case nil, primitive.Null:
+		return 10
+	case primitive.Regex:
+		return 11
+	case primitive.JavaScript:
+		return 13
+	case primitive.Symbol:
+		return 14
+	case int32:
+		return 16
+	case primitive.Timestamp:
+		return 17
+	case int64, int:
+		return 18
+	case primitive.Decimal128:
+		return 19
+	case primitive.MinKey:
+		return -1
+	case primitive.MaxKey:
+		return 127
+	}
+	return 0
+}
@@ -0,0 +1,1045 @@
+package mongoutils
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// missingValue marker of missing field in aggregation expressions
+type missingValue struct{}
+
+var missing = missingValue{}
+
+// exprScope aggregation expression variables
+type exprScope map[string]any
+
+// newExprScope create scope with $$ROOT and $$CURRENT of document and extra variables
+func newExprScope(doc primitive.D, vars map[string]any) exprScope {
+	scope := exprScope{"ROOT": doc, "CURRENT": doc}
+	for k, v := range vars {
+		scope[k] = v
+	}
+	return scope
+}
+
+func (me exprScope) with(name string, v any) exprScope {
+	res := make(exprScope, len(me)+1)
+	for k, val := range me {
+		res[k] = val
+	}
+	res[name] = v
+	return res
+}
+
+// isNullish check if value is null, undefined or missing
+func isNullish(v any) bool {
+	switch v.(type) {
+	case nil, primitive.Null, primitive.Undefined, missingValue:
+		return true
+	}
+	return false
+}
+
+// exprTruthy aggregation truthiness, false, null, missing and zero are false
+func exprTruthy(v any) bool {
+	if _, ok := v.(missingValue); ok {
+		return false
+	}
+	return isTruthy(v)
+}
+
+// orNull convert missing to null
+func orNull(v any) any {
+	if _, ok := v.(missingValue); ok {
+		return nil
+	}
+	return v
+}
+
+// fieldPath get value of dotted path, arrays mapped to values of elements
+func fieldPath(v any, parts []string) any {
+	for i, p := range parts {
+		switch val := v.(type) {
+		case primitive.D:
+			got, ok := getValue(val, []string{p})
+			if !ok {
+				return missing
+			}
+			v = got
+		case primitive.A:
+			res := primitive.A{}
+			for _, item := range val {
+				if r := fieldPath(item, parts[i:]); r != missing {
+					res = append(res, r)
+				}
+			}
+			return res
+		default:
+			return missing
+		}
+	}
+	return v
+}
+
+// evalExpr evaluate aggregation expression
+func evalExpr(scope exprScope, expr any) (any, error) {
+	switch val := expr.(type) {
+	case string:
+		if strings.HasPrefix(val, "$$") {
+			name, path, hasPath := strings.Cut(val[2:], ".")
+			if name == "REMOVE" {
+				return missing, nil
+			}
+			v, ok := scope[name]
+			if !ok {
+				return nil, fmt.Errorf("%w: undefined variable %s", ErrInvalidQuery, name)
+			}
+			if hasPath {
+				return fieldPath(v, strings.Split(path, ".")), nil
+			}
+			return v, nil
+		}
+		if strings.HasPrefix(val, "$") {
+			return fieldPath(scope["CURRENT"], strings.Split(val[1:], ".")), nil
+		}
+		return val, nil
+	case primitive.D:
+		if len(val) > 0 && strings.HasPrefix(val[0].Key, "$") {
+			if len(val) != 1 {
+				return nil, fmt.Errorf("%w: expression %s must be the only field", ErrInvalidQuery, val[0].Key)
+			}
+			return evalOperator(scope, val[0].Key, val[0].Value)
+		}
+		res := primitive.D{}
+		for _, e := range val {
+			v, err := evalExpr(scope, e.Value)
+			if err != nil {
+				return nil, err
+			}
+			if v != missing {
+				res = append(res, primitive.E{Key: e.Key, Value: v})
+			}
+		}
+		return res, nil
+	case primitive.A:
+		res := make(primitive.A, len(val))
+		for i, item := range val {
+			v, err := evalExpr(scope, item)
+			if err != nil {
+				return nil, err
+			}
+			res[i] = orNull(v)
+		}
+		return res, nil
+	}
+	return expr, nil
+}
+
+// evalArgs evaluate operator arguments, single argument treated as one item list
+func evalArgs(scope exprScope, arg any) ([]any, error) {
+	items, ok := arg.(primitive.A)
+	if !ok {
+		items = primitive.A{arg}
+	}
+	res := make([]any, len(items))
+	for i, item := range items {
+		v, err := evalExpr(scope, item)
+		if err != nil {
+			return nil, err
+		}
+		res[i] = v
+	}
+	return res, nil
+}
+
+// evalNamedArgs evaluate document arguments of operator
+func evalNamedArgs(scope exprScope, op string, arg any, names ...string) (map[string]any, error) {
+	doc, ok := arg.(primitive.D)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s needs a document", ErrInvalidQuery, op)
+	}
+	res := make(map[string]any)
+	for _, e := range doc {
+		known := false
+		for _, n := range names {
+			if n == e.Key {
+				known = true
+			}
+		}
+		if !known {
+			return nil, fmt.Errorf("%w: unknown %s argument %s", ErrInvalidQuery, op, e.Key)
+		}
+		v, err := evalExpr(scope, e.Value)
+		if err != nil {
+			return nil, err
+		}
+		res[e.Key] = v
+	}
+	return res, nil
+}
+
+func argCount(op string, args []any, counts ...int) error {
+	for _, c := range counts {
+		if len(args) == c {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: invalid %s arguments count", ErrInvalidQuery, op)
+}
+
+func evalOperator(scope exprScope, op string, arg any) (any, error) {
+	switch op {
+	case "$literal":
+		return arg, nil
+	case "$cond":
+		var cond, then, otherwise any
+		if doc, ok := arg.(primitive.D); ok {
+			for _, e := range doc {
+				switch e.Key {
+				case "if":
+					cond = e.Value
+				case "then":
+					then = e.Value
+				case "else":
+					otherwise = e.Value
+				}
+			}
+		} else if items, ok := arg.(primitive.A); ok && len(items) == 3 {
+			cond, then, otherwise = items[0], items[1], items[2]
+		} else {
+			return nil, fmt.Errorf("%w: invalid $cond", ErrInvalidQuery)
+		}
+		v, err := evalExpr(scope, cond)
+		if err != nil {
+			return nil, err
+		}
+		if exprTruthy(v) {
+			return evalExpr(scope, then)
+		}
+		return evalExpr(scope, otherwise)
+	case "$switch":
+		doc, ok := arg.(primitive.D)
+		if !ok {
+			return nil, fmt.Errorf("%w: $switch needs a document", ErrInvalidQuery)
+		}
+		branches, _ := getValue(doc, []string{"branches"})
+		items, _ := branches.(primitive.A)
+		for _, b := range items {
+			branch, ok := b.(primitive.D)
+			if !ok {
+				return nil, fmt.Errorf("%w: invalid $switch branch", ErrInvalidQuery)
+			}
+			c, _ := getValue(branch, []string{"case"})
+			v, err := evalExpr(scope, c)
+			if err != nil {
+				return nil, err
+			}
+			if exprTruthy(v) {
+				then, _ := getValue(branch, []string{"then"})
+				return evalExpr(scope, then)
+			}
+		}
+		if def, ok := getValue(doc, []string{"default"}); ok {
+			return evalExpr(scope, def)
+		}
+		return nil, fmt.Errorf("%w: $switch has no matching branch and no default", ErrInvalidQuery)
+	case "$filter", "$map":
+		names := []string{"input", "as", "cond", "limit"}
+		if op == "$map" {
+			names = []string{"input", "as", "in"}
+		}
+		doc, ok := arg.(primitive.D)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s needs a document", ErrInvalidQuery, op)
+		}
+		input, as, body := any(nil), "this", any(nil)
+		var limit int64 = -1
+		for _, e := range doc {
+			switch e.Key {
+			case "input":
+				v, err := evalExpr(scope, e.Value)
+				if err != nil {
+					return nil, err
+				}
+				input = v
+			case "as":
+				as, _ = e.Value.(string)
+			case "cond", "in":
+				body = e.Value
+			case "limit":
+				v, err := evalExpr(scope, e.Value)
+				if err != nil {
+					return nil, err
+				}
+				limit, _ = toInteger(v)
+			default:
+				return nil, fmt.Errorf("%w: unknown %s argument %s (expected %s)", ErrInvalidQuery, op, e.Key, strings.Join(names, ", "))
+			}
+		}
+		if isNullish(input) {
+			return nil, nil
+		}
+		items, ok := input.(primitive.A)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s input must be an array", ErrInvalidQuery, op)
+		}
+		res := primitive.A{}
+		for _, item := range items {
+			v, err := evalExpr(scope.with(as, item), body)
+			if err != nil {
+				return nil, err
+			}
+			if op == "$map" {
+				res = append(res, orNull(v))
+			} else if exprTruthy(v) {
+				if limit >= 0 && int64(len(res)) >= limit {
+					break
+				}
+				res = append(res, item)
+			}
+		}
+		return res, nil
+	case "$reduce":
+		doc, ok := arg.(primitive.D)
+		if !ok {
+			return nil, fmt.Errorf("%w: $reduce needs a document", ErrInvalidQuery)
+		}
+		inputExpr, _ := getValue(doc, []string{"input"})
+		initial, _ := getValue(doc, []string{"initialValue"})
+		body, _ := getValue(doc, []string{"in"})
+		input, err := evalExpr(scope, inputExpr)
+		if err != nil {
+			return nil, err
+		}
+		if isNullish(input) {
+			return nil, nil
+		}
+		items, ok := input.(primitive.A)
+		if !ok {
+			return nil, fmt.Errorf("%w: $reduce input must be an array", ErrInvalidQuery)
+		}
+		value, err := evalExpr(scope, initial)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			if value, err = evalExpr(scope.with("value", value).with("this", item), body); err != nil {
+				return nil, err
+			}
+		}
+		return value, nil
+	case "$let":
+		doc, ok := arg.(primitive.D)
+		if !ok {
+			return nil, fmt.Errorf("%w: $let needs a document", ErrInvalidQuery)
+		}
+		vars, _ := getValue(doc, []string{"vars"})
+		body, _ := getValue(doc, []string{"in"})
+		varsDoc, _ := vars.(primitive.D)
+		inner := scope
+		for _, e := range varsDoc {
+			v, err := evalExpr(scope, e.Value)
+			if err != nil {
+				return nil, err
+			}
+			inner = inner.with(e.Key, v)
+		}
+		return evalExpr(inner, body)
+	case "$trim", "$ltrim", "$rtrim":
+		args, err := evalNamedArgs(scope, op, arg, "input", "chars")
+		if err != nil {
+			return nil, err
+		}
+		if isNullish(args["input"]) {
+			return nil, nil
+		}
+		s, ok := args["input"].(string)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s input must be a string", ErrInvalidQuery, op)
+		}
+		chars := " \t\n\r\v\f\x00"
+		if c, ok := args["chars"].(string); ok {
+			chars = c
+		}
+		switch op {
+		case "$ltrim":
+			return strings.TrimLeft(s, chars), nil
+		case "$rtrim":
+			return strings.TrimRight(s, chars), nil
+		}
+		return strings.Trim(s, chars), nil
+	case "$regexMatch":
+		args, err := evalNamedArgs(scope, op, arg, "input", "regex", "options")
+		if err != nil {
+			return nil, err
+		}
+		s, ok := args["input"].(string)
+		if !ok {
+			return false, nil
+		}
+		pattern, options := "", ""
+		switch r := args["regex"].(type) {
+		case string:
+			pattern = r
+		case primitive.Regex:
+			pattern, options = r.Pattern, r.Options
+		default:
+			return nil, fmt.Errorf("%w: $regexMatch needs a regex", ErrInvalidQuery)
+		}
+		if o, ok := args["options"].(string); ok {
+			options = o
+		}
+		re, err := queryRegex(pattern, options)
+		if err != nil {
+			return nil, err
+		}
+		return re.MatchString(s), nil
+	}
+
+	args, err := evalArgs(scope, arg)
+	if err != nil {
+		return nil, err
+	}
+	return evalFunction(op, args)
+}
+
+// evalFunction evaluate operator with evaluated arguments
+func evalFunction(op string, args []any) (any, error) {
+	switch op {
+	// arithmetic
+	case "$add":
+		var res any = int32(0)
+		var date *primitive.DateTime
+		for _, a := range args {
+			if isNullish(a) {
+				return nil, nil
+			}
+			if d, ok := a.(primitive.DateTime); ok {
+				if date != nil {
+					return nil, fmt.Errorf("%w: only one date allowed in $add", ErrInvalidQuery)
+				}
+				date = &d
+				continue
+			}
+			if !isNumber(a) {
+				return nil, fmt.Errorf("%w: $add only supports numeric or date types", ErrInvalidQuery)
+			}
+			res = arithmetic(res, a, "$inc")
+		}
+		if date != nil {
+			f, _ := toFloat(res)
+			return primitive.DateTime(int64(*date) + int64(math.Round(f))), nil
+		}
+		return res, nil
+	case "$multiply":
+		var res any = int32(1)
+		for _, a := range args {
+			if isNullish(a) {
+				return nil, nil
+			}
+			if !isNumber(a) {
+				return nil, fmt.Errorf("%w: $multiply only supports numeric types", ErrInvalidQuery)
+			}
+			res = arithmetic(res, a, "$mul")
+		}
+		return res, nil
+	case "$subtract", "$divide", "$mod", "$pow":
+		if err := argCount(op, args, 2); err != nil {
+			return nil, err
+		}
+		a, b := args[0], args[1]
+		if isNullish(a) || isNullish(b) {
+			return nil, nil
+		}
+		if op == "$subtract" {
+			da, aDate := a.(primitive.DateTime)
+			db, bDate := b.(primitive.DateTime)
+			switch {
+			case aDate && bDate:
+				return int64(da) - int64(db), nil
+			case aDate && isNumber(b):
+				f, _ := toFloat(b)
+				return primitive.DateTime(int64(da) - int64(math.Round(f))), nil
+			}
+		}
+		if !isNumber(a) || !isNumber(b) {
+			return nil, fmt.Errorf("%w: %s only supports numeric types", ErrInvalidQuery, op)
+		}
+		x, _ := toFloat(a)
+		y, _ := toFloat(b)
+		switch op {
+		case "$subtract":
+			return arithmetic(a, arithmetic(b, int32(-1), "$mul"), "$inc"), nil
+		case "$divide":
+			if y == 0 {
+				return nil, fmt.Errorf("%w: can't $divide by zero", ErrInvalidQuery)
+			}
+			return x / y, nil
+		case "$pow":
+			return math.Pow(x, y), nil
+		}
+		if y == 0 {
+			return nil, fmt.Errorf("%w: can't $mod by zero", ErrInvalidQuery)
+		}
+		ia, aInt := intValue(a)
+		ib, bInt := intValue(b)
+		if aInt && bInt {
+			_, aLong := a.(int64)
+			_, bLong := b.(int64)
+			if aLong || bLong {
+				return ia % ib, nil
+			}
+			return int32(ia % ib), nil
+		}
+		return math.Mod(x, y), nil
+	case "$abs", "$ceil", "$floor", "$sqrt", "$round", "$trunc":
+		if len(args) == 0 || len(args) > 2 {
+			return nil, fmt.Errorf("%w: invalid %s arguments count", ErrInvalidQuery, op)
+		}
+		if isNullish(args[0]) {
+			return nil, nil
+		}
+		if !isNumber(args[0]) {
+			return nil, fmt.Errorf("%w: %s only supports numeric types", ErrInvalidQuery, op)
+		}
+		x, _ := toFloat(args[0])
+		if i, ok := intValue(args[0]); ok && op != "$sqrt" {
+			if op == "$abs" && i < 0 {
+				return arithmetic(args[0], int32(-1), "$mul"), nil
+			}
+			return args[0], nil
+		}
+		switch op {
+		case "$abs":
+			return math.Abs(x), nil
+		case "$ceil":
+			return math.Ceil(x), nil
+		case "$floor":
+			return math.Floor(x), nil
+		case "$sqrt":
+			return math.Sqrt(x), nil
+		}
+		place := int64(0)
+		if len(args) == 2 {
+			place, _ = toInteger(args[1])
+		}
+		scale := math.Pow(10, float64(place))
+		if op == "$trunc" {
+			return math.Trunc(x*scale) / scale, nil
+		}
+		return math.RoundToEven(x*scale) / scale, nil
+
+	// comparison and boolean
+	case "$eq", "$ne", "$gt", "$gte", "$lt", "$lte", "$cmp":
+		if err := argCount(op, args, 2); err != nil {
+			return nil, err
+		}
+		c := compareValues(orNull(args[0]), orNull(args[1]))
+		switch op {
+		case "$eq":
+			return c == 0, nil
+		case "$ne":
+			return c != 0, nil
+		case "$gt":
+			return c > 0, nil
+		case "$gte":
+			return c >= 0, nil
+		case "$lt":
+			return c < 0, nil
+		case "$lte":
+			return c <= 0, nil
+		}
+		return int32(c), nil
+	case "$and":
+		for _, a := range args {
+			if !exprTruthy(a) {
+				return false, nil
+			}
+		}
+		return true, nil
+	case "$or":
+		for _, a := range args {
+			if exprTruthy(a) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case "$not":
+		if err := argCount(op, args, 1); err != nil {
+			return nil, err
+		}
+		return !exprTruthy(args[0]), nil
+	case "$ifNull":
+		if len(args) < 2 {
+			return nil, fmt.Errorf("%w: $ifNull needs at least 2 arguments", ErrInvalidQuery)
+		}
+		for _, a := range args[:len(args)-1] {
+			if !isNullish(a) {
+				return a, nil
+			}
+		}
+		return args[len(args)-1], nil
+
+	// string
+	case "$concat":
+		var sb strings.Builder
+		for _, a := range args {
+			if isNullish(a) {
+				return nil, nil
+			}
+			s, ok := a.(string)
+			if !ok {
+				return nil, fmt.Errorf("%w: $concat only supports strings, not %s", ErrInvalidQuery, typeName(a))
+			}
+			sb.WriteString(s)
+		}
+		return sb.String(), nil
+	case "$toLower", "$toUpper":
+		if err := argCount(op, args, 1); err != nil {
+			return nil, err
+		}
+		if isNullish(args[0]) {
+			return "", nil
+		}
+		s := fmt.Sprint(args[0])
+		if str, ok := args[0].(string); ok {
+			s = str
+		}
+		if op == "$toLower" {
+			return strings.ToLower(s), nil
+		}
+		return strings.ToUpper(s), nil
+	case "$substr", "$substrBytes", "$substrCP":
+		if err := argCount(op, args, 3); err != nil {
+			return nil, err
+		}
+		if isNullish(args[0]) {
+			return "", nil
+		}
+		s, ok := args[0].(string)
+		start, ok1 := toInteger(args[1])
+		length, ok2 := toInteger(args[2])
+		if !ok || !ok1 || !ok2 || start < 0 {
+			return nil, fmt.Errorf("%w: invalid %s arguments", ErrInvalidQuery, op)
+		}
+		if op == "$substrCP" {
+			runes := []rune(s)
+			if start > int64(len(runes)) {
+				return "", nil
+			}
+			end := start + length
+			if length < 0 || end > int64(len(runes)) {
+				end = int64(len(runes))
+			}
+			return string(runes[start:end]), nil
+		}
+		if start > int64(len(s)) {
+			return "", nil
+		}
+		end := start + length
+		if length < 0 || end > int64(len(s)) {
+			end = int64(len(s))
+		}
+		return s[start:end], nil
+	case "$strLenCP", "$strLenBytes":
+		if err := argCount(op, args, 1); err != nil {
+			return nil, err
+		}
+		s, ok := args[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s needs a string", ErrInvalidQuery, op)
+		}
+		if op == "$strLenBytes" {
+			return int32(len(s)), nil
+		}
+		return int32(utf8.RuneCountInString(s)), nil
+	case "$split":
+		if err := argCount(op, args, 2); err != nil {
+			return nil, err
+		}
+		if isNullish(args[0]) {
+			return nil, nil
+		}
+		s, ok1 := args[0].(string)
+		sep, ok2 := args[1].(string)
+		if !ok1 || !ok2 || sep == "" {
+			return nil, fmt.Errorf("%w: invalid $split arguments", ErrInvalidQuery)
+		}
+		res := primitive.A{}
+		for _, part := range strings.Split(s, sep) {
+			res = append(res, part)
+		}
+		return res, nil
+
+	// array
+	case "$size":
+		if err := argCount(op, args, 1); err != nil {
+			return nil, err
+		}
+		arr, ok := args[0].(primitive.A)
+		if !ok {
+			return nil, fmt.Errorf("%w: $size argument must be an array", ErrInvalidQuery)
+		}
+		return int32(len(arr)), nil
+	case "$arrayElemAt":
+		if err := argCount(op, args, 2); err != nil {
+			return nil, err
+		}
+		if isNullish(args[0]) || isNullish(args[1]) {
+			return nil, nil
+		}
+		arr, ok := args[0].(primitive.A)
+		idx, ok1 := toInteger(args[1])
+		if !ok || !ok1 {
+			return nil, fmt.Errorf("%w: invalid $arrayElemAt arguments", ErrInvalidQuery)
+		}
+		if idx < 0 {
+			idx += int64(len(arr))
+		}
+		if idx < 0 || idx >= int64(len(arr)) {
+			return missing, nil
+		}
+		return arr[idx], nil
+	case "$first", "$last":
+		if err := argCount(op, args, 1); err != nil {
+			return nil, err
+		}
+		if isNullish(args[0]) {
+			return nil, nil
+		}
+		arr, ok := args[0].(primitive.A)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s argument must be an array", ErrInvalidQuery, op)
+		}
+		if len(arr) == 0 {
+			return missing, nil
+		}
+		if op == "$first" {
+			return arr[0], nil
+		}
+		return arr[len(arr)-1], nil
+	case "$in":
+		if err := argCount(op, args, 2); err != nil {
+			return nil, err
+		}
+		arr, ok := args[1].(primitive.A)
+		if !ok {
+			return nil, fmt.Errorf("%w: $in second argument must be an array", ErrInvalidQuery)
+		}
+		return containsValue(arr, orNull(args[0])), nil
+	case "$concatArrays":
+		res := primitive.A{}
+		for _, a := range args {
+			if isNullish(a) {
+				return nil, nil
+			}
+			arr, ok := a.(primitive.A)
+			if !ok {
+				return nil, fmt.Errorf("%w: $concatArrays only supports arrays", ErrInvalidQuery)
+			}
+			res = append(res, arr...)
+		}
+		return res, nil
+	case "$slice":
+		if err := argCount(op, args, 2, 3); err != nil {
+			return nil, err
+		}
+		if isNullish(args[0]) {
+			return nil, nil
+		}
+		arr, ok := args[0].(primitive.A)
+		if !ok {
+			return nil, fmt.Errorf("%w: $slice first argument must be an array", ErrInvalidQuery)
+		}
+		n, _ := toInteger(args[len(args)-1])
+		if len(args) == 3 {
+			skip, _ := toInteger(args[1])
+			return sliceArray(arr, n, &skip), nil
+		}
+		return sliceArray(arr, n, nil), nil
+	case "$reverseArray":
+		if err := argCount(op, args, 1); err != nil {
+			return nil, err
+		}
+		if isNullish(args[0]) {
+			return nil, nil
+		}
+		arr, ok := args[0].(primitive.A)
+		if !ok {
+			return nil, fmt.Errorf("%w: $reverseArray argument must be an array", ErrInvalidQuery)
+		}
+		res := make(primitive.A, len(arr))
+		for i, item := range arr {
+			res[len(arr)-1-i] = item
+		}
+		return res, nil
+	case "$isArray":
+		if err := argCount(op, args, 1); err != nil {
+			return nil, err
+		}
+		_, ok := args[0].(primitive.A)
+		return ok, nil
+	case "$sum", "$avg", "$min", "$max":
+		values := args
+		if len(args) == 1 {
+			if arr, ok := args[0].(primitive.A); ok {
+				values = arr
+			}
+		}
+		return accumulate(op, values)
+
+	// object
+	case "$mergeObjects":
+		values := args
+		if len(args) == 1 {
+			if arr, ok := args[0].(primitive.A); ok {
+				values = arr
+			}
+		}
+		return accumulate(op, values)
+
+	// type
+	case "$type":
+		if err := argCount(op, args, 1); err != nil {
+			return nil, err
+		}
+		if args[0] == missing {
+			return "missing", nil
+		}
+		return typeName(args[0]), nil
+	case "$toString", "$toInt", "$toLong", "$toDouble", "$toBool", "$toObjectId", "$toDate":
+		if err := argCount(op, args, 1); err != nil {
+			return nil, err
+		}
+		return convertExpr(op, args[0])
+
+	// date
+	case "$year", "$month", "$dayOfMonth", "$hour", "$minute", "$second", "$millisecond", "$dayOfWeek", "$dayOfYear":
+		if err := argCount(op, args, 1); err != nil {
+			return nil, err
+		}
+		if isNullish(args[0]) {
+			return nil, nil
+		}
+		d, ok := args[0].(primitive.DateTime)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s needs a date", ErrInvalidQuery, op)
+		}
+		t := d.Time().UTC()
+		switch op {
+		case "$year":
+			return int32(t.Year()), nil
+		case "$month":
+			return int32(t.Month()), nil
+		case "$dayOfMonth":
+			return int32(t.Day()), nil
+		case "$hour":
+			return int32(t.Hour()), nil
+		case "$minute":
+			return int32(t.Minute()), nil
+		case "$second":
+			return int32(t.Second()), nil
+		case "$millisecond":
+			return int32(t.Nanosecond() / int(time.Millisecond)), nil
+		case "$dayOfWeek":
+			return int32(t.Weekday()) + 1, nil
+		}
+		return int32(t.YearDay()), nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedQuery, op)
+}
+
+// convertExpr evaluate type conversion operator
+func convertExpr(op string, v any) (any, error) {
+	if isNullish(v) {
+		return nil, nil
+	}
+	switch op {
+	case "$toString":
+		switch val := v.(type) {
+		case string:
+			return val, nil
+		case primitive.ObjectID:
+			return val.Hex(), nil
+		case primitive.DateTime:
+			return val.Time().UTC().Format("2006-01-02T15:04:05.000Z"), nil
+		case float64:
+			return strconv.FormatFloat(val, 'f', -1, 64), nil
+		case primitive.Decimal128:
+			return val.String(), nil
+		case bool, int32, int64:
+			return fmt.Sprint(val), nil
+		}
+	case "$toInt", "$toLong", "$toDouble":
+		var f float64
+		switch val := v.(type) {
+		case string:
+			var err error
+			if f, err = strconv.ParseFloat(strings.TrimSpace(val), 64); err != nil {
+				return nil, fmt.Errorf("%w: %s failed to parse %q", ErrInvalidQuery, op, val)
+			}
+		case bool:
+			if val {
+				f = 1
+			}
+		case primitive.DateTime:
+			f = float64(val)
+		default:
+			if i, ok := intValue(v); ok && op != "$toDouble" {
+				if op == "$toInt" {
+					return int32(i), nil
+				}
+				return i, nil
+			}
+			var ok bool
+			if f, ok = toFloat(v); !ok {
+				return nil, fmt.Errorf("%w: %s unsupported conversion from %s", ErrInvalidQuery, op, typeName(v))
+			}
+		}
+		switch op {
+		case "$toInt":
+			return int32(f), nil
+		case "$toLong":
+			return int64(f), nil
+		}
+		return f, nil
+	case "$toBool":
+		return exprTruthy(v), nil
+	case "$toObjectId":
+		switch val := v.(type) {
+		case primitive.ObjectID:
+			return val, nil
+		case string:
+			id, err := primitive.ObjectIDFromHex(val)
+			if err != nil {
+				return nil, fmt.Errorf("%w: $toObjectId failed to parse %q", ErrInvalidQuery, val)
+			}
+			return id, nil
+		}
+	case "$toDate":
+		switch val := v.(type) {
+		case primitive.DateTime:
+			return val, nil
+		case primitive.ObjectID:
+			return primitive.NewDateTimeFromTime(val.Timestamp()), nil
+		case string:
+			t, err := time.Parse(time.RFC3339Nano, val)
+			if err != nil {
+				return nil, fmt.Errorf("%w: $toDate failed to parse %q", ErrInvalidQuery, val)
+			}
+			return primitive.NewDateTimeFromTime(t), nil
+		default:
+			if i, ok := toInteger(v); ok {
+				return primitive.DateTime(i), nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("%w: %s unsupported conversion from %s", ErrInvalidQuery, op, typeName(v))
+}
+
+// accumulate reduce values with $sum, $avg, $min, $max, $first, $last, $push, $addToSet or $mergeObjects
+//
+// missing values ignored
+func accumulate(op string, values []any) (any, error) {
+	switch op {
+	case "$sum", "$avg":
+		var sum any = int32(0)
+		count := 0
+		for _, v := range values {
+			if isNumber(v) {
+				sum = arithmetic(sum, v, "$inc")
+				count++
+			}
+		}
+		if op == "$sum" {
+			return sum, nil
+		}
+		if count == 0 {
+			return nil, nil
+		}
+		f, _ := toFloat(sum)
+		return f / float64(count), nil
+	case "$min", "$max":
+		var res any
+		for _, v := range values {
+			if isNullish(v) {
+				continue
+			}
+			if res == nil || (op == "$min" && compareValues(v, res) < 0) || (op == "$max" && compareValues(v, res) > 0) {
+				res = v
+			}
+		}
+		return res, nil
+	case "$first", "$last":
+		if len(values) == 0 {
+			return nil, nil
+		}
+		if op == "$first" {
+			return orNull(values[0]), nil
+		}
+		return orNull(values[len(values)-1]), nil
+	case "$push", "$addToSet":
+		res := primitive.A{}
+		for _, v := range values {
+			if v == missing || (op == "$addToSet" && containsValue(res, v)) {
+				continue
+			}
+			res = append(res, v)
+		}
+		return res, nil
+	case "$mergeObjects":
+		res := primitive.D{}
+		for _, v := range values {
+			if isNullish(v) {
+				continue
+			}
+			doc, ok := v.(primitive.D)
+			if !ok {
+				return nil, fmt.Errorf("%w: $mergeObjects requires object inputs, found %s", ErrInvalidQuery, typeName(v))
+			}
+			for _, e := range doc {
+				res, _ = setValue(res, []string{e.Key}, e.Value)
+			}
+		}
+		return res, nil
+	}
+	return nil, fmt.Errorf("%w: accumulator %s", ErrUnsupportedQuery, op)
+}
+
+// substituteVars replace $$name references of variables with literal values
+func substituteVars(v any, vars map[string]any) any {
+	switch val := v.(type) {
+	case string:
+		if !strings.HasPrefix(val, "$$") {
+			return v
+		}
+		name, path, hasPath := strings.Cut(val[2:], ".")
+		value, ok := vars[name]
+		if !ok {
+			return v
+		}
+		if hasPath {
+			value = orNull(fieldPath(value, strings.Split(path, ".")))
+		}
+		return primitive.D{{Key: "$literal", Value: value}}
+	case primitive.D:
+		res := make(primitive.D, len(val))
+		for i, e := range val {
+			res[i] = primitive.E{Key: e.Key, Value: substituteVars(e.Value, vars)}
+		}
+		return res
+	case primitive.A:
+		res := make(primitive.A, len(val))
+		for i, item := range val {
+			res[i] = substituteVars(item, vars)
+		}
+		return res
+	}
+	return v
+}
@@ -0,0 +1,277 @@
+package mongoutils
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// sortDocuments stable sort documents by sort spec
+//
+// array fields sorted by their smallest element in ascending and largest element in descending order
+func sortDocuments(docs []primitive.D, spec primitive.D) error {
+	if len(spec) == 0 {
+		return nil
+	}
+	dirs := make([]int, len(spec))
+	for i, e := range spec {
+		dir, ok := toInteger(e.Value)
+		if !ok || (dir != 1 && dir != -1) {
+			if _, ok := e.Value.(primitive.D); ok {
+				return fmt.Errorf("%w: sort %s", ErrUnsupportedQuery, e.Key)
+			}
+			return fmt.Errorf("%w: invalid sort direction for %s", ErrInvalidQuery, e.Key)
+		}
+		dirs[i] = int(dir)
+	}
+	keys := make([][]any, len(docs))
+	for i, doc := range docs {
+		keys[i] = make([]any, len(spec))
+		for j, e := range spec {
+			keys[i][j] = sortKey(doc, e.Key, dirs[j])
+		}
+	}
+	idx := make([]int, len(docs))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(a, b int) bool {
+		for j := range spec {
+			if c := compareValues(keys[idx[a]][j], keys[idx[b]][j]); c != 0 {
+				return c*dirs[j] < 0
+			}
+		}
+		return false
+	})
+	sorted := make([]primitive.D, len(docs))
+	for i, k := range idx {
+		sorted[i] = docs[k]
+	}
+	copy(docs, sorted)
+	return nil
+}
+
+func sortKey(doc primitive.D, path string, dir int) any {
+	vals, _ := resolvePath(doc, strings.Split(path, "."))
+	var res any
+	first := true
+	for _, v := range vals {
+		items := []any{v}
+		if arr, ok := v.(primitive.A); ok {
+			items = arr
+		}
+		for _, item := range items {
+			if first || compareValues(item, res)*dir < 0 {
+				res, first = item, false
+			}
+		}
+	}
+	return res
+}
+
+// projectionNode dotted projection path tree
+type projectionNode struct {
+	leaf     bool
+	operator primitive.E
+	children map[string]*projectionNode
+}
+
+func (me *projectionNode) child(key string) *projectionNode {
+	if me.children == nil {
+		me.children = make(map[string]*projectionNode)
+	}
+	if me.children[key] == nil {
+		me.children[key] = new(projectionNode)
+	}
+	return me.children[key]
+}
+
+// applyProjection apply find projection to document
+//
+// supports inclusion, exclusion, dotted paths, $slice and $elemMatch
+func applyProjection(doc primitive.D, proj primitive.D) (primitive.D, error) {
+	if len(proj) == 0 {
+		return doc, nil
+	}
+	root := new(projectionNode)
+	include, exclude, excludeID, includeID := false, false, false, false
+	for _, e := range proj {
+		if strings.Contains(e.Key, "$") {
+			return nil, fmt.Errorf("%w: positional projection %s", ErrUnsupportedQuery, e.Key)
+		}
+		node := root
+		for _, p := range strings.Split(e.Key, ".") {
+			node = node.child(p)
+		}
+		if ops, ok := e.Value.(primitive.D); ok {
+			if len(ops) != 1 || (ops[0].Key != "$slice" && ops[0].Key != "$elemMatch") {
+				return nil, fmt.Errorf("%w: projection %s", ErrUnsupportedQuery, e.Key)
+			}
+			node.operator = ops[0]
+			continue
+		}
+		node.leaf = true
+		if e.Key == "_id" {
+			excludeID = !isTruthy(e.Value)
+			includeID = !excludeID
+			continue
+		}
+		if isTruthy(e.Value) {
+			include = true
+		} else {
+			exclude = true
+		}
+	}
+	if include && exclude {
+		return nil, fmt.Errorf("%w: cannot mix inclusion and exclusion", ErrInvalidProjection)
+	}
+	var res any
+	var err error
+	if include || (includeID && !exclude) {
+		if excludeID {
+			delete(root.children, "_id")
+		} else {
+			root.child("_id").leaf = true
+		}
+		res, _, err = projectInclude(doc, root)
+	} else {
+		if !excludeID {
+			delete(root.children, "_id")
+		}
+		res, err = projectExclude(doc, root)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if res == nil {
+		return primitive.D{}, nil
+	}
+	return res.(primitive.D), nil
+}
+
+// projectInclude keep only fields of node, returns false if value must be omitted
+func projectInclude(v any, node *projectionNode) (any, bool, error) {
+	switch val := v.(type) {
+	case primitive.D:
+		res := primitive.D{}
+		for _, e := range val {
+			child, ok := node.children[e.Key]
+			if !ok {
+				continue
+			}
+			var value any
+			keep := true
+			var err error
+			switch {
+			case child.operator.Key != "":
+				value, keep, err = projectOperator(e.Value, child.operator)
+			case child.leaf:
+				value = e.Value
+			default:
+				value, keep, err = projectInclude(e.Value, child)
+			}
+			if err != nil {
+				return nil, false, err
+			}
+			if keep {
+				res = append(res, primitive.E{Key: e.Key, Value: value})
+			}
+		}
+		return res, true, nil
+	case primitive.A:
+		res := primitive.A{}
+		for _, item := range val {
+			sub, keep, err := projectInclude(item, node)
+			if err != nil {
+				return nil, false, err
+			}
+			if keep {
+				res = append(res, sub)
+			}
+		}
+		return res, true, nil
+	}
+	return nil, false, nil
+}
+
+// projectExclude remove leaf fields of node and apply projection operators
+func projectExclude(v any, node *projectionNode) (any, error) {
+	switch val := v.(type) {
+	case primitive.D:
+		res := primitive.D{}
+		for _, e := range val {
+			child, ok := node.children[e.Key]
+			if !ok {
+				res = append(res, e)
+				continue
+			}
+			if child.leaf {
+				continue
+			}
+			if child.operator.Key != "" {
+				value, keep, err := projectOperator(e.Value, child.operator)
+				if err != nil {
+					return nil, err
+				}
+				if keep {
+					res = append(res, primitive.E{Key: e.Key, Value: value})
+				}
+				continue
+			}
+			sub, err := projectExclude(e.Value, child)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, primitive.E{Key: e.Key, Value: sub})
+		}
+		return res, nil
+	case primitive.A:
+		res := primitive.A{}
+		for _, item := range val {
+			sub, err := projectExclude(item, node)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, sub)
+		}
+		return res, nil
+	}
+	return v, nil
+}
+
+// projectOperator apply $slice or $elemMatch projection to array value
+func projectOperator(v any, op primitive.E) (any, bool, error) {
+	arr, ok := v.(primitive.A)
+	if !ok {
+		return v, op.Key == "$slice", nil
+	}
+	if op.Key == "$elemMatch" {
+		cond, ok := op.Value.(primitive.D)
+		if !ok {
+			return nil, false, fmt.Errorf("%w: $elemMatch needs a document", ErrInvalidQuery)
+		}
+		for _, item := range arr {
+			matched, err := matchCondition([]any{primitive.A{item}}, true, primitive.D{{Key: "$elemMatch", Value: cond}})
+			if err != nil {
+				return nil, false, err
+			}
+			if matched {
+				return primitive.A{item}, true, nil
+			}
+		}
+		return nil, false, nil
+	}
+	if n, ok := toInteger(op.Value); ok {
+		return sliceArray(arr, n, nil), true, nil
+	}
+	if args, ok := op.Value.(primitive.A); ok && len(args) == 2 {
+		skip, ok1 := toInteger(args[0])
+		n, ok2 := toInteger(args[1])
+		if ok1 && ok2 && n > 0 {
+			return sliceArray(arr, n, &skip), true, nil
+		}
+	}
+	return nil, false, fmt.Errorf("%w: invalid $slice", ErrInvalidQuery)
+}
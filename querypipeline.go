@@ -0,0 +1,612 @@
+package mongoutils
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PipelineError aggregation stage evaluation error
+type PipelineError struct {
+	// Stage zero based stage index
+	Stage int
+	// Name stage operator name
+	Name string
+	Err  error
+}
+
+// Error implement error interface
+func (me *PipelineError) Error() string {
+	return fmt.Sprintf("mongoutils: pipeline stage %d (%s): %v", me.Stage, me.Name, me.Err)
+}
+
+// Unwrap get stage error
+func (me *PipelineError) Unwrap() error {
+	return me.Err
+}
+
+// EvalPipeline run aggregation pipeline against documents in memory
+//
+// db used to resolve $lookup collections and can be nil if pipeline has no $lookup.
+// unsupported stages and operators returns *PipelineError wrapping ErrUnsupportedQuery
+func EvalPipeline(ctx context.Context, db MemoryDatabase, docs []any, pipeline any) ([]primitive.D, error) {
+	input := make([]primitive.D, len(docs))
+	for i, d := range docs {
+		doc, err := toDocument(d)
+		if err != nil {
+			return nil, err
+		}
+		input[i] = doc
+	}
+	env := &pipelineEnv{ctx: ctx, db: db}
+	return env.run(input, pipeline)
+}
+
+type pipelineEnv struct {
+	ctx  context.Context
+	db   MemoryDatabase
+	vars map[string]any
+}
+
+func (me *pipelineEnv) run(docs []primitive.D, pipeline any) ([]primitive.D, error) {
+	value, err := toValue(pipeline)
+	if err != nil {
+		return nil, err
+	}
+	stages, ok := value.(primitive.A)
+	if !ok && value != nil {
+		return nil, fmt.Errorf("%w: pipeline must be an array", ErrInvalidQuery)
+	}
+	for i, s := range stages {
+		stage, ok := s.(primitive.D)
+		if !ok || len(stage) != 1 {
+			return nil, &PipelineError{Stage: i, Err: fmt.Errorf("%w: stage must be a document with a single field", ErrInvalidQuery)}
+		}
+		if docs, err = me.stage(docs, stage[0].Key, stage[0].Value); err != nil {
+			return nil, &PipelineError{Stage: i, Name: stage[0].Key, Err: err}
+		}
+		if err := me.ctx.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return docs, nil
+}
+
+func (me *pipelineEnv) scope(doc primitive.D) exprScope {
+	return newExprScope(doc, me.vars)
+}
+
+func (me *pipelineEnv) stage(docs []primitive.D, name string, spec any) ([]primitive.D, error) {
+	switch name {
+	case "$match":
+		filter, ok := spec.(primitive.D)
+		if !ok {
+			return nil, fmt.Errorf("%w: $match needs a document", ErrInvalidQuery)
+		}
+		if len(me.vars) > 0 {
+			filter = substituteVars(filter, me.vars).(primitive.D)
+		}
+		res := make([]primitive.D, 0, len(docs))
+		for _, doc := range docs {
+			matched, err := matchDocument(doc, filter)
+			if err != nil {
+				return nil, err
+			}
+			if matched {
+				res = append(res, doc)
+			}
+		}
+		return res, nil
+	case "$sort":
+		spec, ok := spec.(primitive.D)
+		if !ok {
+			return nil, fmt.Errorf("%w: $sort needs a document", ErrInvalidQuery)
+		}
+		return docs, sortDocuments(docs, spec)
+	case "$skip", "$limit":
+		n, ok := toInteger(spec)
+		if !ok || n < 0 || (name == "$limit" && n == 0) {
+			return nil, fmt.Errorf("%w: %s needs a positive number", ErrInvalidQuery, name)
+		}
+		if n > int64(len(docs)) {
+			n = int64(len(docs))
+		}
+		if name == "$skip" {
+			return docs[n:], nil
+		}
+		return docs[:n], nil
+	case "$count":
+		field, ok := spec.(string)
+		if !ok || field == "" || strings.HasPrefix(field, "$") || strings.Contains(field, ".") {
+			return nil, fmt.Errorf("%w: $count needs a field name", ErrInvalidQuery)
+		}
+		if len(docs) == 0 {
+			return docs, nil
+		}
+		return []primitive.D{{{Key: field, Value: int32(len(docs))}}}, nil
+	case "$project":
+		return me.project(docs, spec)
+	case "$unset":
+		fields, ok := spec.(primitive.A)
+		if !ok {
+			fields = primitive.A{spec}
+		}
+		proj := primitive.D{}
+		for _, f := range fields {
+			field, ok := f.(string)
+			if !ok {
+				return nil, fmt.Errorf("%w: $unset needs field names", ErrInvalidQuery)
+			}
+			proj = append(proj, primitive.E{Key: field, Value: 0})
+		}
+		return me.project(docs, proj)
+	case "$addFields", "$set":
+		fields, ok := spec.(primitive.D)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s needs a document", ErrInvalidQuery, name)
+		}
+		res := make([]primitive.D, len(docs))
+		for i, doc := range docs {
+			next, err := me.addFields(doc, me.scope(doc), doc, fields)
+			if err != nil {
+				return nil, err
+			}
+			res[i] = next
+		}
+		return res, nil
+	case "$replaceRoot", "$replaceWith":
+		expr := spec
+		if name == "$replaceRoot" {
+			doc, _ := spec.(primitive.D)
+			var ok bool
+			if expr, ok = getValue(doc, []string{"newRoot"}); !ok {
+				return nil, fmt.Errorf("%w: $replaceRoot needs newRoot", ErrInvalidQuery)
+			}
+		}
+		res := make([]primitive.D, len(docs))
+		for i, doc := range docs {
+			v, err := evalExpr(me.scope(doc), expr)
+			if err != nil {
+				return nil, err
+			}
+			root, ok := v.(primitive.D)
+			if !ok {
+				return nil, fmt.Errorf("%w: newRoot must evaluate to a document, found %s", ErrInvalidQuery, typeName(orNull(v)))
+			}
+			res[i] = root
+		}
+		return res, nil
+	case "$unwind":
+		return me.unwind(docs, spec)
+	case "$group":
+		return me.group(docs, spec)
+	case "$sortByCount":
+		grouped, err := me.group(docs, primitive.D{
+			{Key: "_id", Value: spec},
+			{Key: "count", Value: primitive.D{{Key: "$sum", Value: int32(1)}}},
+		})
+		if err != nil {
+			return nil, err
+		}
+		return grouped, sortDocuments(grouped, primitive.D{{Key: "count", Value: int32(-1)}})
+	case "$lookup":
+		return me.lookup(docs, spec)
+	case "$facet":
+		facets, ok := spec.(primitive.D)
+		if !ok {
+			return nil, fmt.Errorf("%w: $facet needs a document", ErrInvalidQuery)
+		}
+		out := primitive.D{}
+		for _, f := range facets {
+			input := make([]primitive.D, len(docs))
+			for i, doc := range docs {
+				input[i] = copyValue(doc).(primitive.D)
+			}
+			res, err := me.run(input, f.Value)
+			if err != nil {
+				return nil, fmt.Errorf("facet %s: %w", f.Key, err)
+			}
+			items := make(primitive.A, len(res))
+			for i, doc := range res {
+				items[i] = doc
+			}
+			out = append(out, primitive.E{Key: f.Key, Value: items})
+		}
+		return []primitive.D{out}, nil
+	}
+	return nil, fmt.Errorf("%w: stage %s", ErrUnsupportedQuery, name)
+}
+
+// project evaluate $project stage with inclusion, exclusion and computed fields
+func (me *pipelineEnv) project(docs []primitive.D, spec any) ([]primitive.D, error) {
+	fields, ok := spec.(primitive.D)
+	if !ok || len(fields) == 0 {
+		return nil, fmt.Errorf("%w: $project needs a nonempty document", ErrInvalidQuery)
+	}
+	proj := primitive.D{}
+	computed := primitive.D{}
+	exclude := false
+	for _, e := range fields {
+		switch v := e.Value.(type) {
+		case bool, int32, int64, float64:
+			proj = append(proj, e)
+			if !isTruthy(v) && e.Key != "_id" {
+				exclude = true
+			}
+			continue
+		case primitive.D:
+			if isFindProjectionOperator(v) {
+				proj = append(proj, e)
+				continue
+			}
+		}
+		computed = append(computed, e)
+	}
+	if exclude && len(computed) > 0 {
+		return nil, fmt.Errorf("%w: cannot mix exclusion and computed fields", ErrInvalidProjection)
+	}
+	onlyComputed := len(computed) > 0 && !hasIncludedFields(proj)
+	res := make([]primitive.D, len(docs))
+	for i, doc := range docs {
+		var next primitive.D
+		var err error
+		if onlyComputed {
+			next = keepID(doc, isIDIncluded(proj))
+		} else if next, err = applyProjection(doc, proj); err != nil {
+			return nil, err
+		}
+		scope := me.scope(doc)
+		for _, e := range computed {
+			v, err := evalExpr(scope, e.Value)
+			if err != nil {
+				return nil, err
+			}
+			if v == missing {
+				continue
+			}
+			if next, err = setValue(next, strings.Split(e.Key, "."), v); err != nil {
+				return nil, err
+			}
+		}
+		res[i] = next
+	}
+	return res, nil
+}
+
+// isFindProjectionOperator check if value is $slice or $elemMatch projection instead of expression
+func isFindProjectionOperator(v primitive.D) bool {
+	if len(v) != 1 {
+		return false
+	}
+	switch v[0].Key {
+	case "$elemMatch":
+		return true
+	case "$slice":
+		if _, ok := toInteger(v[0].Value); ok {
+			return true
+		}
+		if args, ok := v[0].Value.(primitive.A); ok && len(args) == 2 {
+			_, ok1 := toInteger(args[0])
+			_, ok2 := toInteger(args[1])
+			return ok1 && ok2
+		}
+	}
+	return false
+}
+
+func isIDIncluded(proj primitive.D) bool {
+	for _, e := range proj {
+		if e.Key == "_id" {
+			return isTruthy(e.Value)
+		}
+	}
+	return true
+}
+
+func hasIncludedFields(proj primitive.D) bool {
+	for _, e := range proj {
+		if e.Key != "_id" && isTruthy(e.Value) {
+			return true
+		}
+	}
+	return false
+}
+
+// keepID get document with only _id field if included
+func keepID(doc primitive.D, include bool) primitive.D {
+	if id, ok := getValue(doc, []string{"_id"}); ok && include {
+		return primitive.D{{Key: "_id", Value: id}}
+	}
+	return primitive.D{}
+}
+
+// addFields evaluate $addFields fields, embedded documents merged with existing documents
+func (me *pipelineEnv) addFields(doc primitive.D, scope exprScope, target primitive.D, fields primitive.D) (primitive.D, error) {
+	for _, e := range fields {
+		if sub, ok := e.Value.(primitive.D); ok && len(sub) > 0 && !strings.HasPrefix(sub[0].Key, "$") {
+			current, _ := getValue(target, strings.Split(e.Key, "."))
+			base, ok := current.(primitive.D)
+			if !ok {
+				base = primitive.D{}
+			}
+			merged, err := me.addFields(doc, scope, base, sub)
+			if err != nil {
+				return nil, err
+			}
+			if target, err = setValue(target, strings.Split(e.Key, "."), merged); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		v, err := evalExpr(scope, e.Value)
+		if err != nil {
+			return nil, err
+		}
+		parts := strings.Split(e.Key, ".")
+		if v == missing {
+			target = unsetValue(target, parts).(primitive.D)
+			continue
+		}
+		if target, err = setValue(target, parts, v); err != nil {
+			return nil, err
+		}
+	}
+	return target, nil
+}
+
+// unwind evaluate $unwind stage
+func (me *pipelineEnv) unwind(docs []primitive.D, spec any) ([]primitive.D, error) {
+	path, indexField, preserve := "", "", false
+	switch v := spec.(type) {
+	case string:
+		path = v
+	case primitive.D:
+		for _, e := range v {
+			switch e.Key {
+			case "path":
+				path, _ = e.Value.(string)
+			case "includeArrayIndex":
+				indexField, _ = e.Value.(string)
+			case "preserveNullAndEmptyArrays":
+				preserve = isTruthy(e.Value)
+			default:
+				return nil, fmt.Errorf("%w: unknown $unwind option %s", ErrInvalidQuery, e.Key)
+			}
+		}
+	}
+	if !strings.HasPrefix(path, "$") || len(path) < 2 {
+		return nil, fmt.Errorf("%w: $unwind path must start with $", ErrInvalidQuery)
+	}
+	parts := strings.Split(path[1:], ".")
+	res := make([]primitive.D, 0, len(docs))
+	for _, doc := range docs {
+		value, found := getValue(doc, parts)
+		arr, isArray := value.(primitive.A)
+		switch {
+		case isArray && len(arr) > 0:
+			for i, item := range arr {
+				next, err := setValue(copyValue(doc).(primitive.D), parts, item)
+				if err == nil && indexField != "" {
+					next, err = setValue(next, strings.Split(indexField, "."), int64(i))
+				}
+				if err != nil {
+					return nil, err
+				}
+				res = append(res, next)
+			}
+		case found && !isArray && !isNullish(value):
+			next := doc
+			if indexField != "" {
+				var err error
+				if next, err = setValue(copyValue(doc).(primitive.D), strings.Split(indexField, "."), nil); err != nil {
+					return nil, err
+				}
+			}
+			res = append(res, next)
+		case preserve:
+			next := copyValue(doc).(primitive.D)
+			if isArray {
+				next = unsetValue(next, parts).(primitive.D)
+			}
+			if indexField != "" {
+				var err error
+				if next, err = setValue(next, strings.Split(indexField, "."), nil); err != nil {
+					return nil, err
+				}
+			}
+			res = append(res, next)
+		}
+	}
+	return res, nil
+}
+
+type groupBucket struct {
+	id     any
+	values [][]any
+}
+
+// group evaluate $group stage, groups returned in first appearance order
+func (me *pipelineEnv) group(docs []primitive.D, spec any) ([]primitive.D, error) {
+	fields, ok := spec.(primitive.D)
+	if !ok {
+		return nil, fmt.Errorf("%w: $group needs a document", ErrInvalidQuery)
+	}
+	idExpr, ok := getValue(fields, []string{"_id"})
+	if !ok {
+		return nil, fmt.Errorf("%w: $group needs an _id", ErrInvalidQuery)
+	}
+	type accumulator struct {
+		field string
+		op    string
+		expr  any
+	}
+	var accs []accumulator
+	for _, e := range fields {
+		if e.Key == "_id" {
+			continue
+		}
+		acc, ok := e.Value.(primitive.D)
+		if !ok || len(acc) != 1 {
+			return nil, fmt.Errorf("%w: $group field %s must be an accumulator", ErrInvalidQuery, e.Key)
+		}
+		switch acc[0].Key {
+		case "$sum", "$avg", "$min", "$max", "$first", "$last", "$push", "$addToSet", "$mergeObjects", "$count":
+		default:
+			return nil, fmt.Errorf("%w: accumulator %s", ErrUnsupportedQuery, acc[0].Key)
+		}
+		accs = append(accs, accumulator{field: e.Key, op: acc[0].Key, expr: acc[0].Value})
+	}
+	var buckets []*groupBucket
+	for _, doc := range docs {
+		scope := me.scope(doc)
+		id, err := evalExpr(scope, idExpr)
+		if err != nil {
+			return nil, err
+		}
+		id = orNull(id)
+		var bucket *groupBucket
+		for _, b := range buckets {
+			if compareValues(b.id, id) == 0 {
+				bucket = b
+				break
+			}
+		}
+		if bucket == nil {
+			bucket = &groupBucket{id: id, values: make([][]any, len(accs))}
+			buckets = append(buckets, bucket)
+		}
+		for i, acc := range accs {
+			var v any = int32(1)
+			if acc.op != "$count" {
+				if v, err = evalExpr(scope, acc.expr); err != nil {
+					return nil, err
+				}
+			}
+			bucket.values[i] = append(bucket.values[i], v)
+		}
+	}
+	res := make([]primitive.D, len(buckets))
+	for i, b := range buckets {
+		doc := primitive.D{{Key: "_id", Value: b.id}}
+		for j, acc := range accs {
+			op := acc.op
+			if op == "$count" {
+				op = "$sum"
+			}
+			v, err := accumulate(op, b.values[j])
+			if err != nil {
+				return nil, err
+			}
+			doc = append(doc, primitive.E{Key: acc.field, Value: v})
+		}
+		res[i] = doc
+	}
+	return res, nil
+}
+
+// lookup evaluate $lookup stage with localField/foreignField or let/pipeline
+func (me *pipelineEnv) lookup(docs []primitive.D, spec any) ([]primitive.D, error) {
+	opts, ok := spec.(primitive.D)
+	if !ok {
+		return nil, fmt.Errorf("%w: $lookup needs a document", ErrInvalidQuery)
+	}
+	var from, local, foreign, as string
+	var let primitive.D
+	var pipeline any
+	for _, e := range opts {
+		switch e.Key {
+		case "from":
+			from, _ = e.Value.(string)
+		case "localField":
+			local, _ = e.Value.(string)
+		case "foreignField":
+			foreign, _ = e.Value.(string)
+		case "as":
+			as, _ = e.Value.(string)
+		case "let":
+			let, _ = e.Value.(primitive.D)
+		case "pipeline":
+			pipeline = e.Value
+		default:
+			return nil, fmt.Errorf("%w: unknown $lookup option %s", ErrInvalidQuery, e.Key)
+		}
+	}
+	if from == "" || as == "" || (pipeline == nil && (local == "" || foreign == "")) {
+		return nil, fmt.Errorf("%w: $lookup needs from, as and localField/foreignField or pipeline", ErrInvalidQuery)
+	}
+	if me.db == nil {
+		return nil, fmt.Errorf("%w: $lookup needs a memory database", ErrInvalidQuery)
+	}
+	cursor, err := me.db.Collection(from).Find(me.ctx, primitive.D{})
+	if err != nil {
+		return nil, err
+	}
+	var foreignDocs []primitive.D
+	if err := cursor.All(me.ctx, &foreignDocs); err != nil {
+		return nil, err
+	}
+
+	res := make([]primitive.D, len(docs))
+	for i, doc := range docs {
+		matched := foreignDocs
+		if local != "" {
+			vals, _ := resolvePath(doc, strings.Split(local, "."))
+			keys := primitive.A{}
+			for _, v := range vals {
+				if arr, ok := v.(primitive.A); ok {
+					keys = append(keys, arr...)
+				} else {
+					keys = append(keys, v)
+				}
+			}
+			if len(keys) == 0 {
+				keys = append(keys, nil)
+			}
+			filter := primitive.D{{Key: foreign, Value: primitive.D{{Key: "$in", Value: keys}}}}
+			matched = nil
+			for _, f := range foreignDocs {
+				ok, err := matchDocument(f, filter)
+				if err != nil {
+					return nil, err
+				}
+				if ok {
+					matched = append(matched, f)
+				}
+			}
+		}
+		joined := make([]primitive.D, len(matched))
+		for j, f := range matched {
+			joined[j] = copyValue(f).(primitive.D)
+		}
+		if pipeline != nil {
+			vars := make(map[string]any, len(me.vars)+len(let))
+			for k, v := range me.vars {
+				vars[k] = v
+			}
+			scope := me.scope(doc)
+			for _, e := range let {
+				v, err := evalExpr(scope, e.Value)
+				if err != nil {
+					return nil, err
+				}
+				vars[e.Key] = orNull(v)
+			}
+			sub := &pipelineEnv{ctx: me.ctx, db: me.db, vars: vars}
+			if joined, err = sub.run(joined, pipeline); err != nil {
+				return nil, fmt.Errorf("lookup %s: %w", from, err)
+			}
+		}
+		items := make(primitive.A, len(joined))
+		for j, f := range joined {
+			items[j] = f
+		}
+		next, err := setValue(copyValue(doc).(primitive.D), strings.Split(as, "."), items)
+		if err != nil {
+			return nil, err
+		}
+		res[i] = next
+	}
+	return res, nil
+}
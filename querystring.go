@@ -0,0 +1,252 @@
+package mongoutils
+
+import (
+	"errors"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Query string field types
+const (
+	QueryString   = "string"
+	QueryInt      = "int"
+	QueryBool     = "bool"
+	QueryDate     = "date"
+	QueryObjectID = "objectId"
+)
+
+// Query string operators
+const (
+	QueryEq     = "eq"
+	QueryNe     = "ne"
+	QueryGt     = "gt"
+	QueryGte    = "gte"
+	QueryLt     = "lt"
+	QueryLte    = "lte"
+	QueryIn     = "in"
+	QueryNin    = "nin"
+	QueryLike   = "like"
+	QueryExists = "exists"
+)
+
+// QueryField filterable query string field
+type QueryField struct {
+	// Path document field path, query name used if empty
+	Path string
+	// Type value type, string if empty
+	Type string
+	// Operators allowed operators, eq only if empty
+	Operators []string
+}
+
+// QuerySchema query string filter schema
+type QuerySchema struct {
+	// Fields filterable fields keyed by query name
+	Fields map[string]QueryField
+	// Sortable sortable query names mapped to document field path
+	Sortable map[string]string
+	// DefaultLimit limit used if limit param not passed, 0 for no limit
+	DefaultLimit int64
+	// MaxLimit max allowed limit, 0 for unlimited
+	MaxLimit int64
+}
+
+// QueryResult parsed query string
+type QueryResult struct {
+	Filter primitive.D
+	Sort   MongoSort
+	Skip   int64
+	Limit  int64
+}
+
+// FindOption generate find option from parsed sort, skip and limit
+func (me QueryResult) FindOption(opts ...OptionFunc) *options.FindOptions {
+	var sort any
+	if me.Sort != nil && !me.Sort.IsEmpty() {
+		sort = me.Sort.Build()
+	}
+	return FindOption(sort, me.Skip, me.Limit, opts...)
+}
+
+var queryParamRx = regexp.MustCompile(`^([^\[\]]+)(?:\[([a-z]+)\])?$`)
+
+type queryCond struct {
+	op    string
+	value any
+}
+
+// ParseQuery parse query string values (e.g. "?status=active&age[gte]=18&tags[in]=a,b&sort=-age&skip=10&limit=20") to filter
+//
+// sort, skip and limit params reserved. returns ValidationErrors keyed by query param for unknown fields, operators and invalid values
+func ParseQuery(values url.Values, schema QuerySchema) (QueryResult, error) {
+	res := QueryResult{Filter: primitive.D{}, Sort: NewSort(), Limit: schema.DefaultLimit}
+	errs := make(ValidationErrors)
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	conds := make(map[string][]queryCond)
+	names := make([]string, 0)
+	for _, key := range keys {
+		vals := values[key]
+		if len(vals) != 1 {
+			errs.Add(key, "single", "", "must be passed once")
+			continue
+		}
+		raw := vals[0]
+		switch key {
+		case "sort":
+			if s, err := ParseSort(raw, schema.Sortable); err != nil {
+				errs.Add(key, "sortable", "", err.Error())
+			} else {
+				res.Sort = s
+			}
+			continue
+		case "skip":
+			if n, err := strconv.ParseInt(raw, 10, 64); err != nil || n < 0 {
+				errs.Add(key, "numeric", "", "must be non-negative integer")
+			} else {
+				res.Skip = n
+			}
+			continue
+		case "limit":
+			if n, err := strconv.ParseInt(raw, 10, 64); err != nil || n < 0 {
+				errs.Add(key, "numeric", "", "must be non-negative integer")
+			} else if schema.MaxLimit > 0 && (n == 0 || n > schema.MaxLimit) {
+				max := strconv.FormatInt(schema.MaxLimit, 10)
+				errs.Add(key, "max", max, "must be between 1 and "+max)
+			} else {
+				res.Limit = n
+			}
+			continue
+		}
+
+		m := queryParamRx.FindStringSubmatch(key)
+		if m == nil {
+			errs.Add(key, "unknown", "", "is not filterable")
+			continue
+		}
+		name, op := m[1], m[2]
+		if op == "" {
+			op = QueryEq
+		}
+		field, ok := schema.Fields[name]
+		if !ok {
+			errs.Add(key, "unknown", "", "is not filterable")
+			continue
+		}
+		if !queryOperatorAllowed(field, op) {
+			errs.Add(key, "operator", op, "operator "+op+" is not allowed")
+			continue
+		}
+		v, err := parseQueryValue(field, op, raw)
+		if err != nil {
+			errs.Add(key, "type", field.Type, err.Error())
+			continue
+		}
+		if _, ok := conds[name]; !ok {
+			names = append(names, name)
+		}
+		conds[name] = append(conds[name], queryCond{op: op, value: v})
+	}
+	if len(errs) > 0 {
+		return res, errs
+	}
+
+	for _, name := range names {
+		field := schema.Fields[name]
+		path := field.Path
+		if path == "" {
+			path = name
+		}
+		cs := conds[name]
+		if len(cs) == 1 && cs[0].op == QueryEq {
+			res.Filter = append(res.Filter, primitive.E{Key: path, Value: cs[0].value})
+			continue
+		}
+		ops := make(primitive.D, 0, len(cs))
+		for _, c := range cs {
+			if c.op == QueryLike {
+				ops = append(ops, primitive.E{Key: "$regex", Value: c.value})
+			} else {
+				ops = append(ops, primitive.E{Key: "$" + c.op, Value: c.value})
+			}
+		}
+		res.Filter = append(res.Filter, primitive.E{Key: path, Value: ops})
+	}
+	return res, nil
+}
+
+func queryOperatorAllowed(field QueryField, op string) bool {
+	if len(field.Operators) == 0 {
+		return op == QueryEq
+	}
+	for _, o := range field.Operators {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
+
+func parseQueryValue(field QueryField, op string, raw string) (any, error) {
+	switch op {
+	case QueryExists:
+		return parseQueryScalar(QueryBool, raw)
+	case QueryIn, QueryNin:
+		res := primitive.A{}
+		for _, part := range strings.Split(raw, ",") {
+			v, err := parseQueryScalar(field.Type, strings.TrimSpace(part))
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, v)
+		}
+		return res, nil
+	case QueryLike:
+		if field.Type != "" && field.Type != QueryString {
+			return nil, errors.New("like operator requires string field")
+		}
+		return primitive.Regex{Pattern: regexp.QuoteMeta(raw), Options: "i"}, nil
+	}
+	return parseQueryScalar(field.Type, raw)
+}
+
+func parseQueryScalar(typ string, raw string) (any, error) {
+	switch typ {
+	case "", QueryString:
+		return raw, nil
+	case QueryInt:
+		if n, err := strconv.ParseInt(raw, 10, 64); err == nil {
+			return n, nil
+		}
+		return nil, errors.New("must be integer")
+	case QueryBool:
+		if b, err := strconv.ParseBool(raw); err == nil {
+			return b, nil
+		}
+		return nil, errors.New("must be boolean")
+	case QueryDate:
+		for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+			if t, err := time.Parse(layout, raw); err == nil {
+				return t, nil
+			}
+		}
+		return nil, errors.New("must be date (RFC3339 or YYYY-MM-DD)")
+	case QueryObjectID:
+		if id := ParseObjectID(raw); id != nil {
+			return *id, nil
+		}
+		return nil, errors.New("must be valid object id")
+	}
+	return nil, errors.New("unknown type " + typ)
+}
@@ -0,0 +1,62 @@
+package mongoutils_test
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/bopher/mongoutils"
+)
+
+func TestParseQuery(t *testing.T) {
+	schema := mongoutils.QuerySchema{
+		Fields: map[string]mongoutils.QueryField{
+			"status": {},
+			"age":    {Type: mongoutils.QueryInt, Operators: []string{mongoutils.QueryGte, mongoutils.QueryLte}},
+			"name":   {Path: "profile.name", Operators: []string{mongoutils.QueryEq, mongoutils.QueryLike}},
+			"tags":   {Operators: []string{mongoutils.QueryIn}},
+			"active": {Type: mongoutils.QueryBool},
+		},
+		Sortable:     map[string]string{"age": "age"},
+		DefaultLimit: 20,
+		MaxLimit:     100,
+	}
+
+	// Filter
+	values, _ := url.ParseQuery("status=active&age[gte]=18&age[lte]=30&name[like]=j.o&tags[in]=a,b&active=true&sort=-age&skip=10")
+	res, err := mongoutils.ParseQuery(values, schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := pretty(res.Filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != `[{"Key":"active","Value":true},{"Key":"age","Value":[{"Key":"$gte","Value":18},{"Key":"$lte","Value":30}]},{"Key":"profile.name","Value":[{"Key":"$regex","Value":{"Pattern":"j\\.o","Options":"i"}}]},{"Key":"status","Value":"active"},{"Key":"tags","Value":[{"Key":"$in","Value":["a","b"]}]}]` {
+		t.Log(v)
+		t.Fatal("fail Filter")
+	}
+
+	// Options
+	opt := res.FindOption()
+	if *opt.Skip != 10 || *opt.Limit != 20 {
+		t.Fatal("fail Options")
+	}
+	if v, _ = pretty(opt.Sort); v != `[{"Key":"age","Value":-1},{"Key":"_id","Value":1}]` {
+		t.Log(v)
+		t.Fatal("fail Options sort")
+	}
+
+	// Errors
+	values, _ = url.ParseQuery("password=x&age[gt]=1&age[gte]=old&limit=500&sort=name")
+	_, err = mongoutils.ParseQuery(values, schema)
+	errs, ok := err.(mongoutils.ValidationErrors)
+	if !ok {
+		t.Fatal("fail Errors type")
+	}
+	for field, rule := range map[string]string{"password": "unknown", "age[gt]": "operator", "age[gte]": "type", "limit": "max", "sort": "sortable"} {
+		if len(errs[field]) != 1 || errs[field][0].Rule != rule {
+			t.Log(errs)
+			t.Fatalf("fail Errors %s", field)
+		}
+	}
+}
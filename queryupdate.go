@@ -0,0 +1,496 @@
+package mongoutils
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// applyUpdate apply update operators to copy of document
+//
+// $setOnInsert applied only if insert is true
+func applyUpdate(doc primitive.D, update primitive.D, insert bool) (primitive.D, error) {
+	res := copyValue(doc).(primitive.D)
+	for _, op := range update {
+		fields, ok := op.Value.(primitive.D)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s needs a document", ErrInvalidQuery, op.Key)
+		}
+		for _, f := range fields {
+			parts := strings.Split(f.Key, ".")
+			for _, p := range parts {
+				if p == "" || strings.HasPrefix(p, "$") {
+					return nil, fmt.Errorf("%w: positional update path %s", ErrUnsupportedQuery, f.Key)
+				}
+			}
+			var err error
+			if res, err = applyUpdateOperator(res, op.Key, parts, f.Value, insert); err != nil {
+				return nil, fmt.Errorf("%s %s: %w", op.Key, f.Key, err)
+			}
+		}
+	}
+	return res, nil
+}
+
+func applyUpdateOperator(doc primitive.D, op string, parts []string, v any, insert bool) (primitive.D, error) {
+	current, found := getValue(doc, parts)
+	switch op {
+	case "$set":
+		return setValue(doc, parts, v)
+	case "$setOnInsert":
+		if !insert {
+			return doc, nil
+		}
+		return setValue(doc, parts, v)
+	case "$unset":
+		return unsetValue(doc, parts).(primitive.D), nil
+	case "$inc", "$mul":
+		if !isNumber(v) {
+			return nil, fmt.Errorf("%w: %s needs a number", ErrInvalidQuery, op)
+		}
+		if !found {
+			if op == "$mul" {
+				v = arithmetic(v, int32(0), "$mul")
+			}
+			return setValue(doc, parts, v)
+		}
+		if !isNumber(current) {
+			return nil, fmt.Errorf("%w: cannot apply %s to non numeric field", ErrInvalidQuery, op)
+		}
+		return setValue(doc, parts, arithmetic(current, v, op))
+	case "$min", "$max":
+		if !found {
+			return setValue(doc, parts, v)
+		}
+		cmp := compareValues(v, current)
+		if (op == "$min" && cmp < 0) || (op == "$max" && cmp > 0) {
+			return setValue(doc, parts, v)
+		}
+		return doc, nil
+	case "$rename":
+		target, ok := v.(string)
+		if !ok || target == "" {
+			return nil, fmt.Errorf("%w: $rename needs a field name", ErrInvalidQuery)
+		}
+		if !found {
+			return doc, nil
+		}
+		doc = unsetValue(doc, parts).(primitive.D)
+		return setValue(doc, strings.Split(target, "."), current)
+	case "$currentDate":
+		var now any = primitive.NewDateTimeFromTime(time.Now())
+		if spec, ok := v.(primitive.D); ok {
+			for _, e := range spec {
+				if e.Key == "$type" && e.Value == "timestamp" {
+					now = primitive.Timestamp{T: uint32(time.Now().Unix()), I: 1}
+				}
+			}
+		}
+		return setValue(doc, parts, now)
+	}
+
+	// array operators
+	var arr primitive.A
+	if found && current != nil {
+		var ok bool
+		if arr, ok = current.(primitive.A); !ok {
+			return nil, fmt.Errorf("%w: %s needs an array field", ErrInvalidQuery, op)
+		}
+	}
+	switch op {
+	case "$push":
+		items, position, slice, sortSpec, err := pushModifiers(v)
+		if err != nil {
+			return nil, err
+		}
+		if position < 0 {
+			position += len(arr)
+		}
+		if position < 0 {
+			position = 0
+		}
+		if position > len(arr) {
+			position = len(arr)
+		}
+		res := make(primitive.A, 0, len(arr)+len(items))
+		res = append(res, arr[:position]...)
+		res = append(res, items...)
+		res = append(res, arr[position:]...)
+		if sortSpec != nil {
+			if err := sortArray(res, sortSpec); err != nil {
+				return nil, err
+			}
+		}
+		if slice != nil {
+			res = sliceArray(res, *slice, nil)
+		}
+		return setValue(doc, parts, res)
+	case "$addToSet":
+		items := primitive.A{v}
+		if each, ok := getValue(v, []string{"$each"}); ok {
+			if items, ok = each.(primitive.A); !ok {
+				return nil, fmt.Errorf("%w: $each needs an array", ErrInvalidQuery)
+			}
+		}
+		res := append(primitive.A{}, arr...)
+		for _, item := range items {
+			if !containsValue(res, item) {
+				res = append(res, item)
+			}
+		}
+		return setValue(doc, parts, res)
+	case "$pop":
+		if len(arr) == 0 {
+			return doc, nil
+		}
+		if n, _ := toInteger(v); n < 0 {
+			return setValue(doc, parts, append(primitive.A{}, arr[1:]...))
+		}
+		return setValue(doc, parts, append(primitive.A{}, arr[:len(arr)-1]...))
+	case "$pull", "$pullAll":
+		if !found {
+			return doc, nil
+		}
+		res := primitive.A{}
+		for _, item := range arr {
+			var remove bool
+			var err error
+			if op == "$pullAll" {
+				values, ok := v.(primitive.A)
+				if !ok {
+					return nil, fmt.Errorf("%w: $pullAll needs an array", ErrInvalidQuery)
+				}
+				remove = containsValue(values, item)
+			} else {
+				remove, err = matchPull(item, v)
+			}
+			if err != nil {
+				return nil, err
+			}
+			if !remove {
+				res = append(res, item)
+			}
+		}
+		return setValue(doc, parts, res)
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedQuery, op)
+}
+
+// pushModifiers parse $push value with $each, $position, $slice and $sort modifiers
+func pushModifiers(v any) (items primitive.A, position int, slice *int64, sortSpec any, err error) {
+	mods, ok := v.(primitive.D)
+	if _, each := getValue(mods, []string{"$each"}); !ok || !each {
+		return primitive.A{v}, math.MaxInt32, nil, nil, nil
+	}
+	position = math.MaxInt32
+	for _, m := range mods {
+		switch m.Key {
+		case "$each":
+			if items, ok = m.Value.(primitive.A); !ok {
+				return nil, 0, nil, nil, fmt.Errorf("%w: $each needs an array", ErrInvalidQuery)
+			}
+		case "$position":
+			n, ok := toInteger(m.Value)
+			if !ok {
+				return nil, 0, nil, nil, fmt.Errorf("%w: $position needs a number", ErrInvalidQuery)
+			}
+			position = int(n)
+		case "$slice":
+			n, ok := toInteger(m.Value)
+			if !ok {
+				return nil, 0, nil, nil, fmt.Errorf("%w: $slice needs a number", ErrInvalidQuery)
+			}
+			slice = &n
+		case "$sort":
+			sortSpec = m.Value
+		default:
+			return nil, 0, nil, nil, fmt.Errorf("%w: $push modifier %s", ErrUnsupportedQuery, m.Key)
+		}
+	}
+	return
+}
+
+// sortArray sort array elements by 1/-1 or by document sort spec
+func sortArray(arr primitive.A, spec any) error {
+	if keys, ok := spec.(primitive.D); ok {
+		docs := make([]primitive.D, len(arr))
+		for i, item := range arr {
+			docs[i], _ = item.(primitive.D)
+		}
+		if err := sortDocuments(docs, keys); err != nil {
+			return err
+		}
+		for i := range docs {
+			arr[i] = docs[i]
+		}
+		return nil
+	}
+	dir, ok := toInteger(spec)
+	if !ok || (dir != 1 && dir != -1) {
+		return fmt.Errorf("%w: invalid $sort", ErrInvalidQuery)
+	}
+	sort.SliceStable(arr, func(i, j int) bool {
+		return compareValues(arr[i], arr[j])*int(dir) < 0
+	})
+	return nil
+}
+
+// sliceArray get first n (or last -n) elements, or n elements after skip if skip is not nil
+func sliceArray(arr primitive.A, n int64, skip *int64) primitive.A {
+	size := int64(len(arr))
+	start, end := int64(0), size
+	if skip != nil {
+		start = *skip
+		if start < 0 {
+			start += size
+		}
+		if start < 0 {
+			start = 0
+		}
+		if start > size {
+			start = size
+		}
+		end = start + n
+	} else if n >= 0 {
+		end = n
+	} else {
+		start = size + n
+	}
+	if start < 0 {
+		start = 0
+	}
+	if end > size {
+		end = size
+	}
+	if end < start {
+		end = start
+	}
+	return append(primitive.A{}, arr[start:end]...)
+}
+
+// matchPull check if array element match $pull condition
+func matchPull(item any, cond any) (bool, error) {
+	if _, ok := isOperatorDoc(cond); ok {
+		return matchCondition([]any{item}, true, cond)
+	}
+	if filter, ok := cond.(primitive.D); ok {
+		if doc, ok := item.(primitive.D); ok {
+			return matchDocument(doc, filter)
+		}
+		return false, nil
+	}
+	return matchEqual([]any{item}, true, cond)
+}
+
+func containsValue(arr primitive.A, v any) bool {
+	for _, item := range arr {
+		if compareValues(item, v) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// arithmetic add or multiply numbers with bson type promotion
+func arithmetic(a, b any, op string) any {
+	_, aFloat := a.(float64)
+	_, bFloat := b.(float64)
+	_, aDec := a.(primitive.Decimal128)
+	_, bDec := b.(primitive.Decimal128)
+	if aFloat || bFloat || aDec || bDec {
+		x, _ := toFloat(a)
+		y, _ := toFloat(b)
+		if op == "$mul" {
+			return x * y
+		}
+		return x + y
+	}
+	x, _ := intValue(a)
+	y, _ := intValue(b)
+	var res int64
+	if op == "$mul" {
+		res = x * y
+	} else {
+		res = x + y
+	}
+	_, aLong := a.(int64)
+	_, bLong := b.(int64)
+	if aLong || bLong || res > math.MaxInt32 || res < math.MinInt32 {
+		return res
+	}
+	return int32(res)
+}
+
+// getValue get value of dotted path without array traversal, numeric parts index into arrays
+func getValue(v any, parts []string) (any, bool) {
+	for _, p := range parts {
+		switch val := v.(type) {
+		case primitive.D:
+			found := false
+			for _, e := range val {
+				if e.Key == p {
+					v, found = e.Value, true
+					break
+				}
+			}
+			if !found {
+				return nil, false
+			}
+		case primitive.A:
+			idx, err := strconv.Atoi(p)
+			if err != nil || idx < 0 || idx >= len(val) {
+				return nil, false
+			}
+			v = val[idx]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// setValue set value of dotted path, missing documents created and arrays padded with null
+func setValue(doc primitive.D, parts []string, v any) (primitive.D, error) {
+	res, err := setIn(doc, parts, v)
+	if err != nil {
+		return nil, err
+	}
+	return res.(primitive.D), nil
+}
+
+func setIn(container any, parts []string, v any) (any, error) {
+	switch val := container.(type) {
+	case primitive.D:
+		for i := range val {
+			if val[i].Key != parts[0] {
+				continue
+			}
+			if len(parts) == 1 {
+				val[i].Value = v
+				return val, nil
+			}
+			sub, err := setIn(val[i].Value, parts[1:], v)
+			if err != nil {
+				return nil, err
+			}
+			val[i].Value = sub
+			return val, nil
+		}
+		if len(parts) == 1 {
+			return append(val, primitive.E{Key: parts[0], Value: v}), nil
+		}
+		sub, err := setIn(primitive.D{}, parts[1:], v)
+		if err != nil {
+			return nil, err
+		}
+		return append(val, primitive.E{Key: parts[0], Value: sub}), nil
+	case primitive.A:
+		idx, err := strconv.Atoi(parts[0])
+		if err != nil || idx < 0 {
+			return nil, fmt.Errorf("%w: cannot create field %s in array", ErrInvalidQuery, parts[0])
+		}
+		for len(val) <= idx {
+			val = append(val, nil)
+		}
+		if len(parts) == 1 {
+			val[idx] = v
+			return val, nil
+		}
+		if val[idx] == nil {
+			val[idx] = primitive.D{}
+		}
+		sub, err := setIn(val[idx], parts[1:], v)
+		if err != nil {
+			return nil, err
+		}
+		val[idx] = sub
+		return val, nil
+	}
+	return nil, fmt.Errorf("%w: cannot create field %s in %s value", ErrInvalidQuery, parts[0], typeName(container))
+}
+
+// unsetValue remove dotted path, array elements set to null
+func unsetValue(container any, parts []string) any {
+	switch val := container.(type) {
+	case primitive.D:
+		for i := range val {
+			if val[i].Key != parts[0] {
+				continue
+			}
+			if len(parts) == 1 {
+				return append(val[:i:i], val[i+1:]...)
+			}
+			val[i].Value = unsetValue(val[i].Value, parts[1:])
+			return val
+		}
+	case primitive.A:
+		idx, err := strconv.Atoi(parts[0])
+		if err != nil || idx < 0 || idx >= len(val) {
+			return val
+		}
+		if len(parts) == 1 {
+			val[idx] = nil
+		} else {
+			val[idx] = unsetValue(val[idx], parts[1:])
+		}
+	}
+	return container
+}
+
+func typeName(v any) string {
+	for name, code := range typeAliases {
+		if code == bsonTypeOf(v) {
+			return name
+		}
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// upsertDocument build base document of upsert from filter equality conditions
+func upsertDocument(filter primitive.D) (primitive.D, error) {
+	doc := primitive.D{}
+	var collect func(f primitive.D) error
+	collect = func(f primitive.D) error {
+		for _, e := range f {
+			if e.Key == "$and" {
+				subs, _ := e.Value.(primitive.A)
+				for _, s := range subs {
+					if sub, ok := s.(primitive.D); ok {
+						if err := collect(sub); err != nil {
+							return err
+						}
+					}
+				}
+				continue
+			}
+			if strings.HasPrefix(e.Key, "$") {
+				continue
+			}
+			v := e.Value
+			if ops, ok := isOperatorDoc(v); ok {
+				found := false
+				for _, op := range ops {
+					if op.Key == "$eq" {
+						v, found = op.Value, true
+					}
+				}
+				if !found {
+					continue
+				}
+			} else if _, ok := v.(primitive.Regex); ok {
+				continue
+			}
+			var err error
+			if doc, err = setValue(doc, strings.Split(e.Key, "."), copyValue(v)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return doc, collect(filter)
+}
@@ -0,0 +1,414 @@
+package mongoutils
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ErrInvalidRSQL invalid rsql expression
+var ErrInvalidRSQL = errors.New("mongoutils: invalid rsql")
+
+// RSQLError rsql parse or validation error
+type RSQLError struct {
+	// Pos zero based byte offset in expression
+	Pos int
+	Msg string
+}
+
+// Error implement error interface
+func (me *RSQLError) Error() string {
+	return fmt.Sprintf("%s at position %d: %s", ErrInvalidRSQL, me.Pos, me.Msg)
+}
+
+// Unwrap get ErrInvalidRSQL
+func (me *RSQLError) Unwrap() error {
+	return ErrInvalidRSQL
+}
+
+// RSQLNode rsql expression node, *RSQLLogical or *RSQLComparison
+type RSQLNode interface {
+	// Position zero based byte offset of node in expression
+	Position() int
+}
+
+// RSQLLogical and (;) or or (,) node
+type RSQLLogical struct {
+	// Op "and" or "or"
+	Op       string
+	Children []RSQLNode
+	Pos      int
+}
+
+// Position implement RSQLNode
+func (me *RSQLLogical) Position() int {
+	return me.Pos
+}
+
+// RSQLValue comparison argument
+type RSQLValue struct {
+	Value string
+	Pos   int
+}
+
+// RSQLComparison selector operator arguments node (e.g. age=gt=18)
+type RSQLComparison struct {
+	Selector string
+	// Operator comparison operator as written (e.g. "==", "=in=", ">=")
+	Operator string
+	// List true if arguments passed in parentheses
+	List  bool
+	Args  []RSQLValue
+	Pos   int
+	OpPos int
+}
+
+// Position implement RSQLNode
+func (me *RSQLComparison) Position() int {
+	return me.Pos
+}
+
+var rsqlOperators = map[string]string{
+	"==":       QueryEq,
+	"!=":       QueryNe,
+	"=gt=":     QueryGt,
+	">":        QueryGt,
+	"=ge=":     QueryGte,
+	">=":       QueryGte,
+	"=lt=":     QueryLt,
+	"<":        QueryLt,
+	"=le=":     QueryLte,
+	"<=":       QueryLte,
+	"=in=":     QueryIn,
+	"=out=":    QueryNin,
+	"=exists=": QueryExists,
+}
+
+// ParseRSQL parse rsql expression (e.g. "name==John*;age=gt=18,status=in=(a,b)") to ast
+//
+// ; and "and" keyword combine with and, , and "or" keyword combine with or, and bind tighter than or.
+// returns *RSQLError wrapping ErrInvalidRSQL
+func ParseRSQL(query string) (RSQLNode, error) {
+	p := &rsqlParser{src: query}
+	p.skipSpace()
+	if p.eof() {
+		return nil, p.fail(p.pos, "empty expression")
+	}
+	node, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if !p.eof() {
+		return nil, p.fail(p.pos, fmt.Sprintf("unexpected %q", p.src[p.pos]))
+	}
+	return node, nil
+}
+
+// CompileRSQL validate rsql ast against fields and generate filter
+//
+// fields keyed by selector, == with * wildcard in string value require QueryLike operator.
+// returns *RSQLError wrapping ErrInvalidRSQL
+func CompileRSQL(node RSQLNode, fields map[string]QueryField) (primitive.D, error) {
+	switch n := node.(type) {
+	case *RSQLLogical:
+		items := make(primitive.A, 0, len(n.Children))
+		for _, child := range n.Children {
+			doc, err := CompileRSQL(child, fields)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, doc)
+		}
+		return primitive.D{{Key: "$" + n.Op, Value: items}}, nil
+	case *RSQLComparison:
+		return compileRSQLComparison(n, fields)
+	}
+	return nil, &RSQLError{Msg: fmt.Sprintf("unknown node %T", node)}
+}
+
+// RSQLFilter parse rsql expression and compile to filter
+func RSQLFilter(query string, fields map[string]QueryField) (primitive.D, error) {
+	node, err := ParseRSQL(query)
+	if err != nil {
+		return nil, err
+	}
+	return CompileRSQL(node, fields)
+}
+
+func compileRSQLComparison(n *RSQLComparison, fields map[string]QueryField) (primitive.D, error) {
+	field, ok := fields[n.Selector]
+	if !ok {
+		return nil, &RSQLError{Pos: n.Pos, Msg: fmt.Sprintf("field %q is not filterable", n.Selector)}
+	}
+	path := field.Path
+	if path == "" {
+		path = n.Selector
+	}
+	op, ok := rsqlOperators[n.Operator]
+	if !ok {
+		return nil, &RSQLError{Pos: n.OpPos, Msg: fmt.Sprintf("unknown operator %q", n.Operator)}
+	}
+	list := op == QueryIn || op == QueryNin
+	if !list && (n.List || len(n.Args) != 1) {
+		return nil, &RSQLError{Pos: n.OpPos, Msg: fmt.Sprintf("operator %q accept single argument", n.Operator)}
+	}
+	isString := field.Type == "" || field.Type == QueryString
+	if op == QueryEq && isString && strings.Contains(n.Args[0].Value, "*") {
+		if !queryOperatorAllowed(field, QueryLike) {
+			return nil, &RSQLError{Pos: n.Args[0].Pos, Msg: fmt.Sprintf("wildcard is not allowed for field %q", n.Selector)}
+		}
+		parts := strings.Split(n.Args[0].Value, "*")
+		for i := range parts {
+			parts[i] = regexp.QuoteMeta(parts[i])
+		}
+		pattern := "^" + strings.Join(parts, ".*") + "$"
+		return primitive.D{{Key: path, Value: primitive.Regex{Pattern: pattern}}}, nil
+	}
+	if !queryOperatorAllowed(field, op) {
+		return nil, &RSQLError{Pos: n.OpPos, Msg: fmt.Sprintf("operator %q is not allowed for field %q", n.Operator, n.Selector)}
+	}
+
+	typ := field.Type
+	if op == QueryExists {
+		typ = QueryBool
+	}
+	values := make(primitive.A, 0, len(n.Args))
+	for _, arg := range n.Args {
+		v, err := parseQueryScalar(typ, arg.Value)
+		if err != nil {
+			return nil, &RSQLError{Pos: arg.Pos, Msg: fmt.Sprintf("%s %s", n.Selector, err)}
+		}
+		values = append(values, v)
+	}
+	if list {
+		return primitive.D{{Key: path, Value: primitive.D{{Key: "$" + op, Value: values}}}}, nil
+	}
+	if op == QueryEq {
+		return primitive.D{{Key: path, Value: values[0]}}, nil
+	}
+	return primitive.D{{Key: path, Value: primitive.D{{Key: "$" + op, Value: values[0]}}}}, nil
+}
+
+type rsqlParser struct {
+	src string
+	pos int
+}
+
+func (me *rsqlParser) fail(pos int, msg string) error {
+	return &RSQLError{Pos: pos, Msg: msg}
+}
+
+func (me *rsqlParser) eof() bool {
+	return me.pos >= len(me.src)
+}
+
+func (me *rsqlParser) skipSpace() {
+	for !me.eof() && isRSQLSpace(me.src[me.pos]) {
+		me.pos++
+	}
+}
+
+// keyword consume and/or keyword surrounded by spaces
+func (me *rsqlParser) keyword(word string) bool {
+	if me.pos == 0 || !isRSQLSpace(me.src[me.pos-1]) {
+		return false
+	}
+	end := me.pos + len(word)
+	if end >= len(me.src) || !strings.EqualFold(me.src[me.pos:end], word) || !isRSQLSpace(me.src[end]) {
+		return false
+	}
+	me.pos = end
+	return true
+}
+
+func (me *rsqlParser) or() (RSQLNode, error) {
+	start := me.pos
+	node, err := me.and()
+	if err != nil {
+		return nil, err
+	}
+	children := []RSQLNode{node}
+	for {
+		me.skipSpace()
+		if !me.eof() && me.src[me.pos] == ',' {
+			me.pos++
+		} else if !me.keyword("or") {
+			break
+		}
+		me.skipSpace()
+		node, err := me.and()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, node)
+	}
+	if len(children) == 1 {
+		return children[0], nil
+	}
+	return &RSQLLogical{Op: "or", Children: children, Pos: start}, nil
+}
+
+func (me *rsqlParser) and() (RSQLNode, error) {
+	start := me.pos
+	node, err := me.constraint()
+	if err != nil {
+		return nil, err
+	}
+	children := []RSQLNode{node}
+	for {
+		me.skipSpace()
+		if !me.eof() && me.src[me.pos] == ';' {
+			me.pos++
+		} else if !me.keyword("and") {
+			break
+		}
+		me.skipSpace()
+		node, err := me.constraint()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, node)
+	}
+	if len(children) == 1 {
+		return children[0], nil
+	}
+	return &RSQLLogical{Op: "and", Children: children, Pos: start}, nil
+}
+
+func (me *rsqlParser) constraint() (RSQLNode, error) {
+	me.skipSpace()
+	if me.eof() {
+		return nil, me.fail(me.pos, "unexpected end of expression")
+	}
+	if me.src[me.pos] == '(' {
+		open := me.pos
+		me.pos++
+		me.skipSpace()
+		node, err := me.or()
+		if err != nil {
+			return nil, err
+		}
+		me.skipSpace()
+		if me.eof() || me.src[me.pos] != ')' {
+			return nil, me.fail(open, "unclosed parenthesis")
+		}
+		me.pos++
+		return node, nil
+	}
+	return me.comparison()
+}
+
+func (me *rsqlParser) comparison() (RSQLNode, error) {
+	res := &RSQLComparison{Pos: me.pos}
+	for !me.eof() && !isRSQLReserved(me.src[me.pos]) {
+		me.pos++
+	}
+	res.Selector = me.src[res.Pos:me.pos]
+	if res.Selector == "" {
+		return nil, me.fail(me.pos, "expected selector")
+	}
+	me.skipSpace()
+
+	res.OpPos = me.pos
+	switch {
+	case strings.HasPrefix(me.src[me.pos:], "=="), strings.HasPrefix(me.src[me.pos:], "!="),
+		strings.HasPrefix(me.src[me.pos:], "<="), strings.HasPrefix(me.src[me.pos:], ">="):
+		me.pos += 2
+	case strings.HasPrefix(me.src[me.pos:], "<"), strings.HasPrefix(me.src[me.pos:], ">"):
+		me.pos++
+	case strings.HasPrefix(me.src[me.pos:], "="):
+		me.pos++
+		for !me.eof() && (me.src[me.pos] >= 'a' && me.src[me.pos] <= 'z' || me.src[me.pos] >= 'A' && me.src[me.pos] <= 'Z') {
+			me.pos++
+		}
+		if me.eof() || me.src[me.pos] != '=' || me.pos == res.OpPos+1 {
+			return nil, me.fail(res.OpPos, "invalid operator")
+		}
+		me.pos++
+	default:
+		return nil, me.fail(me.pos, "expected operator")
+	}
+	res.Operator = me.src[res.OpPos:me.pos]
+	me.skipSpace()
+
+	if !me.eof() && me.src[me.pos] == '(' {
+		open := me.pos
+		res.List = true
+		me.pos++
+		for {
+			me.skipSpace()
+			v, err := me.value()
+			if err != nil {
+				return nil, err
+			}
+			res.Args = append(res.Args, v)
+			me.skipSpace()
+			if me.eof() {
+				return nil, me.fail(open, "unclosed parenthesis")
+			}
+			if me.src[me.pos] == ')' {
+				me.pos++
+				break
+			}
+			if me.src[me.pos] != ',' {
+				return nil, me.fail(me.pos, fmt.Sprintf("unexpected %q", me.src[me.pos]))
+			}
+			me.pos++
+		}
+		return res, nil
+	}
+	v, err := me.value()
+	if err != nil {
+		return nil, err
+	}
+	res.Args = []RSQLValue{v}
+	return res, nil
+}
+
+func (me *rsqlParser) value() (RSQLValue, error) {
+	start := me.pos
+	if me.eof() {
+		return RSQLValue{}, me.fail(start, "expected value")
+	}
+	if q := me.src[me.pos]; q == '"' || q == '\'' {
+		var sb strings.Builder
+		me.pos++
+		for !me.eof() {
+			c := me.src[me.pos]
+			if c == '\\' && me.pos+1 < len(me.src) {
+				sb.WriteByte(me.src[me.pos+1])
+				me.pos += 2
+				continue
+			}
+			me.pos++
+			if c == q {
+				return RSQLValue{Value: sb.String(), Pos: start}, nil
+			}
+			sb.WriteByte(c)
+		}
+		return RSQLValue{}, me.fail(start, "unclosed quote")
+	}
+	for !me.eof() && !isRSQLReserved(me.src[me.pos]) {
+		me.pos++
+	}
+	if me.pos == start {
+		return RSQLValue{}, me.fail(start, "expected value")
+	}
+	return RSQLValue{Value: me.src[start:me.pos], Pos: start}, nil
+}
+
+func isRSQLSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func isRSQLReserved(c byte) bool {
+	switch c {
+	case '"', '\'', '(', ')', ';', ',', '=', '!', '~', '<', '>':
+		return true
+	}
+	return isRSQLSpace(c)
+}
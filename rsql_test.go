@@ -0,0 +1,59 @@
+package mongoutils_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bopher/mongoutils"
+)
+
+func TestRSQL(t *testing.T) {
+	fields := map[string]mongoutils.QueryField{
+		"name":   {Path: "profile.name", Operators: []string{mongoutils.QueryEq, mongoutils.QueryLike}},
+		"age":    {Type: mongoutils.QueryInt, Operators: []string{mongoutils.QueryGt, mongoutils.QueryLte}},
+		"status": {Operators: []string{mongoutils.QueryIn, mongoutils.QueryNe}},
+	}
+
+	// Filter
+	filter, err := mongoutils.RSQLFilter(`name==Jo.hn*;age=gt=18,status=in=(a,"b c") or (age<=5 and status!=x)`, fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := pretty(filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != `[{"Key":"$or","Value":[[{"Key":"$and","Value":[[{"Key":"profile.name","Value":{"Pattern":"^Jo\\.hn.*$","Options":""}}],[{"Key":"age","Value":[{"Key":"$gt","Value":18}]}]]}],[{"Key":"status","Value":[{"Key":"$in","Value":["a","b c"]}]}],[{"Key":"$and","Value":[[{"Key":"age","Value":[{"Key":"$lte","Value":5}]}],[{"Key":"status","Value":[{"Key":"$ne","Value":"x"}]}]]}]]}]` {
+		t.Log(v)
+		t.Fatal("fail Filter")
+	}
+
+	// Ast
+	node, err := mongoutils.ParseRSQL("age=gt=18")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c, ok := node.(*mongoutils.RSQLComparison); !ok || c.Selector != "age" || c.Operator != "=gt=" || c.Args[0].Value != "18" || c.Args[0].Pos != 7 {
+		t.Fatal("fail Ast")
+	}
+
+	// Errors
+	for query, pos := range map[string]int{
+		"name==John;":         11,
+		"name==John;(age>1":   11,
+		"status=in=(a,b":      10,
+		"password==x":         0,
+		"age==18":             3,
+		"age=gt=old":          7,
+		"name==\"John":        6,
+		"status=in=(a,b);a=b": 17,
+		"name=foo=x":          4,
+	} {
+		_, err := mongoutils.RSQLFilter(query, fields)
+		var rErr *mongoutils.RSQLError
+		if !errors.As(err, &rErr) || !errors.Is(err, mongoutils.ErrInvalidRSQL) || rErr.Pos != pos {
+			t.Log(err)
+			t.Fatalf("fail Errors %s", query)
+		}
+	}
+}
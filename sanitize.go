@@ -0,0 +1,159 @@
+package mongoutils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ErrUnsafeKey $ prefixed or dotted key in untrusted value
+var ErrUnsafeKey = errors.New("mongoutils: unsafe key")
+
+// EscapeRegex escape regex meta characters of literal text
+func EscapeRegex(s string) string {
+	return regexp.QuoteMeta(s)
+}
+
+// RegexPrefix generate map with escaped starts with regex
+//
+// { "name": { pattern: "^John", options: "i" } }
+func RegexPrefix(k string, value string, opt string) primitive.M {
+	return RegexFor(k, "^"+EscapeRegex(value), opt)
+}
+
+// RegexContains generate map with escaped contains regex
+//
+// { "name": { pattern: "John", options: "i" } }
+func RegexContains(k string, value string, opt string) primitive.M {
+	return RegexFor(k, EscapeRegex(value), opt)
+}
+
+// RegexSuffix generate map with escaped ends with regex
+//
+// { "name": { pattern: "John$", options: "i" } }
+func RegexSuffix(k string, value string, opt string) primitive.M {
+	return RegexFor(k, EscapeRegex(value)+"$", opt)
+}
+
+// Sanitize check untrusted value (e.g. decoded json) recursively and returns ErrUnsafeKey for $ prefixed or dotted keys
+//
+// pointers dereferenced, structs, bson.Raw and json.RawMessage decoded before check.
+// maps returned as primitive.M and slices as primitive.A, other values returned as is
+func Sanitize(v any) (any, error) {
+	return sanitizeValue(v, "", false)
+}
+
+// SanitizeStrip remove $ prefixed and dotted keys from untrusted value recursively
+//
+// maps returned as primitive.M and slices as primitive.A, other values returned as is
+func SanitizeStrip(v any) any {
+	res, _ := sanitizeValue(v, "", true)
+	return res
+}
+
+func isUnsafeKey(k string) bool {
+	return strings.HasPrefix(k, "$") || strings.Contains(k, ".")
+}
+
+func sanitizeValue(v any, path string, strip bool) (any, error) {
+	join := func(k string) string {
+		if path == "" {
+			return k
+		}
+		return path + "." + k
+	}
+	switch val := v.(type) {
+	case nil:
+		return nil, nil
+	case primitive.D:
+		res := make(primitive.D, 0, len(val))
+		for _, e := range val {
+			if isUnsafeKey(e.Key) {
+				if strip {
+					continue
+				}
+				return nil, fmt.Errorf("%w %q", ErrUnsafeKey, join(e.Key))
+			}
+			item, err := sanitizeValue(e.Value, join(e.Key), strip)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, primitive.E{Key: e.Key, Value: item})
+		}
+		return res, nil
+	case []byte, primitive.Binary:
+		return v, nil
+	case bson.Raw:
+		var doc primitive.D
+		if err := bson.Unmarshal(val, &doc); err != nil {
+			return nil, err
+		}
+		return sanitizeValue(doc, path, strip)
+	case json.RawMessage:
+		var doc any
+		if err := json.Unmarshal(val, &doc); err != nil {
+			return nil, err
+		}
+		return sanitizeValue(doc, path, strip)
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if rv.IsNil() {
+			return nil, nil
+		}
+		return sanitizeValue(rv.Elem().Interface(), path, strip)
+	case reflect.Struct:
+		if isLeafStruct(rv.Type()) {
+			return v, nil
+		}
+		doc, err := toDocument(v)
+		if err != nil {
+			return nil, err
+		}
+		return sanitizeValue(doc, path, strip)
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return v, nil
+		}
+		res := make(primitive.M, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			k := iter.Key().String()
+			if isUnsafeKey(k) {
+				if strip {
+					continue
+				}
+				return nil, fmt.Errorf("%w %q", ErrUnsafeKey, join(k))
+			}
+			item, err := sanitizeValue(iter.Value().Interface(), join(k), strip)
+			if err != nil {
+				return nil, err
+			}
+			res[k] = item
+		}
+		return res, nil
+	case reflect.Slice, reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			// named byte slices and object ids
+			return v, nil
+		}
+		res := make(primitive.A, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			item, err := sanitizeValue(rv.Index(i).Interface(), path, strip)
+			if err != nil {
+				return nil, err
+			}
+			res[i] = item
+		}
+		return res, nil
+	}
+	return v, nil
+}
@@ -0,0 +1,115 @@
+package mongoutils_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/bopher/mongoutils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestSanitize(t *testing.T) {
+	// Regex
+	v, err := pretty(mongoutils.RegexPrefix("name", "a.b*", "i"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != `{"name":{"Pattern":"^a\\.b\\*","Options":"i"}}` {
+		t.Log(v)
+		t.Fatal("fail RegexPrefix")
+	}
+	if v, _ = pretty(mongoutils.RegexSuffix("name", "(x)", "")); v != `{"name":{"Pattern":"\\(x\\)$","Options":""}}` {
+		t.Log(v)
+		t.Fatal("fail RegexSuffix")
+	}
+	if res, err := mongoutils.Matches(mongoutils.Map("name", "John.Doe"), mongoutils.RegexContains("name", "n.D", "")); err != nil || !res {
+		t.Fatal("fail RegexContains")
+	}
+	if res, _ := mongoutils.Matches(mongoutils.Map("name", "JohnxDoe"), mongoutils.RegexContains("name", "n.D", "")); res {
+		t.Fatal("fail RegexContains escape")
+	}
+
+	// Sanitize
+	var input any
+	json.Unmarshal([]byte(`{"name":"John","tags":[{"ok":1},{"$where":"sleep(1000)"}],"profile":{"a.b":1,"age":{"$ne":null}}}`), &input)
+	if _, err := mongoutils.Sanitize(input); !errors.Is(err, mongoutils.ErrUnsafeKey) {
+		t.Fatal("fail Sanitize")
+	}
+	safe, err := mongoutils.Sanitize(mongoutils.Map("name", "John", "tags", []string{"a"}, "nested", mongoutils.Doc("age", 3)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ = pretty(safe); v != `{"name":"John","nested":[{"Key":"age","Value":3}],"tags":["a"]}` {
+		t.Log(v)
+		t.Fatal("fail Sanitize safe")
+	}
+	if v, _ = pretty(mongoutils.SanitizeStrip(input)); v != `{"name":"John","profile":{"age":{}},"tags":[{"ok":1},{}]}` {
+		t.Log(v)
+		t.Fatal("fail SanitizeStrip")
+	}
+	raw, _ := bson.Marshal(mongoutils.Map("$where", "sleep(1000)"))
+	type filter struct {
+		Age map[string]any `bson:"age"`
+	}
+	type token []byte
+	for name, unsafe := range map[string]any{
+		"bson.Raw":        bson.Raw(raw),
+		"json.RawMessage": json.RawMessage(`{"a":[{"$where":"1"}]}`),
+		"pointer":         &map[string]any{"$ne": nil},
+		"struct":          filter{Age: map[string]any{"$ne": nil}},
+		"struct pointer":  mongoutils.Map("f", &filter{Age: map[string]any{"$gt": 1}}),
+	} {
+		if _, err := mongoutils.Sanitize(unsafe); !errors.Is(err, mongoutils.ErrUnsafeKey) {
+			t.Fatal("fail Sanitize " + name)
+		}
+	}
+	if v, err := mongoutils.Sanitize(token("abc")); err != nil || string(v.(token)) != "abc" {
+		t.Fatal("fail Sanitize named bytes")
+	}
+
+	// Strict
+	doc := mongoutils.NewStrictDoc()
+	doc.Add("name", "John").
+		Add("age", map[string]any{"$ne": nil}).
+		Nested("tags", "$in", []string{"a", "b"})
+	if !errors.Is(doc.Err(), mongoutils.ErrUnsafeKey) {
+		t.Fatal("fail Strict")
+	}
+	if v, _ = pretty(doc.Build()); v != `[{"Key":"name","Value":"John"}]` {
+		t.Log(v)
+		t.Fatal("fail Strict build")
+	}
+	doc = mongoutils.NewStrictDoc()
+	doc.Doc("profile", func(d mongoutils.MongoDoc) mongoutils.MongoDoc {
+		return d.Add("age", mongoutils.Map("$gt", 1))
+	})
+	if !errors.Is(doc.Err(), mongoutils.ErrUnsafeKey) {
+		t.Fatal("fail Strict nested")
+	}
+	empty := func(d mongoutils.MongoDoc) mongoutils.MongoDoc { return d }
+	for name, build := range map[string]func(d mongoutils.StrictDoc){
+		"key":                  func(d mongoutils.StrictDoc) { d.Add("$where", "sleep(1000)") },
+		"doc key":              func(d mongoutils.StrictDoc) { d.Doc("$or", empty) },
+		"array":                func(d mongoutils.StrictDoc) { d.Array("$in", 1) },
+		"nested":               func(d mongoutils.StrictDoc) { d.Nested("$expr", "$gt", 1) },
+		"pointer":              func(d mongoutils.StrictDoc) { d.Add("age", &map[string]any{"$ne": nil}) },
+		"nested key":           func(d mongoutils.StrictDoc) { d.Nested("age", "$where", 1) },
+		"nested array key":     func(d mongoutils.StrictDoc) { d.NestedArray("age", "$where", 1) },
+		"nested doc key":       func(d mongoutils.StrictDoc) { d.NestedDoc("age", "$where", empty) },
+		"nested doc array key": func(d mongoutils.StrictDoc) { d.NestedDocArray("age", "$where", empty) },
+	} {
+		doc := mongoutils.NewStrictDoc()
+		build(doc)
+		if !errors.Is(doc.Err(), mongoutils.ErrUnsafeKey) || len(doc.Build()) != 0 {
+			t.Fatal("fail Strict " + name)
+		}
+	}
+	if re, ok := mongoutils.NewStrictDoc().Regex("name", "a.*", "i").Build()[0].Value.(primitive.Regex); !ok || re.Pattern != `a\.\*` {
+		t.Fatal("fail Strict regex", re)
+	}
+	if len(mongoutils.NewDoc().Add("age", mongoutils.Map("$gt", 1)).Build()) != 1 {
+		t.Fatal("fail Strict default")
+	}
+}
@@ -0,0 +1,192 @@
+package mongoutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Collection validation levels
+const (
+	ValidationLevelOff      = "off"
+	ValidationLevelStrict   = "strict"
+	ValidationLevelModerate = "moderate"
+)
+
+// Collection validation actions
+const (
+	ValidationActionError = "error"
+	ValidationActionWarn  = "warn"
+)
+
+// JSONSchema generate $jsonSchema from struct using bson tags
+//
+// pointer, slice, map and interface fields are nullable, omitempty fields are not required.
+// time.Time generated as date, primitive.ObjectID as objectId and nested structs as object
+func JSONSchema(v any) (primitive.M, error) {
+	if v == nil {
+		return nil, errors.New("mongoutils: nil value passed to JSONSchema")
+	}
+	t := indirectType(reflect.TypeOf(v))
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("mongoutils: JSONSchema expect struct, %s given", t)
+	}
+	return objectSchema(t, make(map[reflect.Type]bool)), nil
+}
+
+// EnsureValidator create collection with $jsonSchema validator or update validator of existing collection
+//
+// empty level and action ignored
+func EnsureValidator(ctx context.Context, db *mongo.Database, name string, schema any, level string, action string) error {
+	validator := primitive.M{"$jsonSchema": schema}
+	names, err := db.ListCollectionNames(ctx, primitive.M{"name": name})
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		opt := options.CreateCollection().SetValidator(validator)
+		if level != "" {
+			opt.SetValidationLevel(level)
+		}
+		if action != "" {
+			opt.SetValidationAction(action)
+		}
+		err = db.CreateCollection(ctx, name, opt)
+		if err == nil {
+			return nil
+		}
+		// collection created by another process
+		var cmdErr mongo.CommandError
+		if !errors.As(err, &cmdErr) || cmdErr.Code != 48 {
+			return err
+		}
+	}
+	cmd := primitive.D{
+		{Key: "collMod", Value: name},
+		{Key: "validator", Value: validator},
+	}
+	if level != "" {
+		cmd = append(cmd, primitive.E{Key: "validationLevel", Value: level})
+	}
+	if action != "" {
+		cmd = append(cmd, primitive.E{Key: "validationAction", Value: action})
+	}
+	return db.RunCommand(ctx, cmd).Err()
+}
+
+func objectSchema(t reflect.Type, visiting map[reflect.Type]bool) primitive.M {
+	res := primitive.M{"bsonType": "object"}
+	if visiting[t] {
+		return res
+	}
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	properties := primitive.M{}
+	required := make([]string, 0)
+	var collect func(t reflect.Type)
+	collect = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name, inline, skip := bsonField(f)
+			if skip {
+				continue
+			}
+			if inline {
+				if ft := indirectType(f.Type); ft.Kind() == reflect.Struct {
+					collect(ft)
+				}
+				continue
+			}
+			properties[name] = fieldSchema(f.Type, visiting)
+			if !strings.Contains(f.Tag.Get("bson"), ",omitempty") {
+				required = append(required, name)
+			}
+		}
+	}
+	collect(t)
+	if len(properties) > 0 {
+		res["properties"] = properties
+	}
+	if len(required) > 0 {
+		res["required"] = required
+	}
+	return res
+}
+
+func fieldSchema(t reflect.Type, visiting map[reflect.Type]bool) primitive.M {
+	nullable := false
+	for t.Kind() == reflect.Pointer {
+		nullable = true
+		t = t.Elem()
+	}
+	var res primitive.M
+	switch {
+	case t == timeType || t == dateTimeType:
+		res = primitive.M{"bsonType": "date"}
+	case t == objectIDType:
+		res = primitive.M{"bsonType": "objectId"}
+	case t == decimalType:
+		res = primitive.M{"bsonType": "decimal"}
+	case t == regexType:
+		res = primitive.M{"bsonType": "regex"}
+	case t == timestampType:
+		res = primitive.M{"bsonType": "timestamp"}
+	case t == binaryType:
+		res = primitive.M{"bsonType": "binData"}
+	case t == primitiveDType || t == primitiveMType:
+		res = primitive.M{"bsonType": "object"}
+		nullable = true
+	default:
+		switch t.Kind() {
+		case reflect.String:
+			res = primitive.M{"bsonType": "string"}
+		case reflect.Bool:
+			res = primitive.M{"bsonType": "bool"}
+		case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+			res = primitive.M{"bsonType": "int"}
+		case reflect.Int, reflect.Uint:
+			res = primitive.M{"bsonType": primitive.A{"int", "long"}}
+		case reflect.Int64, reflect.Uint32, reflect.Uint64:
+			res = primitive.M{"bsonType": "long"}
+		case reflect.Float32, reflect.Float64:
+			res = primitive.M{"bsonType": "double"}
+		case reflect.Slice:
+			nullable = true
+			if t.Elem().Kind() == reflect.Uint8 {
+				res = primitive.M{"bsonType": "binData"}
+			} else {
+				res = primitive.M{"bsonType": "array", "items": fieldSchema(t.Elem(), visiting)}
+			}
+		case reflect.Array:
+			res = primitive.M{"bsonType": "array", "items": fieldSchema(t.Elem(), visiting)}
+		case reflect.Map:
+			nullable = true
+			res = primitive.M{"bsonType": "object"}
+		case reflect.Struct:
+			res = objectSchema(t, visiting)
+		case reflect.Interface:
+			// any bson type allowed
+			return primitive.M{}
+		default:
+			return primitive.M{}
+		}
+	}
+	if nullable {
+		res["bsonType"] = appendNullType(res["bsonType"])
+	}
+	return res
+}
+
+func appendNullType(v any) primitive.A {
+	if types, ok := v.(primitive.A); ok {
+		return append(types, "null")
+	}
+	return primitive.A{v, "null"}
+}
@@ -0,0 +1,43 @@
+package mongoutils_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bopher/mongoutils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type schemaAddress struct {
+	City string `bson:"city"`
+}
+
+type schemaPerson struct {
+	mongoutils.Model `bson:",inline"`
+	ID               primitive.ObjectID `bson:"_id,omitempty"`
+	Name             string             `bson:"name"`
+	Age              int32              `bson:"age"`
+	Tags             []string           `bson:"tags"`
+	Birth            *time.Time         `bson:"birth"`
+	Address          schemaAddress      `bson:"address"`
+	Ignored          string             `bson:"-"`
+}
+
+func TestJSONSchema(t *testing.T) {
+	schema, err := mongoutils.JSONSchema(schemaPerson{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := pretty(schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != `{"bsonType":"object","properties":{"_id":{"bsonType":"objectId"},"address":{"bsonType":"object","properties":{"city":{"bsonType":"string"}},"required":["city"]},"age":{"bsonType":"int"},"birth":{"bsonType":["date","null"]},"created_at":{"bsonType":"date"},"name":{"bsonType":"string"},"tags":{"bsonType":["array","null"],"items":{"bsonType":"string"}},"updated_at":{"bsonType":["date","null"]}},"required":["created_at","updated_at","name","age","tags","birth","address"]}` {
+		t.Log(v)
+		t.Fatal("fail JSONSchema")
+	}
+
+	if _, err := mongoutils.JSONSchema("invalid"); err == nil {
+		t.Fatal("fail JSONSchema non struct")
+	}
+}
@@ -0,0 +1,19 @@
+package mongoutils
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// MongoSort sort specification (primitive.D) builder
+type MongoSort interface {
+	// Asc add ascending fields
+	Asc(fields ...string) MongoSort
+	// Desc add descending fields
+	Desc(fields ...string) MongoSort
+	// TextScore add text search score field
+	TextScore(field string) MongoSort
+	// Meta add $meta field
+	Meta(field string, meta string) MongoSort
+	// IsEmpty check if no sort field added
+	IsEmpty() bool
+	// Build generate sort doc with _id field as tiebreaker
+	Build() primitive.D
+}
@@ -0,0 +1,51 @@
+package mongoutils_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bopher/mongoutils"
+)
+
+func TestSort(t *testing.T) {
+	var v string
+	var err error
+
+	// Builder
+	v, err = pretty(mongoutils.NewSort().Desc("created_at").Asc("name").TextScore("score").Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != `[{"Key":"created_at","Value":-1},{"Key":"name","Value":1},{"Key":"score","Value":{"$meta":"textScore"}},{"Key":"_id","Value":1}]` {
+		t.Log(v)
+		t.Fatal("fail Builder")
+	}
+
+	// Tiebreaker
+	v, err = pretty(mongoutils.NewSort().Desc("_id").Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != `[{"Key":"_id","Value":-1}]` {
+		t.Log(v)
+		t.Fatal("fail Tiebreaker")
+	}
+
+	// ParseSort
+	allowed := map[string]string{"created_at": "created_at", "name": "profile.name"}
+	sort, err := mongoutils.ParseSort("-created_at, name", allowed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err = pretty(sort.Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != `[{"Key":"created_at","Value":-1},{"Key":"profile.name","Value":1},{"Key":"_id","Value":1}]` {
+		t.Log(v)
+		t.Fatal("fail ParseSort")
+	}
+	if _, err := mongoutils.ParseSort("-password", allowed); !errors.Is(err, mongoutils.ErrInvalidSort) {
+		t.Fatal("fail ParseSort not allowed")
+	}
+}
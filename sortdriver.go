@@ -0,0 +1,97 @@
+package mongoutils
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ErrInvalidSort invalid sort query
+var ErrInvalidSort = errors.New("mongoutils: invalid sort")
+
+type mSort struct {
+	data primitive.D
+}
+
+func (me *mSort) add(k string, v any) MongoSort {
+	for i, e := range me.data {
+		if e.Key == k {
+			me.data[i].Value = v
+			return me
+		}
+	}
+	me.data = append(me.data, primitive.E{Key: k, Value: v})
+	return me
+}
+
+func (me *mSort) Asc(fields ...string) MongoSort {
+	for _, f := range fields {
+		me.add(f, 1)
+	}
+	return me
+}
+
+func (me *mSort) Desc(fields ...string) MongoSort {
+	for _, f := range fields {
+		me.add(f, -1)
+	}
+	return me
+}
+
+func (me *mSort) TextScore(field string) MongoSort {
+	return me.Meta(field, "textScore")
+}
+
+func (me *mSort) Meta(field string, meta string) MongoSort {
+	return me.add(field, primitive.M{"$meta": meta})
+}
+
+func (me mSort) IsEmpty() bool {
+	return len(me.data) == 0
+}
+
+func (me mSort) Build() primitive.D {
+	res := make(primitive.D, 0, len(me.data)+1)
+	hasID := false
+	for _, e := range me.data {
+		hasID = hasID || e.Key == "_id"
+		res = append(res, e)
+	}
+	if !hasID {
+		res = append(res, primitive.E{Key: "_id", Value: 1})
+	}
+	return res
+}
+
+// ParseSort parse sort query (e.g "-created_at,name")
+//
+// allowed map sortable query field names to document field path, fields prefixed with - sorted descending
+func ParseSort(query string, allowed map[string]string) (MongoSort, error) {
+	res := NewSort()
+	for _, part := range strings.Split(query, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		desc := false
+		switch part[0] {
+		case '-':
+			desc = true
+			part = part[1:]
+		case '+':
+			part = part[1:]
+		}
+		field, ok := allowed[part]
+		if !ok || field == "" {
+			return nil, fmt.Errorf("%w: field %q is not sortable", ErrInvalidSort, part)
+		}
+		if desc {
+			res.Desc(field)
+		} else {
+			res.Asc(field)
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,1176 @@
+package mongoutils
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ErrInvalidSQL invalid or unsupported sql query
+var ErrInvalidSQL = errors.New("mongoutils: invalid sql")
+
+// SQLError sql parse or translation error
+type SQLError struct {
+	// Pos zero based byte offset in query
+	Pos int
+	Msg string
+}
+
+// Error implement error interface
+func (me *SQLError) Error() string {
+	return fmt.Sprintf("%s at position %d: %s", ErrInvalidSQL, me.Pos, me.Msg)
+}
+
+// Unwrap get ErrInvalidSQL
+func (me *SQLError) Unwrap() error {
+	return ErrInvalidSQL
+}
+
+// SQLQuery translated sql select
+type SQLQuery struct {
+	// Collection base collection from FROM clause
+	Collection string
+	Pipeline   MongoPipeline
+}
+
+// ParseSQL translate sql select subset to aggregation pipeline
+//
+// supports projections with aliases, WHERE with AND/OR/NOT/IN/LIKE/BETWEEN/IS NULL,
+// [LEFT] JOIN with single equality, GROUP BY with COUNT/SUM/AVG/MIN/MAX, HAVING, ORDER BY and LIMIT/OFFSET.
+// returns *SQLError wrapping ErrInvalidSQL
+func ParseSQL(query string) (SQLQuery, error) {
+	tokens, err := lexSQL(query)
+	if err != nil {
+		return SQLQuery{}, err
+	}
+	p := &sqlParser{tokens: tokens}
+	stmt, err := p.statement()
+	if err != nil {
+		return SQLQuery{}, err
+	}
+	c := &sqlCompiler{
+		stmt:       stmt,
+		joins:      make(map[string]bool),
+		groupNames: make(map[string]string),
+		accNames:   make(map[string]string),
+		aliases:    make(map[string]sqlExpr),
+	}
+	pipe, err := c.compile()
+	if err != nil {
+		return SQLQuery{}, err
+	}
+	return SQLQuery{Collection: stmt.from, Pipeline: pipe}, nil
+}
+
+// Lexer
+const (
+	sqlEOF = iota
+	sqlIdent
+	sqlQuoted
+	sqlNumber
+	sqlString
+	sqlSymbol
+)
+
+type sqlToken struct {
+	kind int
+	text string
+	pos  int
+}
+
+func lexSQL(src string) ([]sqlToken, error) {
+	res := make([]sqlToken, 0)
+	i := 0
+	for i < len(src) {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case isSQLIdentStart(c):
+			start := i
+			for i < len(src) && (isSQLIdentStart(src[i]) || src[i] >= '0' && src[i] <= '9') {
+				i++
+			}
+			res = append(res, sqlToken{kind: sqlIdent, text: src[start:i], pos: start})
+		case c >= '0' && c <= '9' || c == '-' && i+1 < len(src) && src[i+1] >= '0' && src[i+1] <= '9':
+			start := i
+			i++
+			for i < len(src) && (src[i] >= '0' && src[i] <= '9' || src[i] == '.') {
+				i++
+			}
+			res = append(res, sqlToken{kind: sqlNumber, text: src[start:i], pos: start})
+		case c == '\'' || c == '"' || c == '`':
+			start := i
+			var sb strings.Builder
+			closed := false
+			for i++; i < len(src); i++ {
+				if src[i] == c {
+					// doubled quote escape
+					if i+1 < len(src) && src[i+1] == c {
+						sb.WriteByte(c)
+						i++
+						continue
+					}
+					closed = true
+					i++
+					break
+				}
+				sb.WriteByte(src[i])
+			}
+			if !closed {
+				return nil, &SQLError{Pos: start, Msg: "unclosed quote"}
+			}
+			kind := sqlQuoted
+			if c == '\'' {
+				kind = sqlString
+			} else if !isSQLSafeName(sb.String()) {
+				return nil, &SQLError{Pos: start, Msg: fmt.Sprintf("invalid name %q", sb.String())}
+			}
+			res = append(res, sqlToken{kind: kind, text: sb.String(), pos: start})
+		default:
+			if i+1 < len(src) {
+				switch src[i : i+2] {
+				case "<=", ">=", "<>", "!=":
+					res = append(res, sqlToken{kind: sqlSymbol, text: src[i : i+2], pos: i})
+					i += 2
+					continue
+				}
+			}
+			if !strings.ContainsRune(",()*.=<>;", rune(c)) {
+				return nil, &SQLError{Pos: i, Msg: fmt.Sprintf("unexpected %q", c)}
+			}
+			res = append(res, sqlToken{kind: sqlSymbol, text: string(c), pos: i})
+			i++
+		}
+	}
+	return append(res, sqlToken{kind: sqlEOF, pos: len(src)}), nil
+}
+
+func isSQLIdentStart(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_'
+}
+
+// isSQLSafeName check name path parts not operator ($ prefix) and not contains NUL
+func isSQLSafeName(name string) bool {
+	for _, part := range strings.Split(name, ".") {
+		if strings.HasPrefix(part, "$") || strings.ContainsRune(part, 0) {
+			return false
+		}
+	}
+	return true
+}
+
+var sqlKeywords = map[string]bool{
+	"SELECT": true, "FROM": true, "WHERE": true, "GROUP": true, "BY": true, "HAVING": true,
+	"ORDER": true, "ASC": true, "DESC": true, "LIMIT": true, "OFFSET": true, "AS": true,
+	"JOIN": true, "LEFT": true, "INNER": true, "OUTER": true, "ON": true, "AND": true,
+	"OR": true, "NOT": true, "IN": true, "LIKE": true, "BETWEEN": true, "IS": true,
+	"NULL": true, "TRUE": true, "FALSE": true,
+}
+
+var sqlAggregates = map[string]string{
+	"COUNT": "$sum",
+	"SUM":   "$sum",
+	"AVG":   "$avg",
+	"MIN":   "$min",
+	"MAX":   "$max",
+}
+
+// Ast
+type sqlExpr interface{}
+
+type sqlColumn struct {
+	parts []string
+	pos   int
+}
+
+type sqlLiteral struct {
+	value any
+	pos   int
+}
+
+type sqlCall struct {
+	name string
+	arg  *sqlColumn // nil for COUNT(*)
+	pos  int
+}
+
+type sqlLogical struct {
+	op    string // $and or $or
+	items []sqlExpr
+}
+
+type sqlNot struct {
+	expr sqlExpr
+}
+
+type sqlCompare struct {
+	op    string
+	left  sqlExpr
+	right sqlExpr
+	pos   int
+}
+
+type sqlIn struct {
+	expr sqlExpr
+	list []sqlExpr
+	not  bool
+	pos  int
+}
+
+type sqlLike struct {
+	expr    sqlExpr
+	pattern string
+	not     bool
+	pos     int
+}
+
+type sqlBetween struct {
+	expr sqlExpr
+	low  sqlExpr
+	high sqlExpr
+	not  bool
+	pos  int
+}
+
+type sqlIsNull struct {
+	expr sqlExpr
+	not  bool
+	pos  int
+}
+
+type sqlItem struct {
+	expr  sqlExpr
+	alias string
+	pos   int
+}
+
+type sqlJoin struct {
+	table string
+	alias string
+	left  bool
+	on    [2]*sqlColumn
+	pos   int
+}
+
+type sqlOrder struct {
+	expr sqlExpr
+	desc bool
+	pos  int
+}
+
+type sqlSelect struct {
+	star    bool
+	items   []sqlItem
+	from    string
+	alias   string
+	joins   []sqlJoin
+	where   sqlExpr
+	groupBy []*sqlColumn
+	having  sqlExpr
+	orderBy []sqlOrder
+	limit   int64
+	offset  int64
+}
+
+func exprPos(e sqlExpr) int {
+	switch v := e.(type) {
+	case *sqlColumn:
+		return v.pos
+	case *sqlLiteral:
+		return v.pos
+	case *sqlCall:
+		return v.pos
+	case *sqlCompare:
+		return v.pos
+	case *sqlIn:
+		return v.pos
+	case *sqlLike:
+		return v.pos
+	case *sqlBetween:
+		return v.pos
+	case *sqlIsNull:
+		return v.pos
+	case *sqlNot:
+		return exprPos(v.expr)
+	case *sqlLogical:
+		return exprPos(v.items[0])
+	}
+	return 0
+}
+
+// Parser
+type sqlParser struct {
+	tokens []sqlToken
+	pos    int
+}
+
+func (me *sqlParser) peek() sqlToken {
+	return me.tokens[me.pos]
+}
+
+func (me *sqlParser) next() sqlToken {
+	t := me.tokens[me.pos]
+	if t.kind != sqlEOF {
+		me.pos++
+	}
+	return t
+}
+
+func (me *sqlParser) fail(t sqlToken, msg string) error {
+	return &SQLError{Pos: t.pos, Msg: msg}
+}
+
+func (me *sqlParser) unexpected(t sqlToken) error {
+	if t.kind == sqlEOF {
+		return me.fail(t, "unexpected end of query")
+	}
+	return me.fail(t, fmt.Sprintf("unexpected %q", t.text))
+}
+
+func (me *sqlParser) isKeyword(word string) bool {
+	t := me.peek()
+	return t.kind == sqlIdent && strings.EqualFold(t.text, word)
+}
+
+func (me *sqlParser) acceptKeyword(word string) bool {
+	if me.isKeyword(word) {
+		me.pos++
+		return true
+	}
+	return false
+}
+
+func (me *sqlParser) expectKeyword(word string) error {
+	if !me.acceptKeyword(word) {
+		return me.fail(me.peek(), "expected "+word)
+	}
+	return nil
+}
+
+func (me *sqlParser) acceptSymbol(s string) bool {
+	t := me.peek()
+	if t.kind == sqlSymbol && t.text == s {
+		me.pos++
+		return true
+	}
+	return false
+}
+
+func (me *sqlParser) expectSymbol(s string) error {
+	if !me.acceptSymbol(s) {
+		return me.fail(me.peek(), fmt.Sprintf("expected %q", s))
+	}
+	return nil
+}
+
+// isName check if next token is non keyword identifier or quoted identifier
+func (me *sqlParser) isName() bool {
+	t := me.peek()
+	return t.kind == sqlQuoted || t.kind == sqlIdent && !sqlKeywords[strings.ToUpper(t.text)]
+}
+
+func (me *sqlParser) name() (string, error) {
+	if !me.isName() {
+		return "", me.fail(me.peek(), "expected identifier")
+	}
+	return me.next().text, nil
+}
+
+func (me *sqlParser) alias() (string, error) {
+	if me.acceptKeyword("AS") {
+		return me.name()
+	}
+	if me.isName() {
+		return me.next().text, nil
+	}
+	return "", nil
+}
+
+func (me *sqlParser) integer() (int64, error) {
+	t := me.next()
+	if t.kind == sqlNumber {
+		if n, err := strconv.ParseInt(t.text, 10, 64); err == nil && n >= 0 {
+			return n, nil
+		}
+	}
+	return 0, me.fail(t, "expected non-negative integer")
+}
+
+func (me *sqlParser) statement() (*sqlSelect, error) {
+	res := &sqlSelect{}
+	var err error
+	if err = me.expectKeyword("SELECT"); err != nil {
+		return nil, err
+	}
+	if me.acceptSymbol("*") {
+		res.star = true
+	} else {
+		for {
+			item := sqlItem{pos: me.peek().pos}
+			if item.expr, err = me.operand(); err != nil {
+				return nil, err
+			}
+			if item.alias, err = me.alias(); err != nil {
+				return nil, err
+			}
+			res.items = append(res.items, item)
+			if !me.acceptSymbol(",") {
+				break
+			}
+		}
+	}
+
+	if err = me.expectKeyword("FROM"); err != nil {
+		return nil, err
+	}
+	if res.from, err = me.name(); err != nil {
+		return nil, err
+	}
+	if res.alias, err = me.alias(); err != nil {
+		return nil, err
+	}
+	if res.alias == "" {
+		res.alias = res.from
+	}
+
+	for {
+		join := sqlJoin{pos: me.peek().pos}
+		if me.acceptKeyword("LEFT") {
+			me.acceptKeyword("OUTER")
+			join.left = true
+			if err = me.expectKeyword("JOIN"); err != nil {
+				return nil, err
+			}
+		} else if me.acceptKeyword("INNER") {
+			if err = me.expectKeyword("JOIN"); err != nil {
+				return nil, err
+			}
+		} else if !me.acceptKeyword("JOIN") {
+			break
+		}
+		if join.table, err = me.name(); err != nil {
+			return nil, err
+		}
+		if join.alias, err = me.alias(); err != nil {
+			return nil, err
+		}
+		if join.alias == "" {
+			join.alias = join.table
+		}
+		if err = me.expectKeyword("ON"); err != nil {
+			return nil, err
+		}
+		if join.on[0], err = me.column(); err != nil {
+			return nil, err
+		}
+		if err = me.expectSymbol("="); err != nil {
+			return nil, err
+		}
+		if join.on[1], err = me.column(); err != nil {
+			return nil, err
+		}
+		res.joins = append(res.joins, join)
+	}
+
+	if me.acceptKeyword("WHERE") {
+		if res.where, err = me.or(); err != nil {
+			return nil, err
+		}
+	}
+	if me.acceptKeyword("GROUP") {
+		if err = me.expectKeyword("BY"); err != nil {
+			return nil, err
+		}
+		for {
+			col, err := me.column()
+			if err != nil {
+				return nil, err
+			}
+			res.groupBy = append(res.groupBy, col)
+			if !me.acceptSymbol(",") {
+				break
+			}
+		}
+	}
+	if me.acceptKeyword("HAVING") {
+		if res.having, err = me.or(); err != nil {
+			return nil, err
+		}
+	}
+	if me.acceptKeyword("ORDER") {
+		if err = me.expectKeyword("BY"); err != nil {
+			return nil, err
+		}
+		for {
+			order := sqlOrder{pos: me.peek().pos}
+			if order.expr, err = me.operand(); err != nil {
+				return nil, err
+			}
+			if me.acceptKeyword("DESC") {
+				order.desc = true
+			} else {
+				me.acceptKeyword("ASC")
+			}
+			res.orderBy = append(res.orderBy, order)
+			if !me.acceptSymbol(",") {
+				break
+			}
+		}
+	}
+	if me.acceptKeyword("LIMIT") {
+		if res.limit, err = me.integer(); err != nil {
+			return nil, err
+		}
+	}
+	if me.acceptKeyword("OFFSET") {
+		if res.offset, err = me.integer(); err != nil {
+			return nil, err
+		}
+	}
+	me.acceptSymbol(";")
+	if t := me.peek(); t.kind != sqlEOF {
+		return nil, me.unexpected(t)
+	}
+	return res, nil
+}
+
+func (me *sqlParser) or() (sqlExpr, error) {
+	left, err := me.and()
+	if err != nil {
+		return nil, err
+	}
+	items := []sqlExpr{left}
+	for me.acceptKeyword("OR") {
+		right, err := me.and()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, right)
+	}
+	if len(items) == 1 {
+		return left, nil
+	}
+	return &sqlLogical{op: "$or", items: items}, nil
+}
+
+func (me *sqlParser) and() (sqlExpr, error) {
+	left, err := me.not()
+	if err != nil {
+		return nil, err
+	}
+	items := []sqlExpr{left}
+	for me.acceptKeyword("AND") {
+		right, err := me.not()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, right)
+	}
+	if len(items) == 1 {
+		return left, nil
+	}
+	return &sqlLogical{op: "$and", items: items}, nil
+}
+
+func (me *sqlParser) not() (sqlExpr, error) {
+	if me.acceptKeyword("NOT") {
+		e, err := me.not()
+		if err != nil {
+			return nil, err
+		}
+		return &sqlNot{expr: e}, nil
+	}
+	return me.predicate()
+}
+
+func (me *sqlParser) predicate() (sqlExpr, error) {
+	if me.acceptSymbol("(") {
+		e, err := me.or()
+		if err != nil {
+			return nil, err
+		}
+		if err := me.expectSymbol(")"); err != nil {
+			return nil, err
+		}
+		return e, nil
+	}
+	start := me.peek().pos
+	left, err := me.operand()
+	if err != nil {
+		return nil, err
+	}
+
+	if me.acceptKeyword("IS") {
+		not := me.acceptKeyword("NOT")
+		if err := me.expectKeyword("NULL"); err != nil {
+			return nil, err
+		}
+		return &sqlIsNull{expr: left, not: not, pos: start}, nil
+	}
+	not := me.acceptKeyword("NOT")
+	switch {
+	case me.acceptKeyword("IN"):
+		if err := me.expectSymbol("("); err != nil {
+			return nil, err
+		}
+		res := &sqlIn{expr: left, not: not, pos: start}
+		for {
+			v, err := me.operand()
+			if err != nil {
+				return nil, err
+			}
+			res.list = append(res.list, v)
+			if !me.acceptSymbol(",") {
+				break
+			}
+		}
+		if err := me.expectSymbol(")"); err != nil {
+			return nil, err
+		}
+		return res, nil
+	case me.acceptKeyword("LIKE"):
+		t := me.next()
+		if t.kind != sqlString {
+			return nil, me.fail(t, "expected string pattern")
+		}
+		return &sqlLike{expr: left, pattern: t.text, not: not, pos: start}, nil
+	case me.acceptKeyword("BETWEEN"):
+		res := &sqlBetween{expr: left, not: not, pos: start}
+		if res.low, err = me.operand(); err != nil {
+			return nil, err
+		}
+		if err = me.expectKeyword("AND"); err != nil {
+			return nil, err
+		}
+		if res.high, err = me.operand(); err != nil {
+			return nil, err
+		}
+		return res, nil
+	case not:
+		return nil, me.fail(me.peek(), "expected IN, LIKE or BETWEEN")
+	}
+
+	t := me.peek()
+	if t.kind == sqlSymbol {
+		switch t.text {
+		case "=", "!=", "<>", "<", "<=", ">", ">=":
+			me.pos++
+			right, err := me.operand()
+			if err != nil {
+				return nil, err
+			}
+			return &sqlCompare{op: t.text, left: left, right: right, pos: start}, nil
+		}
+	}
+	return nil, me.fail(t, "expected comparison")
+}
+
+func (me *sqlParser) operand() (sqlExpr, error) {
+	t := me.peek()
+	switch t.kind {
+	case sqlNumber:
+		me.pos++
+		if n, err := strconv.ParseInt(t.text, 10, 64); err == nil {
+			return &sqlLiteral{value: n, pos: t.pos}, nil
+		}
+		if f, err := strconv.ParseFloat(t.text, 64); err == nil {
+			return &sqlLiteral{value: f, pos: t.pos}, nil
+		}
+		return nil, me.fail(t, "invalid number")
+	case sqlString:
+		me.pos++
+		return &sqlLiteral{value: t.text, pos: t.pos}, nil
+	case sqlIdent:
+		switch strings.ToUpper(t.text) {
+		case "NULL":
+			me.pos++
+			return &sqlLiteral{value: nil, pos: t.pos}, nil
+		case "TRUE", "FALSE":
+			me.pos++
+			return &sqlLiteral{value: strings.EqualFold(t.text, "TRUE"), pos: t.pos}, nil
+		}
+		name := strings.ToUpper(t.text)
+		if _, ok := sqlAggregates[name]; ok && me.tokens[me.pos+1].kind == sqlSymbol && me.tokens[me.pos+1].text == "(" {
+			me.pos += 2
+			call := &sqlCall{name: name, pos: t.pos}
+			if name == "COUNT" && me.acceptSymbol("*") {
+				// COUNT(*)
+			} else {
+				col, err := me.column()
+				if err != nil {
+					return nil, err
+				}
+				call.arg = col
+			}
+			if err := me.expectSymbol(")"); err != nil {
+				return nil, err
+			}
+			return call, nil
+		}
+	}
+	if me.isName() {
+		return me.column()
+	}
+	return nil, me.unexpected(t)
+}
+
+func (me *sqlParser) column() (*sqlColumn, error) {
+	res := &sqlColumn{pos: me.peek().pos}
+	for {
+		t := me.next()
+		if t.kind != sqlIdent && t.kind != sqlQuoted {
+			return nil, me.fail(t, "expected column")
+		}
+		if len(res.parts) == 0 && t.kind == sqlIdent && sqlKeywords[strings.ToUpper(t.text)] {
+			return nil, me.fail(t, "expected column")
+		}
+		res.parts = append(res.parts, t.text)
+		if !me.acceptSymbol(".") {
+			return res, nil
+		}
+	}
+}
+
+// Compiler
+type sqlCompiler struct {
+	stmt       *sqlSelect
+	joins      map[string]bool
+	grouped    bool
+	groupID    any
+	groupNames map[string]string // document path to grouped doc path
+	accs       primitive.D
+	accNames   map[string]string // aggregate call to accumulator field
+	aliases    map[string]sqlExpr
+}
+
+func (me *sqlCompiler) fail(pos int, msg string) error {
+	return &SQLError{Pos: pos, Msg: msg}
+}
+
+func (me *sqlCompiler) compile() (MongoPipeline, error) {
+	stmt := me.stmt
+	pipe := NewPipe()
+
+	// joins
+	for _, j := range stmt.joins {
+		if j.alias == stmt.alias || me.joins[j.alias] {
+			return nil, me.fail(j.pos, fmt.Sprintf("duplicate table alias %q", j.alias))
+		}
+		var local, foreign *sqlColumn
+		for i, col := range j.on {
+			if len(col.parts) > 1 && col.parts[0] == j.alias {
+				foreign, local = col, j.on[1-i]
+				break
+			}
+		}
+		if foreign == nil || len(local.parts) > 1 && local.parts[0] == j.alias {
+			return nil, me.fail(j.on[0].pos, fmt.Sprintf("join condition must compare %s column with previous table column", j.alias))
+		}
+		localPath, err := me.path(local)
+		if err != nil {
+			return nil, err
+		}
+		me.joins[j.alias] = true
+		pipe.
+			Lookup(j.table, localPath, strings.Join(foreign.parts[1:], "."), j.alias).
+			Unwind("$"+j.alias, j.left)
+	}
+
+	// where
+	if stmt.where != nil {
+		filter, err := me.filter(stmt.where, false)
+		if err != nil {
+			return nil, err
+		}
+		pipe.Match(filter)
+	}
+
+	// group
+	for _, item := range stmt.items {
+		if item.alias != "" {
+			me.aliases[item.alias] = item.expr
+		}
+		if _, ok := item.expr.(*sqlCall); ok {
+			me.grouped = true
+		}
+	}
+	me.grouped = me.grouped || len(stmt.groupBy) > 0 || stmt.having != nil
+	if me.grouped {
+		if stmt.star {
+			return nil, me.fail(0, "SELECT * can not be used with GROUP BY or aggregates")
+		}
+		if err := me.group(); err != nil {
+			return nil, err
+		}
+	}
+
+	// having, sort and projection compiled before group stage added to register accumulators
+	var having primitive.D
+	if stmt.having != nil {
+		var err error
+		if having, err = me.filter(stmt.having, true); err != nil {
+			return nil, err
+		}
+	}
+	var sort primitive.D
+	for _, o := range stmt.orderBy {
+		field, err := me.ref(o.expr, true)
+		if err != nil {
+			return nil, err
+		}
+		dir := 1
+		if o.desc {
+			dir = -1
+		}
+		sort = append(sort, primitive.E{Key: field, Value: dir})
+	}
+	var projection MongoProjection
+	if !stmt.star {
+		var err error
+		if projection, err = me.projection(); err != nil {
+			return nil, err
+		}
+	}
+
+	if me.grouped {
+		pipe.Group(func(d MongoDoc) MongoDoc {
+			d.Add("_id", me.groupID)
+			for _, acc := range me.accs {
+				d.Add(acc.Key, acc.Value)
+			}
+			return d
+		})
+	}
+	if having != nil {
+		pipe.Match(having)
+	}
+	if len(sort) > 0 {
+		pipe.Sort(sort)
+	}
+	pipe.Skip(stmt.offset).Limit(stmt.limit)
+	if projection != nil {
+		pipe.Project(projection)
+	}
+	return pipe, nil
+}
+
+// path resolve column to document path before group stage
+func (me *sqlCompiler) path(col *sqlColumn) (string, error) {
+	for _, part := range col.parts {
+		if !isSQLSafeName(part) {
+			return "", me.fail(col.pos, fmt.Sprintf("invalid column %q", part))
+		}
+	}
+	if len(col.parts) > 1 {
+		if col.parts[0] == me.stmt.alias {
+			return strings.Join(col.parts[1:], "."), nil
+		}
+		if !me.joins[col.parts[0]] {
+			for _, j := range me.stmt.joins {
+				if j.alias == col.parts[0] {
+					return "", me.fail(col.pos, fmt.Sprintf("table %q used before join", j.alias))
+				}
+			}
+		}
+	}
+	return strings.Join(col.parts, "."), nil
+}
+
+func (me *sqlCompiler) group() error {
+	stmt := me.stmt
+	if len(stmt.groupBy) == 1 {
+		p, err := me.path(stmt.groupBy[0])
+		if err != nil {
+			return err
+		}
+		me.groupNames[p] = "_id"
+		me.groupID = "$" + p
+	} else if len(stmt.groupBy) > 1 {
+		id := primitive.D{}
+		for _, col := range stmt.groupBy {
+			p, err := me.path(col)
+			if err != nil {
+				return err
+			}
+			key := strings.ReplaceAll(p, ".", "_")
+			me.groupNames[p] = "_id." + key
+			id = append(id, primitive.E{Key: key, Value: "$" + p})
+		}
+		me.groupID = id
+	}
+	// aliased aggregates named by alias
+	for _, item := range stmt.items {
+		if call, ok := item.expr.(*sqlCall); ok && item.alias != "" {
+			if _, err := me.accumulator(call, item.alias); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (me *sqlCompiler) accumulator(call *sqlCall, name string) (string, error) {
+	arg := "*"
+	if call.arg != nil {
+		p, err := me.path(call.arg)
+		if err != nil {
+			return "", err
+		}
+		arg = p
+	}
+	key := call.name + "(" + arg + ")"
+	if n, ok := me.accNames[key]; ok {
+		return n, nil
+	}
+	if name == "" {
+		name = strings.ToLower(call.name)
+		if call.arg != nil {
+			name += "_" + strings.ReplaceAll(arg, ".", "_")
+		}
+	}
+	for _, acc := range me.accs {
+		if acc.Key == name {
+			return "", me.fail(call.pos, fmt.Sprintf("duplicate aggregate name %q", name))
+		}
+	}
+	if name == "_id" {
+		return "", me.fail(call.pos, "aggregate can not be named _id")
+	}
+
+	var value any
+	switch {
+	case call.arg == nil:
+		value = primitive.D{{Key: "$sum", Value: 1}}
+	case call.name == "COUNT":
+		isNull := primitive.D{{Key: "$eq", Value: primitive.A{primitive.D{{Key: "$ifNull", Value: primitive.A{"$" + arg, nil}}}, nil}}}
+		value = primitive.D{{Key: "$sum", Value: primitive.D{{Key: "$cond", Value: primitive.A{isNull, 0, 1}}}}}
+	default:
+		value = primitive.D{{Key: sqlAggregates[call.name], Value: "$" + arg}}
+	}
+	me.accNames[key] = name
+	me.accs = append(me.accs, primitive.E{Key: name, Value: value})
+	return name, nil
+}
+
+// ref resolve operand to field path, post for documents after group stage (HAVING and ORDER BY)
+func (me *sqlCompiler) ref(e sqlExpr, post bool) (string, error) {
+	if col, ok := e.(*sqlColumn); ok && post && len(col.parts) == 1 {
+		if aliased, ok := me.aliases[col.parts[0]]; ok {
+			if call, ok := aliased.(*sqlCall); ok && me.grouped {
+				return me.accumulator(call, col.parts[0])
+			}
+			e = aliased
+		}
+	}
+	switch v := e.(type) {
+	case *sqlColumn:
+		p, err := me.path(v)
+		if err != nil {
+			return "", err
+		}
+		if !post || !me.grouped {
+			return p, nil
+		}
+		if g, ok := me.groupNames[p]; ok {
+			return g, nil
+		}
+		return "", me.fail(v.pos, fmt.Sprintf("column %q must appear in GROUP BY", strings.Join(v.parts, ".")))
+	case *sqlCall:
+		if !post || !me.grouped {
+			return "", me.fail(v.pos, "aggregate not allowed here")
+		}
+		return me.accumulator(v, "")
+	}
+	return "", me.fail(exprPos(e), "expected column")
+}
+
+var sqlOperators = map[string]string{
+	"=":  "$eq",
+	"!=": "$ne",
+	"<>": "$ne",
+	"<":  "$lt",
+	"<=": "$lte",
+	">":  "$gt",
+	">=": "$gte",
+}
+
+var sqlFlipped = map[string]string{
+	"=": "=", "!=": "!=", "<>": "<>", "<": ">", "<=": ">=", ">": "<", ">=": "<=",
+}
+
+func (me *sqlCompiler) literal(e sqlExpr) (any, error) {
+	if l, ok := e.(*sqlLiteral); ok {
+		return l.value, nil
+	}
+	return nil, me.fail(exprPos(e), "expected literal")
+}
+
+func (me *sqlCompiler) filter(e sqlExpr, post bool) (primitive.D, error) {
+	switch v := e.(type) {
+	case *sqlLogical:
+		items := make(primitive.A, 0, len(v.items))
+		for _, item := range v.items {
+			f, err := me.filter(item, post)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, f)
+		}
+		return primitive.D{{Key: v.op, Value: items}}, nil
+	case *sqlNot:
+		f, err := me.filter(v.expr, post)
+		if err != nil {
+			return nil, err
+		}
+		return primitive.D{{Key: "$nor", Value: primitive.A{f}}}, nil
+	case *sqlCompare:
+		left, right, op := v.left, v.right, v.op
+		if _, ok := left.(*sqlLiteral); ok {
+			left, right, op = right, left, sqlFlipped[op]
+		}
+		field, err := me.ref(left, post)
+		if err != nil {
+			return nil, err
+		}
+		if lit, ok := right.(*sqlLiteral); ok {
+			if op == "=" {
+				return primitive.D{{Key: field, Value: lit.value}}, nil
+			}
+			return primitive.D{{Key: field, Value: primitive.D{{Key: sqlOperators[op], Value: lit.value}}}}, nil
+		}
+		other, err := me.ref(right, post)
+		if err != nil {
+			return nil, err
+		}
+		expr := primitive.D{{Key: sqlOperators[op], Value: primitive.A{"$" + field, "$" + other}}}
+		return primitive.D{{Key: "$expr", Value: expr}}, nil
+	case *sqlIn:
+		field, err := me.ref(v.expr, post)
+		if err != nil {
+			return nil, err
+		}
+		values := make(primitive.A, 0, len(v.list))
+		for _, item := range v.list {
+			lit, err := me.literal(item)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, lit)
+		}
+		op := "$in"
+		if v.not {
+			op = "$nin"
+		}
+		return primitive.D{{Key: field, Value: primitive.D{{Key: op, Value: values}}}}, nil
+	case *sqlLike:
+		field, err := me.ref(v.expr, post)
+		if err != nil {
+			return nil, err
+		}
+		rx := primitive.Regex{Pattern: likePattern(v.pattern)}
+		if v.not {
+			return primitive.D{{Key: field, Value: primitive.D{{Key: "$not", Value: rx}}}}, nil
+		}
+		return primitive.D{{Key: field, Value: rx}}, nil
+	case *sqlBetween:
+		field, err := me.ref(v.expr, post)
+		if err != nil {
+			return nil, err
+		}
+		low, err := me.literal(v.low)
+		if err != nil {
+			return nil, err
+		}
+		high, err := me.literal(v.high)
+		if err != nil {
+			return nil, err
+		}
+		if v.not {
+			return primitive.D{{Key: "$or", Value: primitive.A{
+				primitive.D{{Key: field, Value: primitive.D{{Key: "$lt", Value: low}}}},
+				primitive.D{{Key: field, Value: primitive.D{{Key: "$gt", Value: high}}}},
+			}}}, nil
+		}
+		return primitive.D{{Key: field, Value: primitive.D{{Key: "$gte", Value: low}, {Key: "$lte", Value: high}}}}, nil
+	case *sqlIsNull:
+		field, err := me.ref(v.expr, post)
+		if err != nil {
+			return nil, err
+		}
+		if v.not {
+			return primitive.D{{Key: field, Value: primitive.D{{Key: "$ne", Value: nil}}}}, nil
+		}
+		return primitive.D{{Key: field, Value: nil}}, nil
+	}
+	return nil, me.fail(exprPos(e), "expected condition")
+}
+
+// likePattern convert sql like pattern to anchored regex, % match any string and _ any character
+func likePattern(pattern string) string {
+	var sb strings.Builder
+	sb.WriteByte('^')
+	for _, r := range pattern {
+		switch r {
+		case '%':
+			sb.WriteString(".*")
+		case '_':
+			sb.WriteByte('.')
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	sb.WriteByte('$')
+	return sb.String()
+}
+
+func (me *sqlCompiler) projection() (MongoProjection, error) {
+	res := NewProjection()
+	names := make(map[string]bool)
+	fields := make([]primitive.E, 0, len(me.stmt.items))
+	for _, item := range me.stmt.items {
+		name := item.alias
+		var value any
+		switch v := item.expr.(type) {
+		case *sqlColumn:
+			field, err := me.ref(v, true)
+			if err != nil {
+				return nil, err
+			}
+			if name == "" {
+				name = v.parts[len(v.parts)-1]
+			}
+			value = "$" + field
+		case *sqlCall:
+			field, err := me.accumulator(v, item.alias)
+			if err != nil {
+				return nil, err
+			}
+			if name == "" {
+				name = field
+			}
+			value = "$" + field
+		case *sqlLiteral:
+			if name == "" {
+				return nil, me.fail(item.pos, "literal column requires alias")
+			}
+			value = primitive.D{{Key: "$literal", Value: v.value}}
+		}
+		if names[name] {
+			return nil, me.fail(item.pos, fmt.Sprintf("duplicate column name %q", name))
+		}
+		names[name] = true
+		fields = append(fields, primitive.E{Key: name, Value: value})
+	}
+	if !names["_id"] {
+		res.Exclude("_id")
+	}
+	for _, f := range fields {
+		res.Computed(f.Key, f.Value)
+	}
+	return res, res.Err()
+}
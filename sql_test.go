@@ -0,0 +1,102 @@
+package mongoutils_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/bopher/mongoutils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestSQL(t *testing.T) {
+	ctx := context.TODO()
+	db := mongoutils.NewMemoryDatabase()
+	db.Collection("users").InsertMany(ctx, []any{
+		mongoutils.Map("_id", 1, "name", "John", "city", "Paris"),
+		mongoutils.Map("_id", 2, "name", "Jack", "city", "Rome"),
+		mongoutils.Map("_id", 3, "name", "Jane", "city", "Paris"),
+	})
+	db.Collection("orders").InsertMany(ctx, []any{
+		mongoutils.Map("_id", 1, "user", 1, "amount", 10, "status", "paid"),
+		mongoutils.Map("_id", 2, "user", 1, "amount", 30, "status", "paid"),
+		mongoutils.Map("_id", 3, "user", 2, "amount", 5, "status", "paid"),
+		mongoutils.Map("_id", 4, "user", 3, "amount", 50, "status", "canceled"),
+		mongoutils.Map("_id", 5, "user", 9, "amount", 7, "status", nil),
+	})
+
+	run := func(query string) []primitive.M {
+		t.Helper()
+		q, err := mongoutils.ParseSQL(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cursor, err := db.Collection(q.Collection).Aggregate(ctx, q.Pipeline.Build())
+		if err != nil {
+			t.Fatal(err)
+		}
+		res := make([]primitive.M, 0)
+		if err := cursor.All(ctx, &res); err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	// Where
+	res := run(`SELECT _id AS id, amount FROM orders WHERE status IN ('paid') AND amount BETWEEN 6 AND 40 AND NOT user = 2 ORDER BY amount DESC`)
+	if v, _ := pretty(res); v != `[{"amount":30,"id":2},{"amount":10,"id":1}]` {
+		t.Log(v)
+		t.Fatal("fail Where")
+	}
+	res = run(`SELECT name FROM users WHERE name LIKE 'Ja%' OR city IS NULL ORDER BY name LIMIT 1 OFFSET 1`)
+	if v, _ := pretty(res); v != `[{"name":"Jane"}]` {
+		t.Log(v)
+		t.Fatal("fail Like")
+	}
+
+	// Join
+	res = run(`SELECT o._id AS id, u.name AS customer FROM orders o JOIN users u ON o.user = u._id WHERE u.city = 'Paris' AND o.status IS NOT NULL ORDER BY o.amount`)
+	if v, _ := pretty(res); v != `[{"customer":"John","id":1},{"customer":"John","id":2},{"customer":"Jane","id":4}]` {
+		t.Log(v)
+		t.Fatal("fail Join")
+	}
+	if res = run(`SELECT o._id AS id FROM orders o LEFT JOIN users u ON u._id = o.user`); len(res) != 5 {
+		t.Fatal("fail Left Join")
+	}
+
+	// Group
+	res = run(`SELECT u.city, COUNT(*) AS orders, SUM(o.amount) AS total FROM orders o JOIN users u ON o.user = u._id GROUP BY u.city HAVING SUM(o.amount) > 10 AND COUNT(*) >= 2 ORDER BY total DESC`)
+	if v, _ := pretty(res); v != `[{"city":"Paris","orders":3,"total":90}]` {
+		t.Log(v)
+		t.Fatal("fail Group")
+	}
+	res = run(`SELECT COUNT(status), MAX(amount) FROM orders`)
+	if v, _ := pretty(res); v != `[{"count_status":4,"max_amount":50}]` {
+		t.Log(v)
+		t.Fatal("fail Group all")
+	}
+
+	// Errors
+	for query, pos := range map[string]int{
+		"SELECT name FROM":                              16,
+		"SELECT name FROM users WHERE":                  28,
+		"SELECT name FROM users WHERE name = 'x":        36,
+		"SELECT name, COUNT(*) FROM users":              7,
+		"SELECT * FROM users GROUP BY city":             0,
+		"SELECT name FROM users WHERE name NOT = 'x'":   38,
+		"SELECT a.x FROM users u JOIN a ON u.id = b.id": 34,
+		"SELECT name FROM users LIMIT -1":               29,
+		"SELECT name FROM users WHERE $where = 'true'":  29,
+		`SELECT name FROM users WHERE "$where" = 'x'`:   29,
+		`SELECT "a.$function" FROM users`:               7,
+		"SELECT name AS `$x` FROM users":                15,
+		"SELECT \"a\x00b\" FROM users":                  7,
+	} {
+		_, err := mongoutils.ParseSQL(query)
+		var sErr *mongoutils.SQLError
+		if !errors.As(err, &sErr) || !errors.Is(err, mongoutils.ErrInvalidSQL) || sErr.Pos != pos {
+			t.Log(err)
+			t.Fatalf("fail Errors %s", query)
+		}
+	}
+}
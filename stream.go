@@ -0,0 +1,218 @@
+package mongoutils
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// DecodeError document decode error with document _id
+type DecodeError struct {
+	ID  any
+	Err error
+}
+
+// Error implement error interface
+func (me *DecodeError) Error() string {
+	return fmt.Sprintf("mongoutils: decode document %v: %v", me.ID, me.Err)
+}
+
+// Unwrap get decode error
+func (me *DecodeError) Unwrap() error {
+	return me.Err
+}
+
+// newDecodeError create decode error with _id of current cursor document
+func newDecodeError(cursor *mongo.Cursor, err error) error {
+	var id any
+	if v, e := cursor.Current.LookupErr("_id"); e == nil {
+		_ = v.Unmarshal(&id)
+	}
+	return &DecodeError{ID: id, Err: err}
+}
+
+// Stream call fn for each iterator document
+//
+// iterator closed on return, ctx used for cursor fetches so cancellation interrupt blocked fetch and stop iteration
+func Stream[T any](ctx context.Context, it *Iterator[T], fn func(v T) error) error {
+	defer it.Close()
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !it.next(ctx) {
+			break
+		}
+		if err := fn(it.Value()); err != nil {
+			return err
+		}
+	}
+	if err := it.Err(); err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+// StreamBatch call fn for each batch of iterator documents
+//
+// last batch may have less than size documents, iterator closed on return
+func StreamBatch[T any](ctx context.Context, it *Iterator[T], size int, fn func(batch []T) error) error {
+	if size <= 0 {
+		size = 1
+	}
+	batch := make([]T, 0, size)
+	err := Stream(ctx, it, func(v T) error {
+		batch = append(batch, v)
+		if len(batch) < size {
+			return nil
+		}
+		err := fn(batch)
+		batch = make([]T, 0, size)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if len(batch) > 0 {
+		return fn(batch)
+	}
+	return nil
+}
+
+// StreamChan stream iterator documents to channel
+//
+// both channels closed after iteration, error channel receive at most one error
+func StreamChan[T any](ctx context.Context, it *Iterator[T], buffer int) (<-chan T, <-chan error) {
+	values := make(chan T, buffer)
+	errs := make(chan error, 1)
+	go func() {
+		defer close(errs)
+		defer close(values)
+		err := Stream(ctx, it, func(v T) error {
+			select {
+			case values <- v:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		if err != nil {
+			errs <- err
+		}
+	}()
+	return values, errs
+}
+
+type streamJob[T any] struct {
+	seq   int
+	value T
+}
+
+type streamResult[R any] struct {
+	seq   int
+	value R
+	err   error
+}
+
+// Process process iterator documents with bounded concurrency workers and pass results to out
+//
+// in ordered mode results passed to out in iterator order. out called from caller goroutine.
+// first error cancel processing, iterator closed on return
+func Process[T any, R any](ctx context.Context, it *Iterator[T], workers int, ordered bool, fn func(ctx context.Context, v T) (R, error), out func(r R) error) error {
+	if workers <= 0 {
+		workers = 1
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	jobs := make(chan streamJob[T])
+	results := make(chan streamResult[R], workers)
+	window := make(chan struct{}, workers*2)
+	var produceErr error
+	produced := make(chan struct{})
+
+	// producer
+	go func() {
+		defer close(produced)
+		defer close(jobs)
+		seq := 0
+		produceErr = Stream(ctx, it, func(v T) error {
+			select {
+			case window <- struct{}{}:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			select {
+			case jobs <- streamJob[T]{seq: seq, value: v}:
+				seq++
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}()
+
+	// workers
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				r, err := fn(ctx, job.value)
+				select {
+				case results <- streamResult[R]{seq: job.seq, value: r, err: err}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// consumer
+	var err error
+	next := 0
+	pending := make(map[int]R)
+	for res := range results {
+		if err != nil {
+			continue
+		}
+		if res.err != nil {
+			err = res.err
+			cancel()
+			continue
+		}
+		if !ordered {
+			<-window
+			if err = out(res.value); err != nil {
+				cancel()
+			}
+			continue
+		}
+		pending[res.seq] = res.value
+		for {
+			v, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+			<-window
+			if err = out(v); err != nil {
+				cancel()
+				break
+			}
+		}
+	}
+	<-produced
+	if err != nil {
+		return err
+	}
+	return produceErr
+}
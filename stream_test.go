@@ -0,0 +1,119 @@
+package mongoutils_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bopher/mongoutils"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type streamNumber struct {
+	ID    int `bson:"_id"`
+	Value int `bson:"value"`
+}
+
+func streamIterator(t *testing.T, count int) *mongoutils.Iterator[streamNumber] {
+	docs := make([]any, count)
+	for i := range docs {
+		docs[i] = mongoutils.Map("_id", i, "value", i)
+	}
+	cursor, err := mongo.NewCursorFromDocuments(docs, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mongoutils.NewIterator[streamNumber](context.TODO(), cursor)
+}
+
+func TestStream(t *testing.T) {
+	ctx := context.TODO()
+
+	// Stream cancel
+	canceled, cancel := context.WithCancel(ctx)
+	calls := 0
+	err := mongoutils.Stream(canceled, streamIterator(t, 5), func(v streamNumber) error {
+		calls++
+		if calls == 2 {
+			cancel()
+		}
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) || calls != 2 {
+		t.Fatal("fail Stream cancel", err, calls)
+	}
+	calls = 0
+	if err := mongoutils.Stream(canceled, streamIterator(t, 5), func(v streamNumber) error { calls++; return nil }); !errors.Is(err, context.Canceled) || calls != 0 {
+		t.Fatal("fail Stream canceled")
+	}
+
+	// StreamBatch
+	sizes := make([]int, 0)
+	err = mongoutils.StreamBatch(ctx, streamIterator(t, 7), 3, func(batch []streamNumber) error {
+		sizes = append(sizes, len(batch))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sizes) != 3 || sizes[0] != 3 || sizes[2] != 1 {
+		t.Log(sizes)
+		t.Fatal("fail StreamBatch")
+	}
+
+	// StreamChan
+	values, errs := mongoutils.StreamChan(ctx, streamIterator(t, 5), 0)
+	sum := 0
+	for v := range values {
+		sum += v.Value
+	}
+	if err := <-errs; err != nil || sum != 10 {
+		t.Fatal("fail StreamChan", err)
+	}
+
+	// Process ordered
+	res := make([]int, 0)
+	err = mongoutils.Process(ctx, streamIterator(t, 50), 4, true, func(ctx context.Context, v streamNumber) (int, error) {
+		time.Sleep(time.Duration(50-v.Value) * 10 * time.Microsecond)
+		return v.Value * 2, nil
+	}, func(r int) error {
+		res = append(res, r)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range res {
+		if v != i*2 {
+			t.Log(res)
+			t.Fatal("fail Process ordered")
+		}
+	}
+	if len(res) != 50 {
+		t.Fatal("fail Process count")
+	}
+
+	// Process error
+	errFail := errors.New("fail")
+	err = mongoutils.Process(ctx, streamIterator(t, 50), 4, false, func(ctx context.Context, v streamNumber) (int, error) {
+		if v.Value == 10 {
+			return 0, errFail
+		}
+		return v.Value, nil
+	}, func(r int) error { return nil })
+	if !errors.Is(err, errFail) {
+		t.Fatal("fail Process error", err)
+	}
+
+	// DecodeError
+	cursor, err := mongo.NewCursorFromDocuments([]any{mongoutils.Map("_id", 1, "value", "invalid")}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = mongoutils.Stream(ctx, mongoutils.NewIterator[streamNumber](ctx, cursor), func(v streamNumber) error { return nil })
+	var decodeErr *mongoutils.DecodeError
+	if !errors.As(err, &decodeErr) || decodeErr.ID != int32(1) {
+		t.Fatal("fail DecodeError", err)
+	}
+}
@@ -0,0 +1,79 @@
+package mongoutils
+
+import (
+	"reflect"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	objectIDType   = reflect.TypeOf(primitive.ObjectID{})
+	dateTimeType   = reflect.TypeOf(primitive.DateTime(0))
+	decimalType    = reflect.TypeOf(primitive.Decimal128{})
+	regexType      = reflect.TypeOf(primitive.Regex{})
+	timestampType  = reflect.TypeOf(primitive.Timestamp{})
+	binaryType     = reflect.TypeOf(primitive.Binary{})
+	primitiveMType = reflect.TypeOf(primitive.M{})
+	primitiveDType = reflect.TypeOf(primitive.D{})
+)
+
+// bsonField parse bson tag of struct field
+//
+// returns field name in document, inline flag and skip flag for unexported or ignored fields
+func bsonField(f reflect.StructField) (name string, inline bool, skip bool) {
+	if f.PkgPath != "" && !f.Anonymous {
+		return "", false, true
+	}
+	tag, ok := f.Tag.Lookup("bson")
+	if !ok && f.Tag != "" && !strings.Contains(string(f.Tag), ":") {
+		tag = string(f.Tag)
+	}
+	if tag == "-" {
+		return "", false, true
+	}
+	parts := strings.Split(tag, ",")
+	name = parts[0]
+	for _, opt := range parts[1:] {
+		if opt == "inline" {
+			inline = true
+		}
+	}
+	if inline {
+		return "", true, false
+	}
+	if f.PkgPath != "" {
+		return "", false, true
+	}
+	if name == "" {
+		name = strings.ToLower(f.Name)
+	}
+	return name, false, false
+}
+
+// isLeafStruct check if struct type encoded as single bson value
+func isLeafStruct(t reflect.Type) bool {
+	switch t {
+	case timeType, decimalType, regexType, timestampType, binaryType:
+		return true
+	}
+	return false
+}
+
+// joinPath join document path parts with dot
+func joinPath(prefix string, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
+
+// indirectType get underlying type of pointer type
+func indirectType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
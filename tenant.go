@@ -0,0 +1,52 @@
+package mongoutils
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNoTenant context has no tenant and scope not bypassed
+var ErrNoTenant = errors.New("mongoutils: tenant missing from context")
+
+// ErrTenantMismatch document or update changes tenant field
+var ErrTenantMismatch = errors.New("mongoutils: tenant mismatch")
+
+// ErrTenantStage pipeline stage can not be scoped to tenant (e.g. $out, $merge)
+var ErrTenantStage = errors.New("mongoutils: stage not allowed in tenant scope")
+
+// TenantScope row level tenant scope options
+type TenantScope struct {
+	// Field tenant field path, "tenant_id" if empty
+	Field string
+	// Tenant resolve tenant value from context, TenantFrom used if nil
+	Tenant func(ctx context.Context) (any, bool)
+	// Shared collections without tenant field (e.g. countries), $lookup, $unionWith and $graphLookup on them not scoped
+	Shared []string
+	// OnBypass called for operations running with WithoutTenant context, standard logger used if nil
+	OnBypass func(ctx context.Context, collection string, operation string, reason string)
+}
+
+type tenantKey struct{}
+type tenantBypassKey struct{}
+
+// WithTenant get context with tenant value
+func WithTenant(ctx context.Context, tenant any) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFrom get tenant value from context
+func TenantFrom(ctx context.Context) (any, bool) {
+	v := ctx.Value(tenantKey{})
+	return v, v != nil
+}
+
+// WithoutTenant get context bypassing tenant scope, bypassed operations reported to TenantScope.OnBypass with reason
+func WithoutTenant(ctx context.Context, reason string) context.Context {
+	return context.WithValue(ctx, tenantBypassKey{}, reason)
+}
+
+// TenantBypassed check if context bypass tenant scope and get bypass reason
+func TenantBypassed(ctx context.Context) (string, bool) {
+	reason, ok := ctx.Value(tenantBypassKey{}).(string)
+	return reason, ok
+}
@@ -0,0 +1,140 @@
+package mongoutils_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/bopher/mongoutils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestTenantCollection(t *testing.T) {
+	db := mongoutils.NewMemoryDatabase()
+	bypassed := make([]string, 0)
+	scope := mongoutils.TenantScope{
+		Field: "tenant",
+		OnBypass: func(ctx context.Context, collection string, operation string, reason string) {
+			bypassed = append(bypassed, collection+":"+operation+":"+reason)
+		},
+	}
+	users := mongoutils.NewTenantCollection(db.Collection("users"), scope)
+	orders := mongoutils.NewTenantCollection(db.Collection("orders"), scope)
+	a := mongoutils.WithTenant(context.TODO(), "a")
+	b := mongoutils.WithTenant(context.TODO(), "b")
+
+	// Insert
+	if _, err := users.InsertOne(context.TODO(), mongoutils.Map("_id", 1)); !errors.Is(err, mongoutils.ErrNoTenant) {
+		t.Fatal("fail Insert without tenant")
+	}
+	if _, err := users.InsertOne(a, mongoutils.Map("_id", 1, "tenant", "b")); !errors.Is(err, mongoutils.ErrTenantMismatch) {
+		t.Fatal("fail Insert mismatch")
+	}
+	users.InsertOne(a, mongoutils.Map("_id", 1, "name", "John"))
+	users.InsertOne(b, mongoutils.Map("_id", 2, "name", "Jack"))
+	orders.InsertMany(a, []any{mongoutils.Map("_id", 1, "user", 1), mongoutils.Map("_id", 2, "user", 2)})
+	// foreign tenant document referencing tenant a user
+	db.Collection("orders").InsertOne(context.TODO(), mongoutils.Map("_id", 3, "user", 1, "tenant", "b"))
+	if n, _ := db.Collection("users").CountDocuments(context.TODO(), mongoutils.Map("tenant", "a")); n != 1 {
+		t.Fatal("fail Insert stamp")
+	}
+
+	// Filter
+	if n, _ := users.CountDocuments(a, nil); n != 1 {
+		t.Fatal("fail Count")
+	}
+	if err := users.FindOne(a, mongoutils.Map("_id", 2)).Err(); err == nil {
+		t.Fatal("fail FindOne other tenant")
+	}
+	res, err := users.DeleteMany(b, mongoutils.Map("_id", 1))
+	if err != nil || res.DeletedCount != 0 {
+		t.Fatal("fail Delete other tenant")
+	}
+	if _, err := users.UpdateOne(a, mongoutils.Map("_id", 1), mongoutils.Set(mongoutils.Map("tenant", "b"))); !errors.Is(err, mongoutils.ErrTenantMismatch) {
+		t.Fatal("fail Update tenant field")
+	}
+	users.UpdateOne(a, mongoutils.Map("_id", 3), mongoutils.Set(mongoutils.Map("name", "Jane")), mongoutils.UpdateOption(mongoutils.OptUpsert()))
+	if n, _ := db.Collection("users").CountDocuments(context.TODO(), mongoutils.Map("_id", 3, "tenant", "a")); n != 1 {
+		t.Fatal("fail Upsert stamp")
+	}
+
+	// Pipeline
+	pipe := mongoutils.NewPipe().
+		Match(mongoutils.Map("_id", mongoutils.Map("$lte", 2))).
+		LoadRelation("orders", "_id", "user", "order").
+		Sort(mongoutils.Doc("_id", 1))
+	type userOrder struct {
+		ID    int `bson:"_id"`
+		Order *struct {
+			ID int `bson:"_id"`
+		} `bson:"order"`
+	}
+	docs, err := mongoutils.Aggregate[userOrder](mongoutils.WithTenant(context.TODO(), "b"), users, pipe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 1 || docs[0].ID != 2 || docs[0].Order != nil {
+		t.Log(docs)
+		t.Fatal("fail Pipeline")
+	}
+	docs, _ = mongoutils.Aggregate[userOrder](a, users, pipe)
+	if len(docs) != 1 || docs[0].Order == nil || docs[0].Order.ID != 1 {
+		t.Log(docs)
+		t.Fatal("fail Pipeline lookup")
+	}
+
+	// Shared
+	db.Collection("countries").InsertOne(context.TODO(), mongoutils.Map("_id", "fr", "name", "France"))
+	users.UpdateOne(a, mongoutils.Map("_id", 1), mongoutils.Set(mongoutils.Map("country", "fr")))
+	countryPipe := mongoutils.NewPipe().Match(mongoutils.Map("_id", 1)).LoadRelation("countries", "country", "_id", "country")
+	type userCountry struct {
+		Country *struct {
+			Name string `bson:"name"`
+		} `bson:"country"`
+	}
+	if docs, _ := mongoutils.Aggregate[userCountry](a, users, countryPipe); len(docs) != 1 || docs[0].Country != nil {
+		t.Fatal("fail Shared default scoped")
+	}
+	scope.Shared = []string{"countries"}
+	sharedUsers := mongoutils.NewTenantCollection(db.Collection("users"), scope)
+	if docs, _ := mongoutils.Aggregate[userCountry](a, sharedUsers, countryPipe); len(docs) != 1 || docs[0].Country == nil || docs[0].Country.Name != "France" {
+		t.Log(docs)
+		t.Fatal("fail Shared")
+	}
+
+	// Stages
+	for _, stage := range []string{"$out", "$merge", "$collStats"} {
+		if _, err := users.Aggregate(a, mongoutils.Array(mongoutils.Map(stage, "archive"))); !errors.Is(err, mongoutils.ErrTenantStage) {
+			t.Fatal("fail unscoped stage "+stage, err)
+		}
+	}
+	recorder := &pipelineRecorder{Collection: db.Collection("users")}
+	recorded := mongoutils.NewTenantCollection(recorder, scope)
+	for stage, match := range map[string]string{"$search": "tenant", "$changeStream": "fullDocument.tenant"} {
+		recorded.Aggregate(a, mongoutils.Array(mongoutils.Map(stage, mongoutils.Map()), mongoutils.Map("$limit", 1)))
+		if v, _ := pretty(recorder.pipeline); v != `[[{"Key":"`+stage+`","Value":[]}],[{"Key":"$match","Value":[{"Key":"`+match+`","Value":"a"}]}],[{"Key":"$limit","Value":1}]]` {
+			t.Log(v)
+			t.Fatal("fail first stage " + stage)
+		}
+	}
+
+	// Bypass
+	all, err := users.CountDocuments(mongoutils.WithoutTenant(context.TODO(), "admin report"), primitive.D{})
+	if err != nil || all != 3 || len(bypassed) != 1 || bypassed[0] != "users:count:admin report" {
+		t.Log(bypassed)
+		t.Fatal("fail Bypass")
+	}
+}
+
+// pipelineRecorder record aggregation pipeline passed to collection
+type pipelineRecorder struct {
+	mongoutils.Collection
+	pipeline any
+}
+
+func (me *pipelineRecorder) Aggregate(ctx context.Context, pipeline any, opts ...*options.AggregateOptions) (*mongo.Cursor, error) {
+	me.pipeline = pipeline
+	return nil, errors.New("recorded")
+}
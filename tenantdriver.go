@@ -0,0 +1,446 @@
+package mongoutils
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type tenantCollection struct {
+	coll  Collection
+	scope TenantScope
+}
+
+// tenant get tenant match doc, nil match for bypassed context
+func (me *tenantCollection) tenant(ctx context.Context, operation string) (primitive.D, error) {
+	if reason, ok := TenantBypassed(ctx); ok {
+		if me.scope.OnBypass != nil {
+			me.scope.OnBypass(ctx, me.coll.Name(), operation, reason)
+		} else {
+			log.Printf("mongoutils: tenant scope bypassed for %s on %s: %s", operation, me.coll.Name(), reason)
+		}
+		return nil, nil
+	}
+	resolve := me.scope.Tenant
+	if resolve == nil {
+		resolve = TenantFrom
+	}
+	tenant, ok := resolve(ctx)
+	if !ok {
+		return nil, ErrNoTenant
+	}
+	v, err := toValue(tenant)
+	if err != nil {
+		return nil, err
+	}
+	return primitive.D{{Key: me.field(), Value: v}}, nil
+}
+
+// shared get shared collections set
+func (me *tenantCollection) shared() map[string]bool {
+	res := make(map[string]bool, len(me.scope.Shared))
+	for _, name := range me.scope.Shared {
+		res[name] = true
+	}
+	return res
+}
+
+func (me *tenantCollection) field() string {
+	if me.scope.Field == "" {
+		return "tenant_id"
+	}
+	return me.scope.Field
+}
+
+func (me *tenantCollection) filter(ctx context.Context, operation string, filter any) (any, error) {
+	match, err := me.tenant(ctx, operation)
+	if err != nil || match == nil {
+		return filter, err
+	}
+	return scopeFilter(filter, match)
+}
+
+// stamp set tenant field of document, returns ErrTenantMismatch if document has other tenant
+func (me *tenantCollection) stamp(match primitive.D, document any) (any, error) {
+	if match == nil {
+		return document, nil
+	}
+	doc, err := toDocument(document)
+	if err != nil {
+		return nil, err
+	}
+	parts := strings.Split(match[0].Key, ".")
+	if v, ok := getValue(doc, parts); ok && v != nil && compareValues(v, match[0].Value) != 0 {
+		return nil, fmt.Errorf("%w: document %s is %v", ErrTenantMismatch, match[0].Key, v)
+	}
+	return setValue(doc, parts, match[0].Value)
+}
+
+// guard reject update operators changing tenant field, pipeline updates end with tenant $set
+func (me *tenantCollection) guard(match primitive.D, update any) (any, error) {
+	if match == nil {
+		return update, nil
+	}
+	v, err := toValue(update)
+	if err != nil {
+		return nil, err
+	}
+	switch val := v.(type) {
+	case primitive.A:
+		return append(val, primitive.D{{Key: "$set", Value: match}}), nil
+	case primitive.D:
+		field := match[0].Key
+		for _, op := range val {
+			fields, _ := op.Value.(primitive.D)
+			for _, f := range fields {
+				targets := []string{f.Key}
+				if op.Key == "$rename" {
+					if to, ok := f.Value.(string); ok {
+						targets = append(targets, to)
+					}
+				}
+				for _, t := range targets {
+					if t == field || strings.HasPrefix(t, field+".") || strings.HasPrefix(field, t+".") {
+						return nil, fmt.Errorf("%w: update can not change %s", ErrTenantMismatch, field)
+					}
+				}
+			}
+		}
+		return val, nil
+	}
+	return update, nil
+}
+
+func (me *tenantCollection) Name() string {
+	return me.coll.Name()
+}
+
+func (me *tenantCollection) InsertOne(ctx context.Context, document any, opts ...*options.InsertOneOptions) (*mongo.InsertOneResult, error) {
+	match, err := me.tenant(ctx, "insert")
+	if err != nil {
+		return nil, err
+	}
+	if document, err = me.stamp(match, document); err != nil {
+		return nil, err
+	}
+	return me.coll.InsertOne(ctx, document, opts...)
+}
+
+func (me *tenantCollection) InsertMany(ctx context.Context, documents []any, opts ...*options.InsertManyOptions) (*mongo.InsertManyResult, error) {
+	match, err := me.tenant(ctx, "insert")
+	if err != nil {
+		return nil, err
+	}
+	stamped := make([]any, len(documents))
+	for i, doc := range documents {
+		if stamped[i], err = me.stamp(match, doc); err != nil {
+			return nil, err
+		}
+	}
+	return me.coll.InsertMany(ctx, stamped, opts...)
+}
+
+func (me *tenantCollection) Find(ctx context.Context, filter any, opts ...*options.FindOptions) (*mongo.Cursor, error) {
+	filter, err := me.filter(ctx, "find", filter)
+	if err != nil {
+		return nil, err
+	}
+	return me.coll.Find(ctx, filter, opts...)
+}
+
+func (me *tenantCollection) FindOne(ctx context.Context, filter any, opts ...*options.FindOneOptions) *mongo.SingleResult {
+	filter, err := me.filter(ctx, "find", filter)
+	if err != nil {
+		return mongo.NewSingleResultFromDocument(primitive.D{}, err, nil)
+	}
+	return me.coll.FindOne(ctx, filter, opts...)
+}
+
+func (me *tenantCollection) update(ctx context.Context, filter any, update any, many bool, opts []*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	match, err := me.tenant(ctx, "update")
+	if err != nil {
+		return nil, err
+	}
+	if match != nil {
+		if filter, err = scopeFilter(filter, match); err != nil {
+			return nil, err
+		}
+		if update, err = me.guard(match, update); err != nil {
+			return nil, err
+		}
+	}
+	if many {
+		return me.coll.UpdateMany(ctx, filter, update, opts...)
+	}
+	return me.coll.UpdateOne(ctx, filter, update, opts...)
+}
+
+func (me *tenantCollection) UpdateOne(ctx context.Context, filter any, update any, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	return me.update(ctx, filter, update, false, opts)
+}
+
+func (me *tenantCollection) UpdateMany(ctx context.Context, filter any, update any, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	return me.update(ctx, filter, update, true, opts)
+}
+
+func (me *tenantCollection) ReplaceOne(ctx context.Context, filter any, replacement any, opts ...*options.ReplaceOptions) (*mongo.UpdateResult, error) {
+	match, err := me.tenant(ctx, "replace")
+	if err != nil {
+		return nil, err
+	}
+	if match != nil {
+		if filter, err = scopeFilter(filter, match); err != nil {
+			return nil, err
+		}
+		if replacement, err = me.stamp(match, replacement); err != nil {
+			return nil, err
+		}
+	}
+	return me.coll.ReplaceOne(ctx, filter, replacement, opts...)
+}
+
+func (me *tenantCollection) DeleteOne(ctx context.Context, filter any, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error) {
+	filter, err := me.filter(ctx, "delete", filter)
+	if err != nil {
+		return nil, err
+	}
+	return me.coll.DeleteOne(ctx, filter, opts...)
+}
+
+func (me *tenantCollection) DeleteMany(ctx context.Context, filter any, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error) {
+	filter, err := me.filter(ctx, "delete", filter)
+	if err != nil {
+		return nil, err
+	}
+	return me.coll.DeleteMany(ctx, filter, opts...)
+}
+
+func (me *tenantCollection) CountDocuments(ctx context.Context, filter any, opts ...*options.CountOptions) (int64, error) {
+	filter, err := me.filter(ctx, "count", filter)
+	if err != nil {
+		return 0, err
+	}
+	return me.coll.CountDocuments(ctx, filter, opts...)
+}
+
+func (me *tenantCollection) Aggregate(ctx context.Context, pipeline any, opts ...*options.AggregateOptions) (*mongo.Cursor, error) {
+	match, err := me.tenant(ctx, "aggregate")
+	if err != nil {
+		return nil, err
+	}
+	if match != nil {
+		if pipeline, err = scopePipeline(pipeline, match, me.shared()); err != nil {
+			return nil, err
+		}
+	}
+	return me.coll.Aggregate(ctx, pipeline, opts...)
+}
+
+func (me *tenantCollection) Distinct(ctx context.Context, fieldName string, filter any, opts ...*options.DistinctOptions) ([]any, error) {
+	filter, err := me.filter(ctx, "distinct", filter)
+	if err != nil {
+		return nil, err
+	}
+	return me.coll.Distinct(ctx, fieldName, filter, opts...)
+}
+
+// scopeFilter combine filter with tenant match using $and
+func scopeFilter(filter any, match primitive.D) (primitive.D, error) {
+	doc, err := toDocument(filter)
+	if err != nil {
+		return nil, err
+	}
+	if len(doc) == 0 {
+		return match, nil
+	}
+	return primitive.D{{Key: "$and", Value: primitive.A{doc, match}}}, nil
+}
+
+// firstStages stages must be first in pipeline, tenant $match added after them
+var firstStages = map[string]bool{"$search": true, "$vectorSearch": true, "$changeStream": true}
+
+// unscopedStages stages write to other collection or return data not scoped by document match
+var unscopedStages = map[string]bool{
+	"$out": true, "$merge": true, "$searchMeta": true, "$collStats": true,
+	"$indexStats": true, "$planCacheStats": true, "$listSearchIndexes": true,
+}
+
+// scopePipeline merge tenant match into leading $match (or $geoNear query) and scope nested collection stages
+//
+// tenant $match added after first stage for stages must be first, change events matched by fullDocument fields
+func scopePipeline(pipeline any, match primitive.D, shared map[string]bool) (mongo.Pipeline, error) {
+	stages, err := toPipeline(pipeline)
+	if err != nil {
+		return nil, err
+	}
+	if err := scopeStages(stages, match, shared); err != nil {
+		return nil, err
+	}
+
+	if len(stages) > 0 {
+		switch stages[0][0].Key {
+		case "$match":
+			filter, err := scopeFilter(stages[0][0].Value, match)
+			if err != nil {
+				return nil, err
+			}
+			stages[0] = primitive.D{{Key: "$match", Value: filter}}
+			return stages, nil
+		case "$geoNear":
+			if opts, ok := stages[0][0].Value.(primitive.D); ok {
+				opts, err := scopeOption(opts, "query", match, func(v any) (any, error) {
+					return scopeFilter(v, match)
+				})
+				if err != nil {
+					return nil, err
+				}
+				stages[0] = primitive.D{{Key: "$geoNear", Value: opts}}
+				return stages, nil
+			}
+		case "$changeStream":
+			events := make(primitive.D, len(match))
+			for i, e := range match {
+				events[i] = primitive.E{Key: "fullDocument." + e.Key, Value: e.Value}
+			}
+			match = events
+		}
+		if firstStages[stages[0][0].Key] {
+			res := append(mongo.Pipeline{stages[0], {{Key: "$match", Value: match}}}, stages[1:]...)
+			return res, nil
+		}
+	}
+	return append(mongo.Pipeline{{{Key: "$match", Value: match}}}, stages...), nil
+}
+
+// scopeStages scope $lookup, $unionWith and $graphLookup foreign collections, including stages nested in $facet
+//
+// shared collections not scoped, only stages nested in their sub-pipeline
+func scopeStages(stages mongo.Pipeline, match primitive.D, shared map[string]bool) error {
+	for i, stage := range stages {
+		name := stage[0].Key
+		if unscopedStages[name] {
+			return fmt.Errorf("%w: %s", ErrTenantStage, name)
+		}
+		opts, ok := stage[0].Value.(primitive.D)
+		if coll, isName := stage[0].Value.(string); isName && name == "$unionWith" {
+			opts, ok = primitive.D{{Key: "coll", Value: coll}}, true
+		}
+		if !ok {
+			continue
+		}
+		var err error
+		switch name {
+		case "$lookup", "$unionWith":
+			if shared[foreignCollection(name, opts)] {
+				opts, err = scopeSharedPipeline(opts, match, shared)
+				break
+			}
+			opts, err = scopeOption(opts, "pipeline", match, func(v any) (any, error) {
+				return scopePipeline(v, match, shared)
+			})
+		case "$graphLookup":
+			if shared[foreignCollection(name, opts)] {
+				continue
+			}
+			opts, err = scopeOption(opts, "restrictSearchWithMatch", match, func(v any) (any, error) {
+				return scopeFilter(v, match)
+			})
+		case "$facet":
+			for j, facet := range opts {
+				sub, e := toPipeline(facet.Value)
+				if e == nil {
+					e = scopeStages(sub, match, shared)
+				}
+				if e != nil {
+					return e
+				}
+				opts[j].Value = sub
+			}
+		default:
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		stages[i] = primitive.D{{Key: name, Value: opts}}
+	}
+	return nil
+}
+
+// foreignCollection get collection name of $lookup, $graphLookup or $unionWith stage options
+func foreignCollection(stage string, opts primitive.D) string {
+	key := "from"
+	if stage == "$unionWith" {
+		key = "coll"
+	}
+	for _, e := range opts {
+		if e.Key == key {
+			name, _ := e.Value.(string)
+			return name
+		}
+	}
+	return ""
+}
+
+// scopeSharedPipeline scope stages nested in shared collection sub-pipeline without tenant match
+func scopeSharedPipeline(opts primitive.D, match primitive.D, shared map[string]bool) (primitive.D, error) {
+	for i, e := range opts {
+		if e.Key != "pipeline" {
+			continue
+		}
+		sub, err := toPipeline(e.Value)
+		if err == nil {
+			err = scopeStages(sub, match, shared)
+		}
+		if err != nil {
+			return nil, err
+		}
+		opts[i].Value = sub
+	}
+	return opts, nil
+}
+
+// scopeOption scope stage option using scope function, option added with tenant match if missing
+func scopeOption(opts primitive.D, key string, match primitive.D, scope func(v any) (any, error)) (primitive.D, error) {
+	for i, e := range opts {
+		if e.Key == key {
+			v, err := scope(e.Value)
+			if err != nil {
+				return nil, err
+			}
+			opts[i].Value = v
+			return opts, nil
+		}
+	}
+	v, err := scope(nil)
+	if err != nil {
+		return nil, err
+	}
+	return append(opts, primitive.E{Key: key, Value: v}), nil
+}
+
+// toPipeline convert pipeline value to stages
+func toPipeline(pipeline any) (mongo.Pipeline, error) {
+	v, err := toValue(pipeline)
+	if err != nil {
+		return nil, err
+	}
+	items, ok := v.(primitive.A)
+	if !ok && v != nil {
+		return nil, fmt.Errorf("%w: pipeline must be an array", ErrInvalidQuery)
+	}
+	stages := make(mongo.Pipeline, 0, len(items)+1)
+	for _, item := range items {
+		stage, ok := item.(primitive.D)
+		if !ok || len(stage) != 1 {
+			return nil, fmt.Errorf("%w: stage must be a document with a single field", ErrInvalidQuery)
+		}
+		stages = append(stages, stage)
+	}
+	return stages, nil
+}
@@ -0,0 +1,162 @@
+package mongoutils
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// TxRetry transaction retry policy
+type TxRetry struct {
+	// MaxAttempts max transaction attempts (unlimited if <= 0)
+	MaxAttempts int
+	// Timeout total retry time budget (unlimited if <= 0)
+	Timeout time.Duration
+	// Backoff initial wait between attempts, doubled after each attempt
+	Backoff time.Duration
+	// MaxBackoff max wait between attempts
+	MaxBackoff time.Duration
+}
+
+// DefaultTxRetry default retry policy used by RunInTx
+var DefaultTxRetry = TxRetry{
+	MaxAttempts: 5,
+	Timeout:     30 * time.Second,
+	Backoff:     50 * time.Millisecond,
+	MaxBackoff:  time.Second,
+}
+
+// RunInTx run fn inside transaction with DefaultTxRetry policy
+//
+// see RunInTxRetry
+func RunInTx(ctx context.Context, client *mongo.Client, fn func(sc mongo.SessionContext) error, opts ...*options.TransactionOptions) (int, error) {
+	return RunInTxRetry(ctx, client, DefaultTxRetry, fn, opts...)
+}
+
+// RunInTxRetry run fn inside transaction and returns attempts count
+//
+// TxOption used if no option passed. transaction retried on TransientTransactionError
+// and commit retried on UnknownTransactionCommitResult error labels.
+// if ctx has running transaction, fn called with existing session and outer transaction handle retries
+func RunInTxRetry(ctx context.Context, client *mongo.Client, retry TxRetry, fn func(sc mongo.SessionContext) error, opts ...*options.TransactionOptions) (int, error) {
+	if sess := mongo.SessionFromContext(ctx); sess != nil && txRunning(sess) {
+		return 1, fn(mongo.NewSessionContext(ctx, sess))
+	}
+	if len(opts) == 0 {
+		opts = []*options.TransactionOptions{TxOption()}
+	}
+
+	sess := mongo.SessionFromContext(ctx)
+	if sess == nil {
+		var err error
+		if sess, err = client.StartSession(); err != nil {
+			return 0, err
+		}
+		defer sess.EndSession(context.Background())
+	}
+	sc := mongo.NewSessionContext(ctx, sess)
+
+	start := time.Now()
+	canRetry := func(attempt int) bool {
+		if ctx.Err() != nil {
+			return false
+		}
+		if retry.MaxAttempts > 0 && attempt >= retry.MaxAttempts {
+			return false
+		}
+		return retry.Timeout <= 0 || time.Since(start) < retry.Timeout
+	}
+
+	attempt := 0
+	for {
+		attempt++
+		if err := sess.StartTransaction(opts...); err != nil {
+			return attempt, err
+		}
+		if err := fn(sc); err != nil {
+			if txRunning(sess) {
+				abortCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+				_ = sess.AbortTransaction(abortCtx)
+				cancel()
+			}
+			if hasErrorLabel(err, "TransientTransactionError") && canRetry(attempt) {
+				if err := txBackoff(ctx, retry, attempt); err != nil {
+					return attempt, err
+				}
+				continue
+			}
+			return attempt, err
+		}
+		if !txRunning(sess) {
+			// transaction aborted by fn
+			return attempt, nil
+		}
+
+		commits := 0
+		for {
+			commits++
+			err := sess.CommitTransaction(ctx)
+			if err == nil {
+				return attempt, nil
+			}
+			if hasErrorLabel(err, "UnknownTransactionCommitResult") && canRetry(commits) {
+				if err := txBackoff(ctx, retry, commits); err != nil {
+					return attempt, err
+				}
+				continue
+			}
+			if hasErrorLabel(err, "TransientTransactionError") && canRetry(attempt) {
+				break
+			}
+			return attempt, err
+		}
+		if err := txBackoff(ctx, retry, attempt); err != nil {
+			return attempt, err
+		}
+	}
+}
+
+// txRunning check if session has running transaction
+func txRunning(sess mongo.Session) bool {
+	xs, ok := sess.(mongo.XSession)
+	return ok && xs.ClientSession() != nil && xs.ClientSession().TransactionRunning()
+}
+
+// hasErrorLabel check if error or wrapped errors has label
+func hasErrorLabel(err error, label string) bool {
+	var labeled interface{ HasErrorLabel(string) bool }
+	return errors.As(err, &labeled) && labeled.HasErrorLabel(label)
+}
+
+// txBackoff wait before next attempt
+func txBackoff(ctx context.Context, retry TxRetry, attempt int) error {
+	if retry.Backoff <= 0 {
+		return ctx.Err()
+	}
+	shift := attempt - 1
+	if shift < 0 {
+		shift = 0
+	}
+	wait := retry.Backoff << shift
+	if shift >= 63 || wait>>shift != retry.Backoff {
+		// shift overflow
+		wait = math.MaxInt64
+	}
+	if retry.MaxBackoff > 0 && wait > retry.MaxBackoff {
+		wait = retry.MaxBackoff
+	}
+	wait = wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1))
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
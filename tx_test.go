@@ -0,0 +1,91 @@
+package mongoutils_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/bopher/mongoutils"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestRunInTx(t *testing.T) {
+	ctx := context.TODO()
+	// transactions started lazily and aborted client side, no server needed
+	client, err := mongo.NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Connect(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer client.Disconnect(ctx)
+
+	transient := mongo.CommandError{Message: "conflict", Labels: []string{"TransientTransactionError"}}
+	retry := mongoutils.TxRetry{MaxAttempts: 3, Backoff: 20 * time.Millisecond, MaxBackoff: 20 * time.Millisecond}
+
+	// Retry on label
+	start := time.Now()
+	attempts, err := mongoutils.RunInTxRetry(ctx, client, retry, func(sc mongo.SessionContext) error {
+		return fmt.Errorf("wrapped: %w", transient)
+	})
+	elapsed := time.Since(start)
+	if attempts != 3 || !errors.As(err, &mongo.CommandError{}) {
+		t.Fatal("fail retry labeled", attempts, err)
+	}
+	// two backoffs with jitter between half and full MaxBackoff
+	if elapsed < 20*time.Millisecond || elapsed > time.Second {
+		t.Fatal("fail backoff bounds", elapsed)
+	}
+
+	// No retry without label
+	attempts, err = mongoutils.RunInTxRetry(ctx, client, retry, func(sc mongo.SessionContext) error {
+		return errors.New("fatal")
+	})
+	if attempts != 1 || err == nil {
+		t.Fatal("fail no retry", attempts)
+	}
+
+	// Timeout budget and unbounded backoff
+	attempts, _ = mongoutils.RunInTxRetry(ctx, client, mongoutils.TxRetry{Timeout: 30 * time.Millisecond, Backoff: time.Millisecond}, func(sc mongo.SessionContext) error {
+		return transient
+	})
+	if attempts < 2 || attempts > 20 {
+		t.Fatal("fail timeout budget", attempts)
+	}
+
+	// Canceled during backoff
+	timeout, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	attempts, err = mongoutils.RunInTxRetry(timeout, client, mongoutils.TxRetry{Backoff: time.Hour}, func(sc mongo.SessionContext) error {
+		return transient
+	})
+	if attempts != 1 || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatal("fail cancel backoff", attempts, err)
+	}
+
+	// Nested transaction reuse outer session
+	sess, err := client.StartSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.EndSession(ctx)
+	if err := sess.StartTransaction(); err != nil {
+		t.Fatal(err)
+	}
+	outer := mongo.NewSessionContext(ctx, sess)
+	attempts, err = mongoutils.RunInTx(outer, client, func(sc mongo.SessionContext) error {
+		if sc.ID().String() != sess.ID().String() {
+			return errors.New("new session")
+		}
+		return transient
+	})
+	if attempts != 1 || !errors.As(err, &mongo.CommandError{}) {
+		t.Fatal("fail nested reuse", attempts, err)
+	}
+	if err := sess.AbortTransaction(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,154 @@
+package mongoutils
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+)
+
+// TxOptionFunc transaction and session option modifier
+type TxOptionFunc func(c *txConfig)
+
+type txConfig struct {
+	readPref      *readpref.ReadPref
+	readConcern   *readconcern.ReadConcern
+	w             any
+	wTimeout      time.Duration
+	journal       *bool
+	maxCommitTime *time.Duration
+	causal        bool
+}
+
+// newTxConfig create config with majority write and snapshot read
+func newTxConfig(opts []TxOptionFunc) *txConfig {
+	c := &txConfig{
+		readConcern: readconcern.Snapshot(),
+		w:           "majority",
+		causal:      true,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+func (me *txConfig) writeConcern() *writeconcern.WriteConcern {
+	opts := make([]writeconcern.Option, 0)
+	if w, ok := me.w.(int); ok {
+		opts = append(opts, writeconcern.W(w))
+	} else {
+		opts = append(opts, writeconcern.WMajority())
+	}
+	if me.wTimeout > 0 {
+		opts = append(opts, writeconcern.WTimeout(me.wTimeout))
+	}
+	if me.journal != nil {
+		opts = append(opts, writeconcern.J(*me.journal))
+	}
+	return writeconcern.New(opts...)
+}
+
+// TxReadPreference set read preference
+func TxReadPreference(rp *readpref.ReadPref) TxOptionFunc {
+	return func(c *txConfig) {
+		c.readPref = rp
+	}
+}
+
+// TxPrimary set primary read preference
+func TxPrimary() TxOptionFunc {
+	return TxReadPreference(readpref.Primary())
+}
+
+// TxNearest set nearest read preference
+func TxNearest() TxOptionFunc {
+	return TxReadPreference(readpref.Nearest())
+}
+
+// TxReadConcern set read concern
+func TxReadConcern(rc *readconcern.ReadConcern) TxOptionFunc {
+	return func(c *txConfig) {
+		c.readConcern = rc
+	}
+}
+
+// TxLocalRead set local read concern
+func TxLocalRead() TxOptionFunc {
+	return TxReadConcern(readconcern.Local())
+}
+
+// TxMajorityRead set majority read concern
+func TxMajorityRead() TxOptionFunc {
+	return TxReadConcern(readconcern.Majority())
+}
+
+// TxSnapshotRead set snapshot read concern (default)
+func TxSnapshotRead() TxOptionFunc {
+	return TxReadConcern(readconcern.Snapshot())
+}
+
+// TxW set write concern w option
+//
+// e.g TxW(1) for low value writes
+func TxW(w int) TxOptionFunc {
+	return func(c *txConfig) {
+		c.w = w
+	}
+}
+
+// TxMajorityWrite set majority write concern (default)
+func TxMajorityWrite() TxOptionFunc {
+	return func(c *txConfig) {
+		c.w = "majority"
+	}
+}
+
+// TxWTimeout set write concern wtimeout option
+func TxWTimeout(d time.Duration) TxOptionFunc {
+	return func(c *txConfig) {
+		c.wTimeout = d
+	}
+}
+
+// TxJournal set write concern j option
+func TxJournal(j bool) TxOptionFunc {
+	return func(c *txConfig) {
+		c.journal = &j
+	}
+}
+
+// TxMaxCommitTime set maxCommitTimeMS option
+func TxMaxCommitTime(d time.Duration) TxOptionFunc {
+	return func(c *txConfig) {
+		c.maxCommitTime = &d
+	}
+}
+
+// TxCausalConsistency set session causal consistency (default true)
+//
+// only used by SessionOption
+func TxCausalConsistency(enabled bool) TxOptionFunc {
+	return func(c *txConfig) {
+		c.causal = enabled
+	}
+}
+
+// SessionOption generate session option with causal consistency and
+// default transaction options same as TxOption
+func SessionOption(opts ...TxOptionFunc) *options.SessionOptions {
+	c := newTxConfig(opts)
+	res := options.Session().
+		SetCausalConsistency(c.causal).
+		SetDefaultWriteConcern(c.writeConcern()).
+		SetDefaultReadConcern(c.readConcern)
+	if c.readPref != nil {
+		res.SetDefaultReadPreference(c.readPref)
+	}
+	if c.maxCommitTime != nil {
+		res.SetDefaultMaxCommitTime(c.maxCommitTime)
+	}
+	return res
+}
@@ -0,0 +1,44 @@
+package mongoutils_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bopher/mongoutils"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+func TestTxOption(t *testing.T) {
+	// Default
+	opt := mongoutils.TxOption()
+	if opt.WriteConcern.GetW() != "majority" || opt.ReadConcern.GetLevel() != "snapshot" || opt.ReadPreference != nil {
+		t.Fatal("fail TxOption default")
+	}
+
+	// Custom
+	opt = mongoutils.TxOption(
+		mongoutils.TxW(1),
+		mongoutils.TxWTimeout(time.Second),
+		mongoutils.TxLocalRead(),
+		mongoutils.TxNearest(),
+		mongoutils.TxMaxCommitTime(2*time.Second),
+	)
+	if opt.WriteConcern.GetW() != 1 || opt.WriteConcern.GetWTimeout() != time.Second {
+		t.Fatal("fail TxOption write concern")
+	}
+	if opt.ReadConcern.GetLevel() != "local" || opt.ReadPreference.Mode() != readpref.NearestMode {
+		t.Fatal("fail TxOption read")
+	}
+	if opt.MaxCommitTime == nil || *opt.MaxCommitTime != 2*time.Second {
+		t.Fatal("fail TxOption max commit time")
+	}
+
+	// Session
+	sess := mongoutils.SessionOption(mongoutils.TxCausalConsistency(false), mongoutils.TxMajorityRead())
+	if sess.CausalConsistency == nil || *sess.CausalConsistency || sess.DefaultReadConcern.GetLevel() != "majority" {
+		t.Fatal("fail SessionOption")
+	}
+	if sess.DefaultWriteConcern.GetW() != "majority" {
+		t.Fatal("fail SessionOption write concern")
+	}
+}
@@ -0,0 +1,195 @@
+package mongoutils
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type notFoundError struct{}
+
+func (notFoundError) Error() string {
+	return "mongoutils: document not found"
+}
+
+func (notFoundError) Unwrap() error {
+	return mongo.ErrNoDocuments
+}
+
+// ErrNotFound document not found error, wraps mongo.ErrNoDocuments
+var ErrNotFound error = notFoundError{}
+
+// Iterator typed cursor iterator
+type Iterator[T any] struct {
+	ctx    context.Context
+	cursor *mongo.Cursor
+	value  T
+	err    error
+}
+
+// NewIterator create typed iterator from cursor
+func NewIterator[T any](ctx context.Context, cursor *mongo.Cursor) *Iterator[T] {
+	return &Iterator[T]{ctx: ctx, cursor: cursor}
+}
+
+// Next decode next document, returns false on end of cursor or error
+//
+// decode errors returned by Err as *DecodeError
+func (me *Iterator[T]) Next() bool {
+	return me.next(me.ctx)
+}
+
+// next decode next document using ctx for cursor fetch
+func (me *Iterator[T]) next(ctx context.Context) bool {
+	if me.err != nil || !me.cursor.Next(ctx) {
+		return false
+	}
+	var v T
+	if err := me.cursor.Decode(&v); err != nil {
+		me.err = newDecodeError(me.cursor, err)
+		return false
+	}
+	me.value = v
+	return true
+}
+
+// Value get current document
+func (me *Iterator[T]) Value() T {
+	return me.value
+}
+
+// Err get iteration error
+func (me *Iterator[T]) Err() error {
+	if me.err != nil {
+		return me.err
+	}
+	return me.cursor.Err()
+}
+
+// Close close cursor
+func (me *Iterator[T]) Close() error {
+	return me.cursor.Close(context.Background())
+}
+
+// Find find documents and decode to T
+//
+// nil filter match all documents
+func Find[T any](ctx context.Context, coll Collection, filter any, opts ...*options.FindOptions) ([]T, error) {
+	cursor, err := coll.Find(ctx, orEmpty(filter), opts...)
+	if err != nil {
+		return nil, err
+	}
+	return decodeAll[T](ctx, cursor)
+}
+
+// FindIter find documents and returns typed iterator
+func FindIter[T any](ctx context.Context, coll Collection, filter any, opts ...*options.FindOptions) (*Iterator[T], error) {
+	cursor, err := coll.Find(ctx, orEmpty(filter), opts...)
+	if err != nil {
+		return nil, err
+	}
+	return NewIterator[T](ctx, cursor), nil
+}
+
+// FindOne find single document and decode to T
+//
+// returns ErrNotFound if no document found
+func FindOne[T any](ctx context.Context, coll Collection, filter any, opts ...*options.FindOneOptions) (*T, error) {
+	res := new(T)
+	if err := coll.FindOne(ctx, orEmpty(filter), opts...).Decode(res); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return res, nil
+}
+
+// FindByID find document by _id and decode to T
+//
+// returns ErrNotFound if no document found
+func FindByID[T any](ctx context.Context, coll Collection, id any, opts ...*options.FindOneOptions) (*T, error) {
+	return FindOne[T](ctx, coll, primitive.M{"_id": id}, opts...)
+}
+
+// Aggregate run pipeline and decode result to T
+//
+// AggregateOption used if no option passed, invalid projection error returned before run
+func Aggregate[T any](ctx context.Context, coll Collection, pipe MongoPipeline, opts ...*options.AggregateOptions) ([]T, error) {
+	if err := pipeErr(pipe); err != nil {
+		return nil, err
+	}
+	cursor, err := aggregate(ctx, coll, pipe.Build(), opts)
+	if err != nil {
+		return nil, err
+	}
+	return decodeAll[T](ctx, cursor)
+}
+
+// AggregateIter run pipeline and returns typed iterator
+//
+// AggregateOption used if no option passed
+func AggregateIter[T any](ctx context.Context, coll Collection, pipe MongoPipeline, opts ...*options.AggregateOptions) (*Iterator[T], error) {
+	if err := pipeErr(pipe); err != nil {
+		return nil, err
+	}
+	cursor, err := aggregate(ctx, coll, pipe.Build(), opts)
+	if err != nil {
+		return nil, err
+	}
+	return NewIterator[T](ctx, cursor), nil
+}
+
+// AggregateOne run pipeline with $limit 1 stage and decode first result to T
+//
+// returns ErrNotFound if pipeline has no result
+func AggregateOne[T any](ctx context.Context, coll Collection, pipe MongoPipeline, opts ...*options.AggregateOptions) (*T, error) {
+	if err := pipeErr(pipe); err != nil {
+		return nil, err
+	}
+	stages := pipe.Build()
+	pipeline := make(mongo.Pipeline, len(stages), len(stages)+1)
+	copy(pipeline, stages)
+	pipeline = append(pipeline, primitive.D{{Key: "$limit", Value: 1}})
+	cursor, err := aggregate(ctx, coll, pipeline, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+	if !cursor.Next(ctx) {
+		if err := cursor.Err(); err != nil {
+			return nil, err
+		}
+		return nil, ErrNotFound
+	}
+	res := new(T)
+	if err := cursor.Decode(res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func aggregate(ctx context.Context, coll Collection, pipeline mongo.Pipeline, opts []*options.AggregateOptions) (*mongo.Cursor, error) {
+	if len(opts) == 0 {
+		opts = []*options.AggregateOptions{AggregateOption()}
+	}
+	return coll.Aggregate(ctx, pipeline, opts...)
+}
+
+func decodeAll[T any](ctx context.Context, cursor *mongo.Cursor) ([]T, error) {
+	res := make([]T, 0)
+	if err := cursor.All(ctx, &res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func orEmpty(filter any) any {
+	if filter == nil {
+		return primitive.M{}
+	}
+	return filter
+}
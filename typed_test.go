@@ -0,0 +1,40 @@
+package mongoutils_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/bopher/mongoutils"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type typedPerson struct {
+	Name string `bson:"name"`
+}
+
+func TestTyped(t *testing.T) {
+	// ErrNotFound
+	if !errors.Is(mongoutils.ErrNotFound, mongo.ErrNoDocuments) {
+		t.Fatal("fail ErrNotFound")
+	}
+
+	// Iterator
+	cursor, err := mongo.NewCursorFromDocuments([]any{mongoutils.Map("name", "John"), mongoutils.Map("name", "Jack")}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	it := mongoutils.NewIterator[typedPerson](context.TODO(), cursor)
+	defer it.Close()
+	names := ""
+	for it.Next() {
+		names += it.Value().Name
+	}
+	if it.Err() != nil {
+		t.Fatal(it.Err())
+	}
+	if names != "JohnJack" {
+		t.Log(names)
+		t.Fatal("fail Iterator")
+	}
+}
@@ -0,0 +1,326 @@
+package mongoutils
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Validator model with Validate method
+//
+// Validate called before insert and update, returning ValidationErrors merged with struct tag errors
+type Validator interface {
+	Validate(ctx context.Context) error
+}
+
+// ValidationError single rule failure
+type ValidationError struct {
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+// ValidationErrors validation errors keyed by bson field path
+type ValidationErrors map[string][]ValidationError
+
+// Add add new error for field
+func (me ValidationErrors) Add(field string, rule string, param string, message string) ValidationErrors {
+	me[field] = append(me[field], ValidationError{Rule: rule, Param: param, Message: message})
+	return me
+}
+
+// Merge add other errors to errors
+func (me ValidationErrors) Merge(other ValidationErrors) ValidationErrors {
+	for k, errs := range other {
+		me[k] = append(me[k], errs...)
+	}
+	return me
+}
+
+// Error implement error interface
+func (me ValidationErrors) Error() string {
+	fields := make([]string, 0, len(me))
+	for k := range me {
+		fields = append(fields, k)
+	}
+	sort.Strings(fields)
+	msgs := make([]string, 0)
+	for _, f := range fields {
+		for _, e := range me[f] {
+			msgs = append(msgs, f+": "+e.Message)
+		}
+	}
+	return strings.Join(msgs, "; ")
+}
+
+var emailRx = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+var regexCache sync.Map
+
+// ValidateStruct validate struct fields using validate tag
+//
+// rules separated by comma, regex rule must be last rule:
+// required, min=n, max=n, len=n, enum=a|b|c, email, objectid, regex=pattern.
+// min, max and len compare length for string, slice and map. empty strings, slices, maps and nil pointers only checked by required rule, numeric zero checked by all rules.
+// returns nil or ValidationErrors
+func ValidateStruct(v any) error {
+	errs := make(ValidationErrors)
+	if err := validateValue(reflect.ValueOf(v), "", errs); err != nil {
+		return err
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// ValidateModel run struct tag validation and Validate method of model
+//
+// returns nil or ValidationErrors, non validation errors of Validate returned as is
+func ValidateModel(ctx context.Context, v any) error {
+	errs := make(ValidationErrors)
+	if err := ValidateStruct(v); err != nil {
+		if e, ok := err.(ValidationErrors); ok {
+			errs.Merge(e)
+		} else {
+			return err
+		}
+	}
+	if m, ok := v.(Validator); ok {
+		if err := m.Validate(ctx); err != nil {
+			if e, ok := err.(ValidationErrors); ok {
+				errs.Merge(e)
+			} else {
+				return err
+			}
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+func validateValue(v reflect.Value, prefix string, errs ValidationErrors) error {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		if isLeafStruct(v.Type()) {
+			return nil
+		}
+		return validateStruct(v, prefix, errs)
+	case reflect.Slice, reflect.Array:
+		if prefix == "" || v.Type().Elem().Kind() == reflect.Uint8 {
+			return nil
+		}
+		for i := 0; i < v.Len(); i++ {
+			if err := validateValue(v.Index(i), joinPath(prefix, strconv.Itoa(i)), errs); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func validateStruct(v reflect.Value, prefix string, errs ValidationErrors) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, inline, skip := bsonField(f)
+		if skip {
+			continue
+		}
+		if inline {
+			if err := validateValue(v.Field(i), prefix, errs); err != nil {
+				return err
+			}
+			continue
+		}
+		path := joinPath(prefix, name)
+		if tag := f.Tag.Get("validate"); tag != "" {
+			if err := validateField(v.Field(i), path, tag, errs); err != nil {
+				return err
+			}
+		}
+		if err := validateValue(v.Field(i), path, errs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateField(v reflect.Value, path string, tag string, errs ValidationErrors) error {
+	for _, rule := range splitRules(tag) {
+		name, param, _ := strings.Cut(rule, "=")
+		isNil := (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && v.IsNil()
+		if name == "required" {
+			if isNil || isEmptyValue(v) {
+				errs.Add(path, name, "", "is required")
+			}
+			continue
+		}
+		if isNil {
+			continue
+		}
+		rv := reflect.Indirect(v)
+		if rv.Kind() == reflect.Interface {
+			rv = rv.Elem()
+		}
+		// empty strings, slices and maps left to required, numeric zero validated
+		if !rv.IsValid() || isEmptyLength(rv) {
+			continue
+		}
+		switch name {
+		case "min", "max", "len":
+			limit, err := strconv.ParseFloat(param, 64)
+			if err != nil {
+				return fmt.Errorf("mongoutils: invalid %s param %q for %s", name, param, path)
+			}
+			n, isLength, ok := measure(rv)
+			if !ok {
+				return fmt.Errorf("mongoutils: %s rule not supported for %s", name, path)
+			}
+			switch {
+			case name == "min" && n < limit:
+				if isLength {
+					errs.Add(path, name, param, "length must be at least "+param)
+				} else {
+					errs.Add(path, name, param, "must be at least "+param)
+				}
+			case name == "max" && n > limit:
+				if isLength {
+					errs.Add(path, name, param, "length must be at most "+param)
+				} else {
+					errs.Add(path, name, param, "must be at most "+param)
+				}
+			case name == "len" && n != limit:
+				errs.Add(path, name, param, "length must be "+param)
+			}
+		case "enum":
+			options := strings.Split(param, "|")
+			found := false
+			s := fmt.Sprint(rv.Interface())
+			for _, o := range options {
+				if o == s {
+					found = true
+					break
+				}
+			}
+			if !found {
+				errs.Add(path, name, param, "must be one of "+strings.Join(options, ", "))
+			}
+		case "regex":
+			rx, err := compileRegex(param)
+			if err != nil {
+				return fmt.Errorf("mongoutils: invalid regex param %q for %s: %w", param, path, err)
+			}
+			if rv.Kind() != reflect.String {
+				return fmt.Errorf("mongoutils: regex rule not supported for %s", path)
+			}
+			if !rx.MatchString(rv.String()) {
+				errs.Add(path, name, param, "format is invalid")
+			}
+		case "email":
+			if rv.Kind() != reflect.String {
+				return fmt.Errorf("mongoutils: email rule not supported for %s", path)
+			}
+			if !isEmail(rv.String()) {
+				errs.Add(path, name, "", "must be valid email")
+			}
+		case "objectid":
+			switch id := rv.Interface().(type) {
+			case primitive.ObjectID:
+				// object id always valid, zero id left to required
+			case string:
+				if ParseObjectID(id) == nil {
+					errs.Add(path, name, "", "must be valid object id")
+				}
+			default:
+				return fmt.Errorf("mongoutils: objectid rule not supported for %s", path)
+			}
+		default:
+			return fmt.Errorf("mongoutils: unknown validation rule %q for %s", name, path)
+		}
+	}
+	return nil
+}
+
+// splitRules split validate tag to rules, regex rule consume rest of tag
+func splitRules(tag string) []string {
+	res := make([]string, 0)
+	for tag = strings.TrimSpace(tag); tag != ""; tag = strings.TrimSpace(tag) {
+		if strings.HasPrefix(tag, "regex=") {
+			res = append(res, tag)
+			break
+		}
+		rule, rest, _ := strings.Cut(tag, ",")
+		if rule = strings.TrimSpace(rule); rule != "" {
+			res = append(res, rule)
+		}
+		tag = rest
+	}
+	return res
+}
+
+// measure get numeric value or length of value
+func measure(v reflect.Value) (n float64, isLength bool, ok bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), false, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), false, true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), false, true
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), true, true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(v.Len()), true, true
+	}
+	return 0, false, false
+}
+
+func isEmptyLength(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map, reflect.String:
+		return v.Len() == 0
+	}
+	return false
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map, reflect.String:
+		return v.Len() == 0
+	}
+	return v.IsZero()
+}
+
+func isEmail(s string) bool {
+	addr, err := mail.ParseAddress(s)
+	return err == nil && addr.Address == s && emailRx.MatchString(s)
+}
+
+func compileRegex(pattern string) (*regexp.Regexp, error) {
+	if rx, ok := regexCache.Load(pattern); ok {
+		return rx.(*regexp.Regexp), nil
+	}
+	rx, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	regexCache.Store(pattern, rx)
+	return rx, nil
+}
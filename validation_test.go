@@ -0,0 +1,113 @@
+package mongoutils_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bopher/mongoutils"
+)
+
+type validationAddress struct {
+	City string `bson:"city" validate:"required"`
+}
+
+type validationPerson struct {
+	mongoutils.Model `bson:",inline"`
+	Name             string              `bson:"name" validate:"required,min=3,max=10"`
+	Age              int                 `bson:"age" validate:"min=18"`
+	Code             string              `bson:"code" validate:"len=4"`
+	Status           string              `bson:"status" validate:"enum=active|banned"`
+	Slug             string              `bson:"slug" validate:"regex=^[a-z]{1,3}$"`
+	Email            *string             `bson:"email" validate:"email"`
+	Owner            string              `bson:"owner" validate:"objectid"`
+	Address          validationAddress   `bson:"address"`
+	Others           []validationAddress `bson:"others"`
+}
+
+func (me validationPerson) Validate(ctx context.Context) error {
+	if me.Name == "admin" {
+		return mongoutils.ValidationErrors{}.Add("name", "reserved", "", "is reserved")
+	}
+	return nil
+}
+
+func TestValidation(t *testing.T) {
+	email := "invalid"
+	p := validationPerson{
+		Name:   "Jo",
+		Age:    12,
+		Code:   "12345",
+		Status: "deleted",
+		Slug:   "abcd",
+		Email:  &email,
+		Owner:  "123",
+		Others: []validationAddress{{City: "London"}, {}},
+	}
+	err := mongoutils.ValidateModel(context.TODO(), p)
+	errs, ok := err.(mongoutils.ValidationErrors)
+	if !ok {
+		t.Fatal("fail ValidateModel", err)
+	}
+	for field, rule := range map[string]string{
+		"name":          "min",
+		"age":           "min",
+		"code":          "len",
+		"status":        "enum",
+		"slug":          "regex",
+		"email":         "email",
+		"owner":         "objectid",
+		"address.city":  "required",
+		"others.1.city": "required",
+	} {
+		if len(errs[field]) != 1 || errs[field][0].Rule != rule {
+			t.Log(errs)
+			t.Fatal("fail " + field)
+		}
+	}
+	if len(errs) != 9 {
+		t.Log(errs)
+		t.Fatal("fail errors count")
+	}
+
+	// Zero values
+	zero := struct {
+		Age   int `bson:"age" validate:"min=18"`
+		Level int `bson:"level" validate:"enum=1|2"`
+	}{}
+	err = mongoutils.ValidateStruct(zero)
+	if errs, ok := err.(mongoutils.ValidationErrors); !ok || len(errs) != 2 || errs["age"][0].Rule != "min" || errs["level"][0].Rule != "enum" {
+		t.Log(err)
+		t.Fatal("fail zero values")
+	}
+
+	// JSON
+	v, err := pretty(mongoutils.ValidationErrors{}.Add("age", "min", "18", "must be at least 18"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != `{"age":[{"rule":"min","param":"18","message":"must be at least 18"}]}` {
+		t.Log(v)
+		t.Fatal("fail JSON")
+	}
+
+	// Validate method
+	p = validationPerson{Name: "admin", Age: 20, Address: validationAddress{City: "Paris"}}
+	err = mongoutils.ValidateModel(context.TODO(), p)
+	if errs, ok := err.(mongoutils.ValidationErrors); !ok || len(errs) != 1 || errs["name"][0].Rule != "reserved" {
+		t.Log(err)
+		t.Fatal("fail Validate")
+	}
+
+	// Valid
+	email = "john@doe.com"
+	p = validationPerson{
+		Name:    "John",
+		Age:     20,
+		Status:  "active",
+		Email:   &email,
+		Address: validationAddress{City: "Paris"},
+	}
+	if err := mongoutils.ValidateStruct(&p); err != nil {
+		t.Fatal(err)
+	}
+}